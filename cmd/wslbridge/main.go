@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"wslbridge/internal/command"
 	"wslbridge/internal/commands"
@@ -28,11 +29,19 @@ func main() {
 		os.Exit(1)
 	}
 
-	rt, err := runtime.New(execx.OSRunner{}, platformInfo)
+	flags, args, err := splitGlobalFlags(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(2)
+	}
+
+	rt, err := runtime.NewForProfile(execx.OSRunner{}, platformInfo, flags.profile, createsProfiles(args))
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
+	rt.ConfigFlags = flags.sets
+	rt.NonInteractive = flags.nonInteractive
 
 	reg := command.New(commands.All()...)
 
@@ -58,12 +67,67 @@ func main() {
 	}
 }
 
+// globalFlags are the options accepted before the command name.
+type globalFlags struct {
+	profile        string
+	sets           map[string]string
+	nonInteractive bool
+}
+
+// splitGlobalFlags removes leading `--profile <name>`, `--set key=value`
+// (also in `--flag=value` form) and `--non-interactive` options from args.
+func splitGlobalFlags(args []string) (globalFlags, []string, error) {
+	var g globalFlags
+	for len(args) > 0 {
+		if args[0] == "--non-interactive" {
+			g.nonInteractive = true
+			args = args[1:]
+			continue
+		}
+		name, val, hasVal := strings.Cut(args[0], "=")
+		if name != "--profile" && name != "--set" {
+			break
+		}
+		if !hasVal {
+			if len(args) < 2 || strings.HasPrefix(args[1], "-") {
+				return g, nil, fmt.Errorf("%s requires a value", name)
+			}
+			val = args[1]
+			args = args[1:]
+		}
+		args = args[1:]
+		if val == "" {
+			return g, nil, fmt.Errorf("%s requires a value", name)
+		}
+
+		if name == "--profile" {
+			g.profile = val
+			continue
+		}
+		key, v, ok := strings.Cut(val, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return g, nil, fmt.Errorf("--set expects key=value, got %q", val)
+		}
+		if g.sets == nil {
+			g.sets = map[string]string{}
+		}
+		g.sets[strings.ToLower(strings.TrimSpace(key))] = v
+	}
+	return g, args, nil
+}
+
+// createsProfiles reports whether the command in args may run with a
+// profile that does not exist yet: `profile` creates and lists profiles.
+func createsProfiles(args []string) bool {
+	return len(args) > 0 && args[0] == "profile"
+}
+
 func isHelp(s string) bool {
 	return s == "help" || s == "-h" || s == "--help"
 }
 
 func printHelp(reg command.Registry) {
-	fmt.Println("Usage: wslbridge <command> [args]")
+	fmt.Println("Usage: wslbridge [--profile <name>] [--set <key>=<value>]... [--non-interactive] <command> [args]")
 	fmt.Println()
 	fmt.Println("Commands:")
 	for _, line := range reg.HelpLines() {
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats accepted by --output.
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+	OutputYAML = "yaml"
+)
+
+// OutputFlag removes `--output <format>` (also `--output=<format>` and `-o`)
+// from args and returns the format, text when the flag is absent.
+func OutputFlag(args []string) (string, []string, error) {
+	format := OutputText
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		name, val, hasVal := strings.Cut(args[i], "=")
+		if name != "--output" && name != "-o" {
+			rest = append(rest, args[i])
+			continue
+		}
+		if !hasVal {
+			if i+1 >= len(args) {
+				return "", nil, fmt.Errorf("%s requires a value (json|yaml)", name)
+			}
+			i++
+			val = args[i]
+		}
+		switch val = strings.ToLower(strings.TrimSpace(val)); val {
+		case OutputText, OutputJSON, OutputYAML:
+			format = val
+		default:
+			return "", nil, fmt.Errorf("unsupported output format %q (use json|yaml)", val)
+		}
+	}
+	return format, rest, nil
+}
+
+// WriteOutput encodes v to w in format, json or yaml.
+func WriteOutput(w io.Writer, format string, v any) error {
+	switch format {
+	case OutputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case OutputYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
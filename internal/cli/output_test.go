@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// TestOutputFlag verifies --output parsing in its spellings.
+func TestOutputFlag(t *testing.T) {
+	cases := []struct {
+		args   []string
+		format string
+		rest   []string
+		ok     bool
+	}{
+		{nil, OutputText, []string{}, true},
+		{[]string{"--output=json"}, OutputJSON, []string{}, true},
+		{[]string{"x", "--output", "YAML"}, OutputYAML, []string{"x"}, true},
+		{[]string{"-o", "json", "y"}, OutputJSON, []string{"y"}, true},
+		{[]string{"--output"}, "", nil, false},
+		{[]string{"--output=xml"}, "", nil, false},
+	}
+	for _, tc := range cases {
+		format, rest, err := OutputFlag(tc.args)
+		if (err == nil) != tc.ok {
+			t.Fatalf("OutputFlag(%q) err=%v, want ok=%v", tc.args, err, tc.ok)
+		}
+		if tc.ok && (format != tc.format || !reflect.DeepEqual(rest, tc.rest)) {
+			t.Fatalf("OutputFlag(%q) = %q, %q; want %q, %q", tc.args, format, rest, tc.format, tc.rest)
+		}
+	}
+}
+
+// TestWriteOutput verifies that both encoders use the field tags.
+func TestWriteOutput(t *testing.T) {
+	v := struct {
+		Name string `json:"name" yaml:"name"`
+		Up   bool   `json:"up" yaml:"up"`
+	}{"tun0", true}
+
+	var buf bytes.Buffer
+	if err := WriteOutput(&buf, OutputJSON, v); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if got, want := buf.String(), "{\n  \"name\": \"tun0\",\n  \"up\": true\n}\n"; got != want {
+		t.Fatalf("json = %q, want %q", got, want)
+	}
+
+	buf.Reset()
+	if err := WriteOutput(&buf, OutputYAML, v); err != nil {
+		t.Fatalf("yaml: %v", err)
+	}
+	if got, want := buf.String(), "name: tun0\nup: true\n"; got != want {
+		t.Fatalf("yaml = %q, want %q", got, want)
+	}
+}
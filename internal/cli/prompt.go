@@ -2,15 +2,21 @@ package cli
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
 )
 
-// Prompter provides interactive string prompts.
+// Prompter provides interactive string prompts. Named prompts can be
+// answered up front (from command-line flags), and in non-interactive mode
+// nothing is read: prompts take the current or default value, and a value
+// that has neither is an error.
 type Prompter struct {
-	in  *bufio.Reader
-	out io.Writer
+	in             *bufio.Reader
+	out            io.Writer
+	answers        map[string]string
+	nonInteractive bool
 }
 
 // NewPrompter creates a new Prompter.
@@ -18,8 +24,64 @@ func NewPrompter(in io.Reader, out io.Writer) *Prompter {
 	return &Prompter{in: bufio.NewReader(in), out: out}
 }
 
+// WithAnswers presets the values of named prompts, keyed by flag name
+// without dashes.
+func (p *Prompter) WithAnswers(answers map[string]string) *Prompter {
+	p.answers = answers
+	return p
+}
+
+// WithNonInteractive turns reading from the input off.
+func (p *Prompter) WithNonInteractive(on bool) *Prompter {
+	p.nonInteractive = on
+	return p
+}
+
+// Has reports whether the prompt named name has a preset answer.
+func (p *Prompter) Has(name string) bool {
+	_, ok := p.answers[name]
+	return ok
+}
+
+// Ask is AskString for a prompt that the --<name> flag answers. A preset
+// answer that fails validation is an error instead of a new prompt.
+func (p *Prompter) Ask(name, label, def, current string, validate func(string) error) (string, error) {
+	if val, ok := p.answers[name]; ok {
+		val = strings.TrimSpace(val)
+		if validate != nil {
+			if err := validate(val); err != nil {
+				return "", fmt.Errorf("--%s: %w", name, err)
+			}
+		}
+		return val, nil
+	}
+	if p.nonInteractive && current == "" && def == "" {
+		return "", fmt.Errorf("missing --%s (%s): %w", name, label, ErrNonInteractive)
+	}
+	return p.AskString(label, def, current, validate)
+}
+
+// ErrNonInteractive is returned for prompts that need an answer in
+// non-interactive mode.
+var ErrNonInteractive = errors.New("a value is required in non-interactive mode")
+
 // AskString prompts for a string with optional validation.
 func (p *Prompter) AskString(label, def, current string, validate func(string) error) (string, error) {
+	if p.nonInteractive {
+		val := current
+		if val == "" {
+			val = def
+		}
+		if val == "" {
+			return "", fmt.Errorf("%s: %w", label, ErrNonInteractive)
+		}
+		if validate != nil {
+			if err := validate(val); err != nil {
+				return "", fmt.Errorf("%s: %w", label, err)
+			}
+		}
+		return val, nil
+	}
 	for {
 		if current != "" {
 			_, _ = fmt.Fprintf(p.out, "%s (current: %s): ", label, current)
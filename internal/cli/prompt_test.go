@@ -54,3 +54,42 @@ func TestAskString_Validate(t *testing.T) {
 		t.Fatalf("AskString = %q, want %q", got, "ok")
 	}
 }
+
+// TestAsk_Answers validates that preset answers skip the prompt and are
+// validated.
+func TestAsk_Answers(t *testing.T) {
+	out := &bytes.Buffer{}
+	p := NewPrompter(strings.NewReader(""), out).WithAnswers(map[string]string{"port": "6432", "role": "leader"})
+	got, err := p.Ask("port", "Port", "5432", "", nil)
+	if err != nil || got != "6432" {
+		t.Fatalf("Ask = %q, %v; want 6432", got, err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("preset answer must not prompt, got %q", out.String())
+	}
+	_, err = p.Ask("role", "Role", "any", "", func(s string) error {
+		if s != "any" {
+			return errors.New("bad role")
+		}
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "--role") {
+		t.Fatalf("invalid answer error = %v", err)
+	}
+}
+
+// TestAsk_NonInteractive validates that nothing is read and missing values
+// fail.
+func TestAsk_NonInteractive(t *testing.T) {
+	p := NewPrompter(strings.NewReader("typed\n"), &bytes.Buffer{}).WithNonInteractive(true)
+	if got, err := p.Ask("port", "Port", "5432", "", nil); err != nil || got != "5432" {
+		t.Fatalf("Ask default = %q, %v", got, err)
+	}
+	if got, err := p.AskString("Role", "any", "sync", nil); err != nil || got != "sync" {
+		t.Fatalf("AskString current = %q, %v", got, err)
+	}
+	_, err := p.Ask("service-discovery", "Service discovery URL", "", "", nil)
+	if !errors.Is(err, ErrNonInteractive) || !strings.Contains(err.Error(), "--service-discovery") {
+		t.Fatalf("missing value error = %v", err)
+	}
+}
@@ -2,7 +2,11 @@ package commands
 
 import (
 	"wslbridge/internal/command"
+	configcmd "wslbridge/internal/commands/config"
 	dbcmd "wslbridge/internal/commands/db"
+	doctorcmd "wslbridge/internal/commands/doctor"
+	profilecmd "wslbridge/internal/commands/profile"
+	secretcmd "wslbridge/internal/commands/secret"
 	"wslbridge/internal/driver"
 	appruntime "wslbridge/internal/runtime"
 )
@@ -48,6 +52,17 @@ func All() []command.Command {
 				return d.Stop(rt, args)
 			},
 		},
+		driverCommand{
+			name: "watchdog",
+			help: "Watch the tunnel and restart tun2socks on failure (start|stop|status|run)",
+			run: func(d driver.Driver, rt appruntime.Runtime, args []string) error {
+				return d.Watchdog(rt, args)
+			},
+		},
 		dbcmd.Command{},
+		configcmd.Command{},
+		profilecmd.Command{},
+		secretcmd.Command{},
+		doctorcmd.Command{},
 	}
 }
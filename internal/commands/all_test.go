@@ -5,15 +5,20 @@ import "testing"
 // TestAllCommandsMetadata validates exported top-level CLI command metadata.
 func TestAllCommandsMetadata(t *testing.T) {
 	cmds := All()
-	if len(cmds) != 4 {
-		t.Fatalf("All() returned %d commands, want 4", len(cmds))
+	if len(cmds) != 9 {
+		t.Fatalf("All() returned %d commands, want 9", len(cmds))
 	}
 
 	want := map[string]string{
-		"init":   "Initialize wslbridge for the current OS/environment",
-		"status": "Show wslbridge status (current OS/environment)",
-		"stop":   "Stop wslbridge and restore routes (current OS/environment)",
-		"db":     "Manage service-discovery-driven local DB proxy (init|start|status|stop|add|remove)",
+		"init":     "Initialize wslbridge for the current OS/environment",
+		"status":   "Show wslbridge status (current OS/environment)",
+		"stop":     "Stop wslbridge and restore routes (current OS/environment)",
+		"watchdog": "Watch the tunnel and restart tun2socks on failure (start|stop|status|run)",
+		"db":       "Manage service-discovery-driven local DB proxy (init|start|status|stop|add|search|list-remote|endpoints|pin|unpin|env|remove|auto|route)",
+		"config":   "Show and edit the layered configuration (show|get|set|unset|edit|export|import|migrate)",
+		"profile":  "Manage named config profiles (list|use|show)",
+		"secret":   "Manage the local encrypted secret store (set|get|rm|list)",
+		"doctor":   "Diagnose the bridge end to end and suggest fixes",
 	}
 
 	for _, c := range cmds {
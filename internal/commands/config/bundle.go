@@ -0,0 +1,168 @@
+package configcmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"wslbridge/internal/config"
+	"wslbridge/internal/db"
+	appruntime "wslbridge/internal/runtime"
+)
+
+// Conflict policies of `config import`.
+const (
+	conflictAsk     = "ask"
+	conflictKeep    = "keep"
+	conflictReplace = "replace"
+)
+
+func runExport(rt appruntime.Runtime, args []string) error {
+	dbOnly := false
+	output := ""
+	for _, a := range args {
+		switch {
+		case a == "--db":
+			dbOnly = true
+		case strings.HasPrefix(a, "--output="):
+			output = strings.TrimPrefix(a, "--output=")
+		default:
+			return fmt.Errorf("unknown arg: %s", a)
+		}
+	}
+	if !dbOnly {
+		return fmt.Errorf("usage: config export --db [--output=<file>]")
+	}
+
+	res, err := rt.ResolveConfig(config.Config{})
+	if err != nil {
+		return err
+	}
+	b, err := db.MarshalBundle(db.ExportBundle(res.Config.DB))
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		fmt.Print(string(b))
+		return nil
+	}
+	if err := os.WriteFile(output, b, 0o644); err != nil {
+		return err
+	}
+	fmt.Println("exported db bundle:", output)
+	return nil
+}
+
+func runImport(rt appruntime.Runtime, args []string) error {
+	source := ""
+	policy := conflictAsk
+	dryRun := false
+	yes := false
+	for _, a := range args {
+		switch {
+		case strings.HasPrefix(a, "--on-conflict="):
+			policy = strings.TrimPrefix(a, "--on-conflict=")
+			if policy != conflictAsk && policy != conflictKeep && policy != conflictReplace {
+				return fmt.Errorf("--on-conflict must be one of: ask, keep, replace")
+			}
+		case a == "--dry-run":
+			dryRun = true
+		case a == "--yes":
+			yes = true
+		case strings.HasPrefix(a, "--"):
+			return fmt.Errorf("unknown arg: %s", a)
+		case source == "":
+			source = a
+		default:
+			return fmt.Errorf("unexpected arg: %s", a)
+		}
+	}
+	if source == "" {
+		return fmt.Errorf("usage: config import <file|url> [--on-conflict=ask|keep|replace] [--dry-run] [--yes]")
+	}
+
+	data, err := db.ReadBundle(source)
+	if err != nil {
+		return err
+	}
+	bundle, err := db.ParseBundle(data)
+	if err != nil {
+		return err
+	}
+	path, cfg, err := loadTarget(rt)
+	if err != nil {
+		return err
+	}
+
+	changes := db.PlanImport(cfg.DB, bundle)
+	if len(changes) == 0 {
+		fmt.Println("config already matches the bundle:", path)
+		return nil
+	}
+	fmt.Printf("Changes to %s:\n", path)
+	printChanges(changes)
+	if dryRun {
+		return nil
+	}
+
+	pr := rt.Prompter(nil)
+	var apply []db.ImportChange
+	for _, ch := range changes {
+		if !ch.Conflict {
+			apply = append(apply, ch)
+			continue
+		}
+		take := policy == conflictReplace
+		if policy == conflictAsk {
+			ans, err := pr.AskString(fmt.Sprintf("%s: replace %q with %q? (yes/no)", ch.Key, ch.Old, ch.New), "no", "", validateYesNo)
+			if err != nil {
+				return err
+			}
+			take = isYes(ans)
+		}
+		if take {
+			apply = append(apply, ch)
+		}
+	}
+	if len(apply) == 0 {
+		fmt.Println("nothing to import: all changes conflict with local values that are kept")
+		return nil
+	}
+	if !yes {
+		ans, err := pr.AskString(fmt.Sprintf("Apply %d change(s)? (yes/no)", len(apply)), "yes", "", validateYesNo)
+		if err != nil {
+			return err
+		}
+		if !isYes(ans) {
+			return fmt.Errorf("import cancelled")
+		}
+	}
+
+	for _, ch := range apply {
+		ch.Apply(&cfg.DB)
+	}
+	if err := config.Save(path, cfg); err != nil {
+		return err
+	}
+	fmt.Printf("imported %d change(s) into %s\n", len(apply), path)
+	for _, note := range db.ImportNotes(cfg.DB) {
+		fmt.Println("note:", note)
+	}
+	return nil
+}
+
+// printChanges shows the import diff: `+` adds a value, `~` replaces a
+// local one.
+func printChanges(changes []db.ImportChange) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, ch := range changes {
+		switch {
+		case ch.Conflict:
+			fmt.Fprintf(tw, "~ %s\t%s -> %s\t(conflict)\n", ch.Key, ch.Old, ch.New)
+		default:
+			fmt.Fprintf(tw, "+ %s\t%s\t\n", ch.Key, ch.New)
+		}
+	}
+	_ = tw.Flush()
+}
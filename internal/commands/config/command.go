@@ -0,0 +1,147 @@
+package configcmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	initubuntu "wslbridge/internal/commands/init-ubuntu"
+	"wslbridge/internal/config"
+	"wslbridge/internal/db"
+	appruntime "wslbridge/internal/runtime"
+)
+
+// Command implements layered config inspection and editing.
+type Command struct{}
+
+// Name returns the command name.
+func (Command) Name() string { return "config" }
+
+// Help returns the command description.
+func (Command) Help() string {
+	return "Show and edit the layered configuration (show|get|set|unset|edit|export|import|migrate)"
+}
+
+// Run executes config command.
+func (Command) Run(rt appruntime.Runtime, args []string) error {
+	if len(args) == 0 {
+		return show(rt, false)
+	}
+
+	switch args[0] {
+	case "show":
+		origin := false
+		for _, a := range args[1:] {
+			switch a {
+			case "--origin":
+				origin = true
+			default:
+				return fmt.Errorf("unknown arg: %s", a)
+			}
+		}
+		return show(rt, origin)
+	case "get":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: config get <key>")
+		}
+		return get(rt, args[1])
+	case "set":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: config set <key> <value>")
+		}
+		return set(rt, args[1], args[2])
+	case "unset":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: config unset <key>")
+		}
+		return unset(rt, args[1])
+	case "edit":
+		if len(args) > 1 {
+			return fmt.Errorf("unknown arg: %s", args[1])
+		}
+		return edit(rt)
+	case "migrate":
+		if len(args) > 1 {
+			return fmt.Errorf("unknown arg: %s", args[1])
+		}
+		return migrate(rt)
+	case "export":
+		return runExport(rt, args[1:])
+	case "import":
+		return runImport(rt, args[1:])
+	default:
+		return fmt.Errorf("unknown config subcommand: %s", args[0])
+	}
+}
+
+// migrate upgrades the user and project config files to the current schema
+// version; Load only migrates them in memory.
+func migrate(rt appruntime.Runtime) error {
+	paths := []string{rt.Paths.ConfigPath}
+	if user := rt.Paths.UserConfigPath; user != "" && user != rt.Paths.ConfigPath {
+		paths = append([]string{user}, paths...)
+	}
+	found := false
+	for _, path := range paths {
+		from, backup, err := config.Migrate(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+		if from < config.CurrentVersion {
+			fmt.Printf("migrated %s from version %d to %d (backup: %s)\n", path, from, config.CurrentVersion, backup)
+		} else {
+			fmt.Printf("%s is already at version %d\n", path, config.CurrentVersion)
+		}
+	}
+	if !found {
+		fmt.Println("Config file: not found (run `init` or `db init`)")
+	}
+	return nil
+}
+
+// defaults returns the values the commands fill in for empty config keys.
+func defaults() config.Config {
+	cfg := db.DefaultConfig()
+	cfg.Tun = initubuntu.DefaultConfig().Tun
+	return cfg
+}
+
+func show(rt appruntime.Runtime, origin bool) error {
+	res, err := rt.ResolveConfig(defaults())
+	if err != nil {
+		return err
+	}
+	fmt.Println("Config:", res.Path())
+	if !res.Exists {
+		fmt.Println("Config file: not found (run `init` or `db init`)")
+	}
+
+	if !origin {
+		b, err := config.Marshal(res.Config)
+		if err != nil {
+			return err
+		}
+		if b, err = MaskYAML(b); err != nil {
+			return err
+		}
+		fmt.Print(string(b))
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tVALUE\tORIGIN")
+	for _, k := range config.Keys() {
+		val, ok := res.Value(k.Path)
+		if !ok {
+			continue
+		}
+		from, _ := res.Origin(k.Path)
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", k.Path, displayValue(k.Path, val), from)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,284 @@
+package configcmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"wslbridge/internal/cli"
+	initubuntu "wslbridge/internal/commands/init-ubuntu"
+	"wslbridge/internal/config"
+	"wslbridge/internal/db"
+	appruntime "wslbridge/internal/runtime"
+)
+
+func get(rt appruntime.Runtime, key string) error {
+	res, err := rt.ResolveConfig(defaults())
+	if err != nil {
+		return err
+	}
+	val, ok, err := config.Get(res.Config, key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%s is not set", key)
+	}
+	fmt.Println(val)
+	return nil
+}
+
+// set writes key to the config file that saves go to (the project config
+// when there is one) and leaves the other layers alone.
+func set(rt appruntime.Runtime, key, raw string) error {
+	key = strings.ToLower(strings.TrimSpace(key))
+	path, cfg, err := loadTarget(rt)
+	if err != nil {
+		return err
+	}
+	if err := config.Set(&cfg, key, raw); err != nil {
+		return err
+	}
+	if err := validateKey(cfg, key); err != nil {
+		return err
+	}
+	if err := config.Save(path, cfg); err != nil {
+		return err
+	}
+	val, _, _ := config.Get(cfg, key)
+	fmt.Printf("%s = %s (%s)\n", key, displayValue(key, val), path)
+	return shadowNote(rt, key, path)
+}
+
+func unset(rt appruntime.Runtime, key string) error {
+	key = strings.ToLower(strings.TrimSpace(key))
+	path, cfg, err := loadTarget(rt)
+	if err != nil {
+		return err
+	}
+	if _, ok, err := config.Get(cfg, key); err != nil {
+		return err
+	} else if !ok {
+		fmt.Printf("%s is not set in %s\n", key, path)
+		return nil
+	}
+	if err := config.Unset(&cfg, key); err != nil {
+		return err
+	}
+	if err := config.Save(path, cfg); err != nil {
+		return err
+	}
+	fmt.Printf("%s unset (%s)\n", key, path)
+
+	res, err := rt.ResolveConfig(defaults())
+	if err != nil {
+		return err
+	}
+	if val, ok := res.Value(key); ok {
+		from, _ := res.Origin(key)
+		fmt.Printf("effective: %s (%s)\n", displayValue(key, val), from)
+	}
+	return nil
+}
+
+// loadTarget returns the config file that set and unset write to and its
+// contents (empty when it does not exist yet).
+func loadTarget(rt appruntime.Runtime) (string, config.Config, error) {
+	path := rt.ConfigSources(config.Config{}).TargetPath()
+	cfg, err := config.Load(path, appruntime.SecretStores(rt.Paths)...)
+	if errors.Is(err, os.ErrNotExist) {
+		return path, config.Config{}, nil
+	}
+	return path, cfg, err
+}
+
+// shadowNote warns when an env var or --set flag hides the value just saved.
+func shadowNote(rt appruntime.Runtime, key, path string) error {
+	res, err := rt.ResolveConfig(defaults())
+	if err != nil {
+		return err
+	}
+	from, ok := res.Origin(key)
+	if !ok || strings.HasSuffix(from, path) {
+		return nil
+	}
+	val, _ := res.Value(key)
+	fmt.Printf("note: effective value is %s from %s\n", displayValue(key, val), from)
+	return nil
+}
+
+// edit opens the target config in $VISUAL/$EDITOR and saves it only after it
+// parses and validates; on errors the editor can be reopened.
+func edit(rt appruntime.Runtime) error {
+	path := rt.ConfigSources(config.Config{}).TargetPath()
+	body, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// Edit a copy next to the target so relative `extends` paths resolve.
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.yaml")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	editor := editorCommand()
+	pr := rt.Prompter(nil)
+	for {
+		if err := rt.Runner.Run(editor[0], append(editor[1:], tmpPath)...); err != nil {
+			return fmt.Errorf("editor %s: %w", strings.Join(editor, " "), err)
+		}
+		errs := checkFile(tmpPath, appruntime.SecretStores(rt.Paths))
+		if len(errs) == 0 {
+			break
+		}
+		fmt.Println("config is invalid:")
+		for _, err := range errs {
+			fmt.Println("  " + err.Error())
+		}
+		if rt.NonInteractive {
+			return fmt.Errorf("config not saved")
+		}
+		again, err := pr.AskString("Edit again? (yes/no)", "yes", "", validateYesNo)
+		if err != nil {
+			return err
+		}
+		if !isYes(again) {
+			return fmt.Errorf("config not saved")
+		}
+	}
+
+	edited, err := os.ReadFile(tmpPath)
+	if err != nil {
+		return err
+	}
+	if string(edited) == string(body) {
+		fmt.Println("config unchanged:", path)
+		return nil
+	}
+	if err := os.Chmod(tmpPath, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	fmt.Println("saved config:", path)
+	return nil
+}
+
+func editorCommand() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if fields := strings.Fields(os.Getenv(env)); len(fields) > 0 {
+			return fields
+		}
+	}
+	return []string{"vi"}
+}
+
+// checkFile parses the config at path, rejecting unknown keys, and
+// validates every key.
+func checkFile(path string, secretStores []string) []error {
+	cfg, err := config.LoadStrict(path, secretStores...)
+	if err != nil {
+		return []error{err}
+	}
+	var errs []error
+	for _, k := range config.Keys() {
+		if err := validateKey(cfg, k.Path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// validateKey checks the value of key in cfg; empty values are valid.
+func validateKey(cfg config.Config, key string) error {
+	var err error
+	switch key {
+	case "socks.host":
+		err = validateOptional(cfg.Socks.Host, cli.ValidateHostOrIP)
+	case "socks.port":
+		err = validateOptional(portString(cfg.Socks.Port), cli.ValidatePort)
+	case "tun.cidr":
+		err = validateOptional(cfg.Tun.CIDR, validateCIDR)
+	case "tun.mode":
+		err = initubuntu.ValidateTunMode(cfg.Tun.Mode)
+	case "tun.routes":
+		err = validateEach(cfg.Tun.Routes, initubuntu.ValidateRoute)
+	case "tun.bypass":
+		err = validateEach(cfg.Tun.Bypass, initubuntu.ValidateRoute)
+	case "tun.domains":
+		err = validateEach(cfg.Tun.Domains, cli.ValidateHostOrIP)
+	case "dns.nameserver":
+		err = validateOptional(cfg.DNS.Nameserver, cli.ValidateIP)
+	default:
+		return db.ValidateConfigKey(cfg, key)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	return nil
+}
+
+func validateEach(values []string, validate func(string) error) error {
+	for _, v := range values {
+		if err := validate(v); err != nil {
+			return fmt.Errorf("%q: %w", v, err)
+		}
+	}
+	return nil
+}
+
+func validateOptional(s string, validate func(string) error) error {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	return validate(s)
+}
+
+func portString(port int) string {
+	if port == 0 {
+		return ""
+	}
+	return strconv.Itoa(port)
+}
+
+func validateCIDR(s string) error {
+	if _, _, err := net.ParseCIDR(strings.TrimSpace(s)); err != nil {
+		return fmt.Errorf("must be a CIDR such as 10.0.0.2/24")
+	}
+	return nil
+}
+
+func validateYesNo(s string) error {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "y", "yes", "n", "no":
+		return nil
+	default:
+		return fmt.Errorf("must be yes or no")
+	}
+}
+
+func isYes(s string) bool {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
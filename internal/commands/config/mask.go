@@ -0,0 +1,80 @@
+package configcmd
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"wslbridge/internal/secrets"
+)
+
+const maskedValue = "********"
+
+// secretKey reports whether the config key (or its last element) holds a
+// password or token.
+func secretKey(key string) bool {
+	return strings.HasSuffix(key, "password") || strings.HasSuffix(key, "token")
+}
+
+// displayValue hides passwords and tokens in printed output, also inside
+// structured values such as db.environments. Secret references are shown
+// as they are.
+func displayValue(key, val string) string {
+	if secretKey(key) {
+		return maskValue(val)
+	}
+	if !strings.HasPrefix(val, "{") {
+		return val
+	}
+	var n yaml.Node
+	if err := yaml.Unmarshal([]byte(val), &n); err != nil || !maskNode(&n) {
+		return val
+	}
+	b, err := yaml.Marshal(&n)
+	if err != nil {
+		return maskedValue
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func maskValue(val string) string {
+	if val == "" || secrets.IsRef(val) {
+		return val
+	}
+	return maskedValue
+}
+
+// MaskYAML hides passwords and tokens in a marshalled config. Secret
+// references are shown as they are.
+func MaskYAML(b []byte) ([]byte, error) {
+	var n yaml.Node
+	if err := yaml.Unmarshal(b, &n); err != nil {
+		return nil, err
+	}
+	if !maskNode(&n) {
+		return b, nil
+	}
+	return yaml.Marshal(&n)
+}
+
+// maskNode masks the secret values under n and reports whether any changed.
+func maskNode(n *yaml.Node) bool {
+	changed := false
+	if n.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, val := n.Content[i], n.Content[i+1]
+			if val.Kind == yaml.ScalarNode && secretKey(key.Value) {
+				if m := maskValue(val.Value); m != val.Value {
+					val.Value, val.Tag, val.Style = m, "!!str", 0
+					changed = true
+				}
+			}
+		}
+	}
+	for _, c := range n.Content {
+		if maskNode(c) {
+			changed = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,34 @@
+package configcmd
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestMaskYAML verifies that passwords and tokens are hidden at any depth
+// while secret references stay visible.
+func TestMaskYAML(t *testing.T) {
+	in := "db:\n    auth_lookup_password: hunter2\n    auth_lookup_user: pgb\n    environments:\n        stage:\n            discovery_auth:\n                token: s3cret\n                password: secret://stage-sd\n"
+	b, err := MaskYAML([]byte(in))
+	if err != nil {
+		t.Fatalf("MaskYAML error: %v", err)
+	}
+	out := string(b)
+	for _, leak := range []string{"hunter2", "s3cret"} {
+		if strings.Contains(out, leak) {
+			t.Fatalf("MaskYAML leaked %q:\n%s", leak, out)
+		}
+	}
+	for _, keep := range []string{"auth_lookup_user: pgb", "password: secret://stage-sd"} {
+		if !strings.Contains(out, keep) {
+			t.Fatalf("MaskYAML dropped %q:\n%s", keep, out)
+		}
+	}
+
+	if got := displayValue("db.auth_lookup_password", "hunter2"); got != maskedValue {
+		t.Fatalf("displayValue(password) = %q", got)
+	}
+	if got := displayValue("db.environments", "{stage: {auth_lookup_password: x}}"); strings.Contains(got, ": x") {
+		t.Fatalf("displayValue(environments) = %q", got)
+	}
+}
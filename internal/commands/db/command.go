@@ -2,7 +2,13 @@ package dbcmd
 
 import (
 	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
 
+	"wslbridge/internal/cli"
+	"wslbridge/internal/config"
 	"wslbridge/internal/db"
 	appruntime "wslbridge/internal/runtime"
 )
@@ -15,7 +21,7 @@ func (Command) Name() string { return "db" }
 
 // Help returns the command description.
 func (Command) Help() string {
-	return "Manage service-discovery-driven local DB proxy (init|start|status|stop|add|remove)"
+	return "Manage service-discovery-driven local DB proxy (init|start|status|stop|add|search|list-remote|endpoints|pin|unpin|env|remove|auto|route)"
 }
 
 // Run executes db command.
@@ -28,52 +34,294 @@ func (Command) Run(rt appruntime.Runtime, args []string) error {
 
 	switch args[0] {
 	case "init":
-		force := false
-		for _, a := range args[1:] {
-			switch a {
-			case "--force":
-				force = true
-			default:
-				return fmt.Errorf("unknown arg: %s", a)
-			}
+		force, answers, err := parsePromptFlags(args[1:], initFlags)
+		if err != nil {
+			return err
 		}
-		return svc.Init(force)
+		return svc.WithAnswers(answers).Init(force)
 	case "start":
-		force := false
-		for _, a := range args[1:] {
-			switch a {
-			case "--force":
-				force = true
-			default:
-				return fmt.Errorf("unknown arg: %s", a)
-			}
+		force, answers, err := parsePromptFlags(args[1:], startFlags)
+		if err != nil {
+			return err
 		}
-		return svc.Start(force)
-	case "status":
-		if len(args) > 1 {
-			return fmt.Errorf("unknown arg: %s", args[1])
+		if len(answers) > 0 && !force {
+			return fmt.Errorf("--port and --role need --force (they replace the saved values)")
 		}
-		return svc.Status()
+		return svc.WithAnswers(answers).Start(force)
+	case "status":
+		return runStatus(svc, args[1:])
 	case "stop":
 		if len(args) > 1 {
 			return fmt.Errorf("unknown arg: %s", args[1])
 		}
 		return svc.Stop()
 	case "add":
-		if len(args) > 2 {
-			return fmt.Errorf("too many args for add")
+		return runAdd(svc, args[1:])
+	case "search":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: db search <substring>")
 		}
-		service := ""
-		if len(args) == 2 {
-			service = args[1]
+		return svc.ListRemote(args[1])
+	case "list-remote":
+		if len(args) > 1 {
+			return fmt.Errorf("unknown arg: %s", args[1])
 		}
-		return svc.AddService(service)
+		return svc.ListRemote("")
+	case "endpoints":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: db endpoints <service>")
+		}
+		return svc.Endpoints(args[1])
+	case "pin":
+		return runPin(svc, args[1:])
+	case "unpin":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: db unpin <service>")
+		}
+		return svc.UnpinService(args[1])
+	case "env":
+		return runEnv(svc, args[1:])
 	case "remove", "rm", "delete":
 		if len(args) != 2 {
 			return fmt.Errorf("usage: db remove <service>")
 		}
 		return svc.RemoveService(args[1])
+	case "auto":
+		return runAuto(svc, args[1:])
+	case "route", "routes":
+		return runRoute(svc, args[1:])
+	default:
+		return fmt.Errorf("unknown action: %s (use: init | start | status | stop | add | search | list-remote | endpoints | pin | unpin | env | remove | auto | route)", args[0])
+	}
+}
+
+// initFlags answer the prompts of `db init`; startFlags those of
+// `db start --force`.
+var (
+	initFlags = []string{
+		"provider", "service-discovery", "dns-server", "discovery-file", "mask",
+		"fallback-hosts", "host-order", "auth", "token", "token-file", "token-command",
+		"auth-user", "password", "password-file", "client-cert", "client-key", "ca-file",
+		"port", "role",
+	}
+	startFlags = []string{"port", "role"}
+)
+
+// parsePromptFlags reads --force and `--<name>=<value>` (or `--<name>
+// <value>`) flags for the named prompts.
+func parsePromptFlags(args, names []string) (bool, map[string]string, error) {
+	force := false
+	answers := map[string]string{}
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--force" {
+			force = true
+			continue
+		}
+		name, val, hasVal := strings.Cut(strings.TrimPrefix(a, "--"), "=")
+		if !strings.HasPrefix(a, "--") || !slices.Contains(names, name) {
+			return false, nil, fmt.Errorf("unknown arg: %s (flags: --force, --%s)", a, strings.Join(names, ", --"))
+		}
+		if !hasVal {
+			if i+1 >= len(args) {
+				return false, nil, fmt.Errorf("%s requires a value", a)
+			}
+			i++
+			val = args[i]
+		}
+		answers[name] = val
+	}
+	return force, answers, nil
+}
+
+// runStatus prints `db status` as text or, with --output json|yaml, as a
+// db.StatusReport.
+func runStatus(svc db.Service, args []string) error {
+	format, rest, err := cli.OutputFlag(args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("unknown arg: %s", rest[0])
+	}
+	if format == cli.OutputText {
+		return svc.Status()
+	}
+	report, err := svc.StatusReport()
+	if err != nil {
+		return err
+	}
+	return cli.WriteOutput(os.Stdout, format, report)
+}
+
+func runAuto(svc db.Service, args []string) error {
+	if len(args) == 0 {
+		return svc.Status()
+	}
+
+	pattern := ""
+	for _, a := range args[1:] {
+		switch {
+		case strings.HasPrefix(a, "--pattern="):
+			// An explicit empty pattern resets it like `*`.
+			if pattern = strings.TrimPrefix(a, "--pattern="); strings.TrimSpace(pattern) == "" {
+				pattern = "*"
+			}
+		default:
+			return fmt.Errorf("unknown arg: %s", a)
+		}
+	}
+
+	switch args[0] {
+	case "on":
+		return svc.SetAutoResolve(true, pattern)
+	case "off":
+		if pattern != "" {
+			return fmt.Errorf("--pattern is only supported with `db auto on`")
+		}
+		return svc.SetAutoResolve(false, "")
+	default:
+		return fmt.Errorf("usage: db auto on [--pattern=<glob>[,<glob>...]|--pattern=] | db auto off")
+	}
+}
+
+func runAdd(svc db.Service, args []string) error {
+	service := ""
+	var selector db.EndpointSelector
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case strings.HasPrefix(a, "--release="):
+			selector.Release = strings.TrimPrefix(a, "--release=")
+		case strings.HasPrefix(a, "--version="):
+			selector.Version = strings.TrimPrefix(a, "--version=")
+		case (a == "--release" || a == "--version") && i+1 < len(args):
+			i++
+			if a == "--release" {
+				selector.Release = args[i]
+			} else {
+				selector.Version = args[i]
+			}
+		case strings.HasPrefix(a, "-"):
+			return fmt.Errorf("unknown arg: %s (usage: db add [<service>] [--release=<name>|any] [--version=<constraint>|any])", a)
+		case service == "":
+			service = a
+		default:
+			return fmt.Errorf("too many args for add")
+		}
+	}
+	return svc.AddServiceWithSelector(service, selector)
+}
+
+func runEnv(svc db.Service, args []string) error {
+	const usage = "usage: db env [list] | db env use <name> | db env remove <name>"
+	if len(args) == 0 {
+		return svc.ListEnvironments()
+	}
+	switch args[0] {
+	case "list", "ls":
+		if len(args) > 1 {
+			return fmt.Errorf("unknown arg: %s", args[1])
+		}
+		return svc.ListEnvironments()
+	case "use":
+		if len(args) != 2 {
+			return fmt.Errorf(usage)
+		}
+		return svc.UseEnvironment(args[1])
+	case "remove", "rm", "delete":
+		if len(args) != 2 {
+			return fmt.Errorf(usage)
+		}
+		return svc.RemoveEnvironment(args[1])
+	default:
+		return fmt.Errorf(usage)
+	}
+}
+
+func runPin(svc db.Service, args []string) error {
+	const usage = "usage: db pin <service> --instance <InstanceName> | db pin <service> --address <host:port>"
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return fmt.Errorf(usage)
+	}
+
+	service := args[0]
+	var instance, address string
+	for i := 1; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case strings.HasPrefix(a, "--instance="):
+			instance = strings.TrimPrefix(a, "--instance=")
+		case strings.HasPrefix(a, "--address="):
+			address = strings.TrimPrefix(a, "--address=")
+		case (a == "--instance" || a == "--address") && i+1 < len(args):
+			i++
+			if a == "--instance" {
+				instance = args[i]
+			} else {
+				address = args[i]
+			}
+		default:
+			return fmt.Errorf("unknown arg: %s (%s)", a, usage)
+		}
+	}
+	return svc.PinService(service, instance, address)
+}
+
+func runRoute(svc db.Service, args []string) error {
+	const usage = "usage: db route add [<database-pattern>] [--user=<pattern>] [--app=<pattern>] [--service=<name>] [--role=<role>] [--position=<n>] | db route remove <n|database-pattern> | db route list"
+	if len(args) == 0 {
+		return svc.ListRouteRules()
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		if len(args) > 1 {
+			return fmt.Errorf("unknown arg: %s", args[1])
+		}
+		return svc.ListRouteRules()
+	case "add":
+		var rule config.DBRouteRule
+		index := -1
+		for _, a := range args[1:] {
+			switch {
+			case strings.HasPrefix(a, "--database="):
+				rule.Database = strings.TrimPrefix(a, "--database=")
+			case strings.HasPrefix(a, "--user="):
+				rule.User = strings.TrimPrefix(a, "--user=")
+			case strings.HasPrefix(a, "--app="):
+				rule.ApplicationName = strings.TrimPrefix(a, "--app=")
+			case strings.HasPrefix(a, "--application-name="):
+				rule.ApplicationName = strings.TrimPrefix(a, "--application-name=")
+			case strings.HasPrefix(a, "--service="):
+				rule.Service = strings.TrimPrefix(a, "--service=")
+			case strings.HasPrefix(a, "--role="):
+				rule.Role = strings.TrimPrefix(a, "--role=")
+			case strings.HasPrefix(a, "--position="):
+				v := strings.TrimPrefix(a, "--position=")
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 1 {
+					return fmt.Errorf("invalid --position=%q (must be >= 1)", v)
+				}
+				index = n - 1
+			case strings.HasPrefix(a, "--"):
+				return fmt.Errorf("unknown arg: %s", a)
+			case rule.Database == "":
+				rule.Database = a
+			default:
+				return fmt.Errorf("too many args for route add")
+			}
+		}
+		if rule.Database == "" && rule.User == "" && rule.ApplicationName == "" {
+			return fmt.Errorf(usage)
+		}
+		return svc.AddRouteRule(rule, index)
+	case "remove", "rm", "delete":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: db route remove <n|database-pattern>")
+		}
+		return svc.RemoveRouteRule(args[1])
 	default:
-		return fmt.Errorf("unknown action: %s (use: init | start | status | stop | add | remove)", args[0])
+		return fmt.Errorf(usage)
 	}
 }
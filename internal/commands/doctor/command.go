@@ -0,0 +1,48 @@
+package doctorcmd
+
+import (
+	"fmt"
+	"os"
+
+	"wslbridge/internal/cli"
+	initubuntu "wslbridge/internal/commands/init-ubuntu"
+	"wslbridge/internal/db"
+	"wslbridge/internal/doctor"
+	appruntime "wslbridge/internal/runtime"
+)
+
+// Command runs the end-to-end checks of the bridge.
+type Command struct{}
+
+// Name returns the command name.
+func (Command) Name() string { return "doctor" }
+
+// Help returns the command description.
+func (Command) Help() string {
+	return "Diagnose the bridge end to end and suggest fixes"
+}
+
+// Run executes doctor command. It fails when any check fails, so scripts
+// can use its exit status.
+func (Command) Run(rt appruntime.Runtime, args []string) error {
+	format, rest, err := cli.OutputFlag(args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("unknown arg: %s", rest[0])
+	}
+
+	results := initubuntu.DoctorChecks(rt)
+	results = append(results, db.NewService(rt).DoctorChecks()...)
+
+	if format == cli.OutputText {
+		doctor.Write(os.Stdout, results)
+	} else if err := cli.WriteOutput(os.Stdout, format, results); err != nil {
+		return err
+	}
+	if n := doctor.Failed(results); n > 0 {
+		return fmt.Errorf("%d checks failed", n)
+	}
+	return nil
+}
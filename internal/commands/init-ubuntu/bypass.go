@@ -0,0 +1,110 @@
+package init_ubuntu
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"wslbridge/internal/config"
+	appruntime "wslbridge/internal/runtime"
+)
+
+// bypassMetric sets the bypass routes apart from kernel and user routes of
+// the same prefix, so stop deletes only the routes init added.
+const bypassMetric = "50"
+
+// bypassRoutes returns the routes that keep the SOCKS gateway, subnet (the
+// IPv4 network of the original device, empty when unknown) and tun.bypass
+// off the tun device, as `ip route` arguments. Ranges inside subnet are
+// routed on-link; the others go via the gateway of origRoute. Include mode
+// keeps the default route, so it needs none.
+func bypassRoutes(cfg config.Config, origRoute, subnet string) ([]string, error) {
+	if tunMode(cfg) == tunModeInclude {
+		return nil, nil
+	}
+	dev := routeDev(origRoute)
+	if dev == "" {
+		dev = "eth0"
+	}
+
+	var cidrs []string
+	seen := map[string]bool{}
+	add := func(cidr string) {
+		if !seen[cidr] {
+			seen[cidr] = true
+			cidrs = append(cidrs, cidr)
+		}
+	}
+	if ip := net.ParseIP(strings.TrimSpace(cfg.Socks.Host)); ip != nil && ip.To4() != nil {
+		add(ip.To4().String() + "/32")
+	}
+	if subnet != "" {
+		add(subnet)
+	}
+	for _, r := range cfg.Tun.Bypass {
+		cidr, err := normalizeRouteCIDR(r)
+		if err != nil {
+			return nil, fmt.Errorf("tun.bypass %q: %w", r, err)
+		}
+		add(cidr)
+	}
+
+	gw, hasGW := parseViaIP(origRoute)
+	routes := make([]string, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		switch {
+		case subnet != "" && cidrWithin(cidr, subnet):
+			routes = append(routes, fmt.Sprintf("%s dev %s metric %s", cidr, dev, bypassMetric))
+		case hasGW:
+			routes = append(routes, fmt.Sprintf("%s via %s dev %s metric %s", cidr, gw, dev, bypassMetric))
+		default:
+			return nil, fmt.Errorf("no gateway in the saved default route %q to route %s around %s", origRoute, cidr, cfg.Tun.Dev)
+		}
+	}
+	return routes, nil
+}
+
+// cidrWithin reports whether the network cidr lies inside the network outer.
+func cidrWithin(cidr, outer string) bool {
+	_, in, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return false
+	}
+	_, out, err := net.ParseCIDR(outer)
+	if err != nil {
+		return false
+	}
+	inOnes, inBits := in.Mask.Size()
+	outOnes, outBits := out.Mask.Size()
+	return inBits == outBits && inOnes >= outOnes && out.Contains(in.IP)
+}
+
+// routeDev returns the device of a route line.
+func routeDev(routeLine string) string {
+	fields := strings.Fields(routeLine)
+	for i := 0; i < len(fields)-1; i++ {
+		if fields[i] == "dev" {
+			return fields[i+1]
+		}
+	}
+	return ""
+}
+
+// linkSubnet returns the IPv4 network of dev, e.g. 172.27.16.0/20.
+func linkSubnet(rt appruntime.Runtime, dev string) (string, error) {
+	out, err := rt.Runner.RunCapture("ip", "-4", "-o", "addr", "show", "dev", dev)
+	if err != nil {
+		return "", fmt.Errorf("read %s addr: %w", dev, err)
+	}
+	fields := strings.Fields(out)
+	for i := 0; i < len(fields)-1; i++ {
+		if fields[i] == "inet" {
+			_, ipnet, err := net.ParseCIDR(fields[i+1])
+			if err != nil {
+				return "", fmt.Errorf("parse %s cidr %q: %w", dev, fields[i+1], err)
+			}
+			return ipnet.String(), nil
+		}
+	}
+	return "", fmt.Errorf("%s has no IPv4 address", dev)
+}
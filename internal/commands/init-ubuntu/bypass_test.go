@@ -0,0 +1,57 @@
+package init_ubuntu
+
+import (
+	"reflect"
+	"testing"
+
+	"wslbridge/internal/config"
+	appruntime "wslbridge/internal/runtime"
+)
+
+// TestBypassRoutes validates the on-link and gateway routes around the tun
+// device.
+func TestBypassRoutes(t *testing.T) {
+	cfg := config.Config{
+		Socks: config.SocksConfig{Host: "172.27.16.1", Port: 1080},
+		Tun:   config.TunConfig{Dev: "tun0", Bypass: []string{"192.168.1.7/24", "172.27.20.0/24"}},
+	}
+	orig := "default via 172.27.16.1 dev eth0 proto kernel"
+
+	got, err := bypassRoutes(cfg, orig, "172.27.16.0/20")
+	if err != nil {
+		t.Fatalf("bypassRoutes: %v", err)
+	}
+	want := []string{
+		"172.27.16.1/32 dev eth0 metric 50",
+		"172.27.16.0/20 dev eth0 metric 50",
+		"192.168.1.0/24 via 172.27.16.1 dev eth0 metric 50",
+		"172.27.20.0/24 dev eth0 metric 50",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("routes = %q, want %q", got, want)
+	}
+
+	if _, err := bypassRoutes(cfg, "default dev tun0", ""); err == nil {
+		t.Fatalf("routes outside the subnet need a gateway")
+	}
+	cfg.Tun.Bypass = []string{"not-a-cidr"}
+	if _, err := bypassRoutes(cfg, orig, ""); err == nil {
+		t.Fatalf("invalid tun.bypass must fail")
+	}
+	cfg.Tun.Mode = "include"
+	if got, err := bypassRoutes(cfg, orig, ""); err != nil || got != nil {
+		t.Fatalf("include mode = %q, %v", got, err)
+	}
+}
+
+func TestLinkSubnet(t *testing.T) {
+	r := &logRunner{out: "2: eth0    inet 172.27.21.90/20 brd 172.27.31.255 scope global eth0\\       valid_lft forever preferred_lft forever\n"}
+	got, err := linkSubnet(appruntime.Runtime{Runner: r}, "eth0")
+	if err != nil || got != "172.27.16.0/20" {
+		t.Fatalf("linkSubnet = %q, %v", got, err)
+	}
+	r.out = ""
+	if _, err := linkSubnet(appruntime.Runtime{Runner: r}, "eth0"); err == nil {
+		t.Fatalf("expected error without an address")
+	}
+}
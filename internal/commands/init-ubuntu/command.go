@@ -1,7 +1,6 @@
 package init_ubuntu
 
 import (
-	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -36,6 +35,7 @@ type flags struct {
 	skipDeps          bool
 	force             bool
 	socksPortOverride int
+	dns               string
 }
 
 func logStep(msg string) {
@@ -57,6 +57,11 @@ func parseFlags(args []string) (flags, error) {
 				return flags{}, fmt.Errorf("invalid --socks-port=%q (must be 1..65535)", v)
 			}
 			f.socksPortOverride = n
+		case strings.HasPrefix(a, "--dns="):
+			f.dns = strings.TrimPrefix(a, "--dns=")
+			if err := cli.ValidateIP(f.dns); err != nil {
+				return flags{}, fmt.Errorf("invalid --dns=%q: %w", f.dns, err)
+			}
 		default:
 			return flags{}, fmt.Errorf("unknown arg: %s", a)
 		}
@@ -96,13 +101,28 @@ func ensureDeps(rt appruntime.Runtime, skip bool) error {
 	return rt.Platform.EnsureDeps(rt.Runner)
 }
 
-func loadConfig(path string) (config.Config, bool, error) {
-	if c, err := config.Load(path); err == nil {
-		return c, true, nil
-	} else if !errors.Is(err, os.ErrNotExist) {
+func loadConfig(rt appruntime.Runtime) (config.Config, bool, error) {
+	res, err := rt.ResolveConfig(config.Config{})
+	if err != nil {
 		return config.Config{}, false, err
 	}
-	return config.Config{}, false, nil
+	return res.Config, res.Exists, nil
+}
+
+func saveConfig(rt appruntime.Runtime, cfg config.Config) error {
+	res, err := rt.ResolveConfig(config.Config{})
+	if err != nil {
+		return err
+	}
+	return res.Save(cfg)
+}
+
+// DefaultConfig returns the tun values init fills in when a config leaves
+// them empty.
+func DefaultConfig() config.Config {
+	var cfg config.Config
+	applyDefaults(&cfg)
+	return cfg
 }
 
 func applyDefaults(cfg *config.Config) {
@@ -114,8 +134,18 @@ func applyDefaults(cfg *config.Config) {
 	}
 }
 
-func alreadyEnabled(defaultRouteLine string, cfg config.Config, pidFile string) bool {
-	if defaultIsTun(defaultRouteLine, cfg.Tun.Dev) && tun2socks.IsRunning(pidFile) {
+func alreadyEnabled(rt appruntime.Runtime, defaultRouteLine string, cfg config.Config) bool {
+	if !tun2socks.IsRunning(rt.Paths.Tun2SocksPIDFile) {
+		return false
+	}
+	if tunMode(cfg) == tunModeInclude {
+		if routes := readRoutes(rt.Paths.TunRoutesFile); len(routes) > 0 && !defaultIsTun(defaultRouteLine, cfg.Tun.Dev) {
+			fmt.Println("already enabled:", len(routes), "routes are on", cfg.Tun.Dev, "and tun2socks is running")
+			return true
+		}
+		return false
+	}
+	if defaultIsTun(defaultRouteLine, cfg.Tun.Dev) {
 		fmt.Println("already enabled: default route is on", cfg.Tun.Dev, "and tun2socks is running")
 		return true
 	}
@@ -123,21 +153,31 @@ func alreadyEnabled(defaultRouteLine string, cfg config.Config, pidFile string)
 }
 
 func ensureSudo(rt appruntime.Runtime) error {
+	if rt.NonInteractive {
+		if err := rt.Runner.Run("sudo", "-n", "-v"); err != nil {
+			return fmt.Errorf("sudo needs a password, which cannot be asked for in non-interactive mode (configure passwordless sudo): %w", err)
+		}
+		return nil
+	}
 	if err := rt.Runner.Run("sudo", "-v"); err != nil {
 		return fmt.Errorf("sudo auth failed: %w", err)
 	}
 	return nil
 }
 
-func configureWSL(rt appruntime.Runtime, cfg *config.Config, hasCfg bool) error {
-	pr := cli.NewPrompter(os.Stdin, os.Stdout)
+func configureWSL(rt appruntime.Runtime, cfg *config.Config, hasCfg bool, f flags) error {
+	var answers map[string]string
+	if f.dns != "" {
+		answers = map[string]string{"dns": f.dns}
+	}
+	pr := rt.Prompter(answers)
 
 	curDNS := ""
 	if hasCfg && cfg.DNS.Nameserver != "" {
 		curDNS = cfg.DNS.Nameserver
 	}
 
-	dns, err := pr.AskString("DNS nameserver (WSL)", "", curDNS, cli.ValidateIP)
+	dns, err := pr.Ask("dns", "DNS nameserver (WSL)", "", curDNS, cli.ValidateIP)
 	if err != nil {
 		return err
 	}
@@ -160,7 +200,7 @@ func saveDefaultRoute(rt appruntime.Runtime, defaultRouteLine string) {
 	}
 }
 
-func resolveSocksPort(cfg config.Config, hasCfg bool, f flags) (int, error) {
+func resolveSocksPort(rt appruntime.Runtime, cfg config.Config, hasCfg bool, f flags) (int, error) {
 	socksPort := defaultSocksPort
 	if hasCfg && cfg.Socks.Port != 0 {
 		socksPort = cfg.Socks.Port
@@ -172,12 +212,12 @@ func resolveSocksPort(cfg config.Config, hasCfg bool, f flags) (int, error) {
 		return socksPort, nil
 	}
 
-	pr := cli.NewPrompter(os.Stdin, os.Stdout)
+	pr := rt.Prompter(nil)
 	cur := ""
 	if hasCfg && cfg.Socks.Port != 0 {
 		cur = strconv.Itoa(cfg.Socks.Port)
 	}
-	portStr, perr := pr.AskString("SOCKS port", strconv.Itoa(defaultSocksPort), cur, cli.ValidatePort)
+	portStr, perr := pr.Ask("socks-port", "SOCKS port", strconv.Itoa(defaultSocksPort), cur, cli.ValidatePort)
 	if perr != nil {
 		return 0, perr
 	}
@@ -0,0 +1,202 @@
+package init_ubuntu
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"wslbridge/internal/config"
+	"wslbridge/internal/doctor"
+	appruntime "wslbridge/internal/runtime"
+	"wslbridge/internal/socks"
+	"wslbridge/internal/tun2socks"
+)
+
+const doctorTimeout = 3 * time.Second
+
+// doctorProbeHost is resolved and connected to through the SOCKS proxy when
+// no service discovery host is configured. tun2socks is installed from it.
+const doctorProbeHost = "github.com"
+
+// DoctorChecks diagnoses the WSL side of the bridge: sudo, the tun device,
+// the default route, tun2socks, the SOCKS endpoint and DNS.
+func DoctorChecks(rt appruntime.Runtime) []doctor.Result {
+	out := []doctor.Result{checkWSL(), checkSudo()}
+
+	cfg, _, err := loadConfig(rt)
+	if err != nil {
+		return append(out, doctor.Problem("config", doctor.Fail, err.Error(),
+			"fix the file or run `wslbridge config edit`"))
+	}
+	applyDefaults(&cfg)
+
+	out = append(out, checkTunDevice(cfg.Tun.Dev), checkDefaultRoute(rt, cfg), checkTun2socksBin())
+	socksTCP := checkSocksTCP(cfg)
+	out = append(out, socksTCP)
+	if socksTCP.Status == doctor.OK {
+		out = append(out, checkSocksHandshake(cfg))
+	} else {
+		out = append(out, doctor.Problem("SOCKS5 handshake", doctor.Skip, "SOCKS endpoint is not reachable", ""))
+	}
+	return append(out, checkDNS(cfg))
+}
+
+func checkWSL() doctor.Result {
+	if !isWSL() {
+		return doctor.Problem("WSL", doctor.Fail, "not running under WSL (/proc/version has no \"microsoft\")",
+			"run wslbridge inside an Ubuntu WSL distribution")
+	}
+	return doctor.Pass("WSL", "detected")
+}
+
+func checkSudo() doctor.Result {
+	if _, err := exec.LookPath("sudo"); err != nil {
+		return doctor.Problem("sudo", doctor.Fail, "sudo is not installed",
+			"install it: `apt install sudo` as root")
+	}
+	if err := exec.Command("sudo", "-n", "true").Run(); err != nil {
+		return doctor.Problem("sudo", doctor.Warn, "sudo asks for a password",
+			"run `sudo -v` before `wslbridge init`; --non-interactive needs passwordless sudo")
+	}
+	return doctor.Pass("sudo", "available without a password")
+}
+
+func checkTunDevice(dev string) doctor.Result {
+	if !linkExists(dev) {
+		return doctor.Problem("tun device", doctor.Fail, dev+" does not exist",
+			"run `wslbridge init` to create it")
+	}
+	return doctor.Pass("tun device", dev+" exists")
+}
+
+func checkDefaultRoute(rt appruntime.Runtime, cfg config.Config) doctor.Result {
+	dev := cfg.Tun.Dev
+	line, err := getDefaultRouteLine(rt)
+	if err == nil && tunMode(cfg) == tunModeInclude {
+		routes := readRoutes(rt.Paths.TunRoutesFile)
+		switch {
+		case defaultIsTun(line, dev):
+			return doctor.Problem("default route", doctor.Warn, "on "+dev+" although tun.mode is include",
+				"run `wslbridge stop` and `wslbridge init` to restore it")
+		case len(routes) == 0:
+			return doctor.Problem("default route", doctor.Fail, "no split tunnel routes on "+dev,
+				"run `wslbridge init` to add tun.routes and tun.domains")
+		}
+		return doctor.Pass("default route", fmt.Sprintf("kept; %d split tunnel routes via %s", len(routes), dev))
+	}
+	switch {
+	case err != nil:
+		return doctor.Problem("default route", doctor.Fail, err.Error(), "check that iproute2 is installed")
+	case strings.TrimSpace(line) == "":
+		return doctor.Problem("default route", doctor.Fail, "no default route",
+			"run `wslbridge init`, or `wsl --shutdown` from Windows to reset WSL networking")
+	case !defaultIsTun(line, dev):
+		return doctor.Problem("default route", doctor.Fail, fmt.Sprintf("owned by %q, not %s", line, dev),
+			"run `wslbridge init` to route traffic through "+dev)
+	}
+	return doctor.Pass("default route", "via "+dev)
+}
+
+func checkTun2socksBin() doctor.Result {
+	bin, err := tun2socks.FindBin()
+	if err != nil {
+		return doctor.Problem("tun2socks binary", doctor.Fail, err.Error(),
+			"run `wslbridge init`, which installs it with `go install`")
+	}
+	version, err := tun2socks.Version(bin)
+	if err != nil {
+		return doctor.Problem("tun2socks binary", doctor.Warn, err.Error(),
+			"reinstall it: remove "+bin+" and run `wslbridge init`")
+	}
+	return doctor.Pass("tun2socks binary", fmt.Sprintf("%s (%s)", bin, version))
+}
+
+func socksAddr(cfg config.Config) string {
+	return net.JoinHostPort(cfg.Socks.Host, strconv.Itoa(cfg.Socks.Port))
+}
+
+func checkSocksTCP(cfg config.Config) doctor.Result {
+	if cfg.Socks.Host == "" || cfg.Socks.Port == 0 {
+		return doctor.Problem("SOCKS endpoint", doctor.Fail, "not configured",
+			"run `wslbridge init`, or `wslbridge config set socks.host <ip>` and `wslbridge config set socks.port <port>`")
+	}
+	conn, err := net.DialTimeout("tcp", socksAddr(cfg), doctorTimeout)
+	if err != nil {
+		return doctor.Problem("SOCKS endpoint", doctor.Fail, err.Error(),
+			"start the SOCKS proxy on Windows, make it listen on the WSL interface and allow it in the Windows firewall")
+	}
+	conn.Close()
+	return doctor.Pass("SOCKS endpoint", socksAddr(cfg)+" accepts TCP connections")
+}
+
+func checkSocksHandshake(cfg config.Config) doctor.Result {
+	host, port := doctorProbe(cfg)
+	target := net.JoinHostPort(host, port)
+	conn, err := socks.Dial(socksAddr(cfg), target, doctorTimeout)
+	if err != nil {
+		hint := "make sure the proxy speaks SOCKS5 without authentication"
+		if strings.Contains(err.Error(), "socks5 connect") {
+			hint = "the proxy rejected " + target + "; check its upstream connectivity and rules"
+		}
+		return doctor.Problem("SOCKS5 handshake", doctor.Fail, err.Error(), hint)
+	}
+	conn.Close()
+	return doctor.Pass("SOCKS5 handshake", "connected to "+target+" through the proxy")
+}
+
+func checkDNS(cfg config.Config) doctor.Result {
+	ns := cfg.DNS.Nameserver
+	if ns == "" {
+		var ok bool
+		if ns, ok = readResolvConfNameserver("/etc/resolv.conf"); !ok {
+			return doctor.Problem("DNS", doctor.Fail, "no nameserver configured",
+				"run `wslbridge init --dns=<ip>`")
+		}
+	}
+	server := net.JoinHostPort(ns, "53")
+	r := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+	defer cancel()
+
+	host, _ := doctorProbe(cfg)
+	if net.ParseIP(host) != nil {
+		host = doctorProbeHost
+	}
+	addrs, err := r.LookupHost(ctx, host)
+	if err != nil {
+		return doctor.Problem("DNS", doctor.Fail, fmt.Sprintf("%s via %s: %v", host, ns, err),
+			"check the nameserver with `wslbridge config get dns.nameserver` and rerun `wslbridge init --dns=<ip>`")
+	}
+	return doctor.Pass("DNS", fmt.Sprintf("%s resolves to %s via %s", host, strings.Join(addrs, ", "), ns))
+}
+
+// doctorProbe returns the host and port that the SOCKS and DNS checks
+// reach: the HTTP or Consul service discovery host when one is configured.
+func doctorProbe(cfg config.Config) (string, string) {
+	raw := strings.TrimSpace(cfg.DB.ServiceDiscoveryHost)
+	switch strings.ToLower(cfg.DB.DiscoveryProvider) {
+	case "", "http", "consul":
+	default:
+		raw = ""
+	}
+	if raw == "" {
+		return doctorProbeHost, "443"
+	}
+	if host, port, err := net.SplitHostPort(raw); err == nil {
+		return host, port
+	}
+	if cfg.DB.ServiceDiscoveryScheme == "https" {
+		return raw, "443"
+	}
+	return raw, "80"
+}
@@ -0,0 +1,27 @@
+package init_ubuntu
+
+import (
+	"testing"
+
+	"wslbridge/internal/config"
+)
+
+// TestDoctorProbe validates the host picked for the SOCKS and DNS checks.
+func TestDoctorProbe(t *testing.T) {
+	cases := []struct {
+		db         config.DBConfig
+		host, port string
+	}{
+		{config.DBConfig{}, doctorProbeHost, "443"},
+		{config.DBConfig{ServiceDiscoveryHost: "sd.example.com"}, "sd.example.com", "80"},
+		{config.DBConfig{ServiceDiscoveryHost: "sd.example.com", ServiceDiscoveryScheme: "https"}, "sd.example.com", "443"},
+		{config.DBConfig{ServiceDiscoveryHost: "consul.local:8500", DiscoveryProvider: "consul"}, "consul.local", "8500"},
+		{config.DBConfig{ServiceDiscoveryHost: "10.0.0.53", DiscoveryProvider: "dns"}, doctorProbeHost, "443"},
+	}
+	for _, tc := range cases {
+		host, port := doctorProbe(config.Config{DB: tc.db})
+		if host != tc.host || port != tc.port {
+			t.Fatalf("doctorProbe(%+v) = %s:%s, want %s:%s", tc.db, host, port, tc.host, tc.port)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package init_ubuntu
 import (
 	"fmt"
 	"io"
+	"net"
 	"os/exec"
 	"strings"
 
@@ -28,7 +29,44 @@ func defaultIsTun(routeLine string, tunDev string) bool {
 	return strings.Contains(routeLine, "default") && strings.Contains(routeLine, "dev "+tunDev)
 }
 
-func setupTunAndRoutes(rt appruntime.Runtime, cfg config.Config) error {
+// setupTunAndRoutes brings the tun device up and routes traffic through it
+// as tun.mode says. defaultRouteLine is the current default route.
+func setupTunAndRoutes(rt appruntime.Runtime, cfg config.Config, defaultRouteLine string) error {
+	orig := originalDefaultRoute(rt, defaultRouteLine, cfg.Tun.Dev)
+	routes, err := splitRoutes(cfg, orig, net.LookupIP)
+	if err != nil {
+		return err
+	}
+	subnet := ""
+	if dev := routeDev(orig); dev != "" {
+		subnet, _ = linkSubnet(rt, dev)
+	}
+	bypass, err := bypassRoutes(cfg, orig, subnet)
+	if err != nil {
+		return err
+	}
+	if err := setupTun(rt, cfg); err != nil {
+		return err
+	}
+	if err := installRoutes(rt, rt.Paths.TunRoutesFile, routes); err != nil {
+		return err
+	}
+	// Bypass routes go in before the default route moves, so traffic to
+	// the SOCKS gateway never loops back into the tunnel.
+	if err := installRoutes(rt, rt.Paths.TunBypassFile, bypass); err != nil {
+		return err
+	}
+	if tunMode(cfg) != tunModeInclude {
+		return routeDefaultViaTun(rt, cfg.Tun.Dev)
+	}
+	if defaultIsTun(defaultRouteLine, cfg.Tun.Dev) && orig != "" {
+		return restoreDefaultRoute(rt, orig)
+	}
+	return nil
+}
+
+// setupTun creates the tun device if needed and brings it up.
+func setupTun(rt appruntime.Runtime, cfg config.Config) error {
 	if !linkExists(cfg.Tun.Dev) {
 		if err := rt.Runner.Run("sudo", "ip", "tuntap", "add", "mode", "tun", "dev", cfg.Tun.Dev); err != nil {
 			return fmt.Errorf("ip tuntap add %s: %w", cfg.Tun.Dev, err)
@@ -41,9 +79,12 @@ func setupTunAndRoutes(rt appruntime.Runtime, cfg config.Config) error {
 	if err := rt.Runner.Run("sudo", "ip", "link", "set", cfg.Tun.Dev, "up"); err != nil {
 		return fmt.Errorf("ip link set %s up: %w", cfg.Tun.Dev, err)
 	}
+	return nil
+}
 
-	if err := rt.Runner.Run("sudo", "ip", "route", "replace", "default", "dev", cfg.Tun.Dev); err != nil {
-		return fmt.Errorf("ip route replace default dev %s: %w", cfg.Tun.Dev, err)
+func routeDefaultViaTun(rt appruntime.Runtime, dev string) error {
+	if err := rt.Runner.Run("sudo", "ip", "route", "replace", "default", "dev", dev); err != nil {
+		return fmt.Errorf("ip route replace default dev %s: %w", dev, err)
 	}
 	return nil
 }
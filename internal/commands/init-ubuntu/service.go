@@ -24,7 +24,7 @@ func (s *initService) run() error {
 		return err
 	}
 
-	if alreadyEnabled(s.defaultRouteLine, s.cfg, s.rt.Paths.Tun2SocksPIDFile) {
+	if alreadyEnabled(s.rt, s.defaultRouteLine, s.cfg) {
 		return nil
 	}
 
@@ -43,7 +43,7 @@ func (s *initService) prepare() error {
 		return err
 	}
 
-	cfg, hasCfg, err := loadConfig(s.rt.Paths.ConfigPath)
+	cfg, hasCfg, err := loadConfig(s.rt)
 	if err != nil {
 		return err
 	}
@@ -65,12 +65,16 @@ func (s *initService) configureNetworkInputs() error {
 	}
 
 	if isWSL() {
-		if err := configureWSL(s.rt, &s.cfg, s.hasCfg); err != nil {
+		if err := configureWSL(s.rt, &s.cfg, s.hasCfg, s.flags); err != nil {
 			return err
 		}
 	}
 
-	saveDefaultRoute(s.rt, s.defaultRouteLine)
+	// A default route already on the tun device (tun2socks died since the
+	// last init) would overwrite the original gateway the bypass routes use.
+	if !defaultIsTun(s.defaultRouteLine, s.cfg.Tun.Dev) {
+		saveDefaultRoute(s.rt, s.defaultRouteLine)
+	}
 
 	logStep("Detecting SOCKS gateway")
 	gw, err := detectSocksGateway(s.rt, s.cfg, s.defaultRouteLine)
@@ -80,14 +84,14 @@ func (s *initService) configureNetworkInputs() error {
 	s.cfg.Socks.Host = gw
 	fmt.Println("SOCKS gateway:", s.cfg.Socks.Host)
 
-	socksPort, err := resolveSocksPort(s.cfg, s.hasCfg, s.flags)
+	socksPort, err := resolveSocksPort(s.rt, s.cfg, s.hasCfg, s.flags)
 	if err != nil {
 		return err
 	}
 	s.cfg.Socks.Port = socksPort
 
 	logStep("Saving configuration")
-	if err := config.Save(s.rt.Paths.ConfigPath, s.cfg); err != nil {
+	if err := saveConfig(s.rt, s.cfg); err != nil {
 		return err
 	}
 	fmt.Println("saved config:", s.rt.Paths.ConfigPath)
@@ -105,7 +109,7 @@ func (s *initService) enableTrafficBridge() error {
 	fmt.Println("tun2socks:", s.tun2socksBin)
 
 	logStep("Configuring tun interface and default route")
-	if err := setupTunAndRoutes(s.rt, s.cfg); err != nil {
+	if err := setupTunAndRoutes(s.rt, s.cfg, s.defaultRouteLine); err != nil {
 		return err
 	}
 
@@ -0,0 +1,206 @@
+package init_ubuntu
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"wslbridge/internal/config"
+	appruntime "wslbridge/internal/runtime"
+)
+
+// Tun routing modes of config.TunConfig.Mode.
+const (
+	tunModeFull    = "full"
+	tunModeInclude = "include"
+	tunModeExclude = "exclude"
+)
+
+func tunMode(cfg config.Config) string {
+	if m := strings.ToLower(strings.TrimSpace(cfg.Tun.Mode)); m != "" {
+		return m
+	}
+	return tunModeFull
+}
+
+// ValidateTunMode validates a tun.mode value.
+func ValidateTunMode(s string) error {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", tunModeFull, tunModeInclude, tunModeExclude:
+		return nil
+	default:
+		return fmt.Errorf("must be one of: full, include, exclude")
+	}
+}
+
+// ValidateRoute validates a tun.routes entry.
+func ValidateRoute(s string) error {
+	_, err := normalizeRouteCIDR(s)
+	return err
+}
+
+// normalizeRouteCIDR turns an IP or CIDR into the network `ip route`
+// accepts, e.g. 10.1.2.3/8 into 10.0.0.0/8 and 10.1.2.3 into 10.1.2.3/32.
+func normalizeRouteCIDR(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if ip := net.ParseIP(s); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4.String() + "/32", nil
+		}
+		return ip.String() + "/128", nil
+	}
+	_, ipnet, err := net.ParseCIDR(s)
+	if err != nil {
+		return "", fmt.Errorf("must be an IP or a CIDR such as 10.0.0.0/8")
+	}
+	return ipnet.String(), nil
+}
+
+// splitCIDRs returns the routes of cfg followed by the IPv4 addresses of
+// its domains, without duplicates.
+func splitCIDRs(cfg config.Config, lookup func(string) ([]net.IP, error)) ([]string, error) {
+	var out []string
+	seen := map[string]bool{}
+	add := func(cidr string) {
+		if !seen[cidr] {
+			seen[cidr] = true
+			out = append(out, cidr)
+		}
+	}
+	for _, r := range cfg.Tun.Routes {
+		cidr, err := normalizeRouteCIDR(r)
+		if err != nil {
+			return nil, fmt.Errorf("tun.routes %q: %w", r, err)
+		}
+		add(cidr)
+	}
+	for _, d := range cfg.Tun.Domains {
+		ips, err := lookup(strings.TrimSpace(d))
+		if err != nil {
+			return nil, fmt.Errorf("resolve tun.domains %q: %w", d, err)
+		}
+		n := 0
+		for _, ip := range ips {
+			if ip4 := ip.To4(); ip4 != nil {
+				add(ip4.String() + "/32")
+				n++
+			}
+		}
+		if n == 0 {
+			return nil, fmt.Errorf("resolve tun.domains %q: no IPv4 addresses", d)
+		}
+	}
+	return out, nil
+}
+
+// splitRoutes returns the routes the mode of cfg needs, as `ip route`
+// arguments: via the tun device in include mode and via the original
+// default route (gateway and device of origRoute) in exclude mode.
+func splitRoutes(cfg config.Config, origRoute string, lookup func(string) ([]net.IP, error)) ([]string, error) {
+	mode := tunMode(cfg)
+	if mode == tunModeFull {
+		return nil, nil
+	}
+	cidrs, err := splitCIDRs(cfg, lookup)
+	if err != nil {
+		return nil, err
+	}
+	if len(cidrs) == 0 {
+		return nil, fmt.Errorf("tun.mode %s needs tun.routes or tun.domains", mode)
+	}
+
+	nextHop := "dev " + cfg.Tun.Dev
+	if mode == tunModeExclude {
+		if nextHop, err = originalNextHop(origRoute); err != nil {
+			return nil, err
+		}
+	}
+	routes := make([]string, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		routes = append(routes, cidr+" "+nextHop)
+	}
+	return routes, nil
+}
+
+// originalNextHop returns the `via <gw> dev <dev>` part of a default
+// route line.
+func originalNextHop(routeLine string) (string, error) {
+	var hop []string
+	fields := strings.Fields(routeLine)
+	for i := 0; i < len(fields)-1; i++ {
+		if fields[i] == "via" || fields[i] == "dev" {
+			hop = append(hop, fields[i], fields[i+1])
+		}
+	}
+	if len(hop) == 0 {
+		return "", fmt.Errorf("no original default route to send excluded routes to (got %q)", routeLine)
+	}
+	return strings.Join(hop, " "), nil
+}
+
+// originalDefaultRoute returns the default route before init moved it to
+// the tun device.
+func originalDefaultRoute(rt appruntime.Runtime, current, tunDev string) string {
+	if current != "" && !defaultIsTun(current, tunDev) {
+		return current
+	}
+	b, err := os.ReadFile(rt.Paths.DefaultRouteFile)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// installRoutes replaces the routes recorded in file with routes and
+// records them, so stop removes exactly what init added.
+func installRoutes(rt appruntime.Runtime, file string, routes []string) error {
+	removeRoutes(rt, file)
+	if len(routes) == 0 {
+		return nil
+	}
+	if err := os.WriteFile(file, []byte(strings.Join(routes, "\n")+"\n"), 0o644); err != nil {
+		return err
+	}
+	return replayRoutes(rt, file)
+}
+
+// replayRoutes adds the routes recorded in file again.
+func replayRoutes(rt appruntime.Runtime, file string) error {
+	for _, r := range readRoutes(file) {
+		args := append([]string{"ip", "route", "replace"}, strings.Fields(r)...)
+		if err := rt.Runner.Run("sudo", args...); err != nil {
+			return fmt.Errorf("ip route replace %s: %w", r, err)
+		}
+	}
+	return nil
+}
+
+// deleteRoutes deletes the routes recorded in file and keeps the record.
+// Routes that are already gone are skipped.
+func deleteRoutes(rt appruntime.Runtime, file string) {
+	for _, r := range readRoutes(file) {
+		args := append([]string{"ip", "route", "del"}, strings.Fields(r)...)
+		_ = rt.Runner.Run("sudo", args...)
+	}
+}
+
+// removeRoutes deletes the routes recorded in file and the record.
+func removeRoutes(rt appruntime.Runtime, file string) {
+	deleteRoutes(rt, file)
+	_ = os.Remove(file)
+}
+
+func readRoutes(path string) []string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for _, line := range strings.Split(string(b), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			out = append(out, line)
+		}
+	}
+	return out
+}
@@ -0,0 +1,110 @@
+package init_ubuntu
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"wslbridge/internal/config"
+	appruntime "wslbridge/internal/runtime"
+)
+
+// logRunner records every Run call; RunCapture returns out.
+type logRunner struct {
+	cmds []string
+	out  string
+}
+
+func (r *logRunner) Run(name string, args ...string) error {
+	r.cmds = append(r.cmds, name+" "+strings.Join(args, " "))
+	return nil
+}
+
+func (r *logRunner) RunCapture(name string, args ...string) (string, error) {
+	return r.out, nil
+}
+
+func fakeLookup(host string) ([]net.IP, error) {
+	if host == "git.example.internal" {
+		return []net.IP{net.ParseIP("10.9.0.7"), net.ParseIP("fd00::7"), net.ParseIP("10.20.1.1")}, nil
+	}
+	return nil, fmt.Errorf("no such host")
+}
+
+// TestSplitRoutes validates the routes of the include and exclude modes.
+func TestSplitRoutes(t *testing.T) {
+	cfg := config.Config{Tun: config.TunConfig{
+		Dev:     "tun0",
+		Mode:    "include",
+		Routes:  []string{"10.20.1.1/16", "192.168.7.10"},
+		Domains: []string{"git.example.internal"},
+	}}
+	orig := "default via 172.27.16.1 dev eth0 proto kernel"
+
+	got, err := splitRoutes(cfg, orig, fakeLookup)
+	if err != nil {
+		t.Fatalf("include: %v", err)
+	}
+	want := []string{"10.20.0.0/16 dev tun0", "192.168.7.10/32 dev tun0", "10.9.0.7/32 dev tun0", "10.20.1.1/32 dev tun0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("include routes = %q, want %q", got, want)
+	}
+
+	cfg.Tun.Mode = "exclude"
+	cfg.Tun.Domains = nil
+	got, err = splitRoutes(cfg, orig, fakeLookup)
+	if err != nil {
+		t.Fatalf("exclude: %v", err)
+	}
+	want = []string{"10.20.0.0/16 via 172.27.16.1 dev eth0", "192.168.7.10/32 via 172.27.16.1 dev eth0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("exclude routes = %q, want %q", got, want)
+	}
+	if _, err := splitRoutes(cfg, "", fakeLookup); err == nil {
+		t.Fatalf("exclude without an original route must fail")
+	}
+
+	for _, bad := range []config.TunConfig{
+		{Mode: "include"},
+		{Mode: "include", Routes: []string{"10.0.0.0/33"}},
+		{Mode: "include", Domains: []string{"missing.example"}},
+	} {
+		if _, err := splitRoutes(config.Config{Tun: bad}, orig, fakeLookup); err == nil {
+			t.Fatalf("%+v: expected error", bad)
+		}
+	}
+	if got, err := splitRoutes(config.Config{Tun: config.TunConfig{Routes: []string{"10.0.0.0/8"}}}, orig, fakeLookup); err != nil || got != nil {
+		t.Fatalf("full mode = %q, %v", got, err)
+	}
+}
+
+// TestInstallRoutes verifies that recorded routes are replaced and removed
+// exactly.
+func TestInstallRoutes(t *testing.T) {
+	r := &logRunner{}
+	rt := appruntime.Runtime{Runner: r}
+	rt.Paths.TunRoutesFile = filepath.Join(t.TempDir(), "tun_routes.txt")
+
+	if err := installRoutes(rt, rt.Paths.TunRoutesFile, []string{"10.20.0.0/16 dev tun0"}); err != nil {
+		t.Fatalf("installRoutes: %v", err)
+	}
+	if err := installRoutes(rt, rt.Paths.TunRoutesFile, []string{"10.9.0.7/32 dev tun0"}); err != nil {
+		t.Fatalf("installRoutes: %v", err)
+	}
+	removeRoutes(rt, rt.Paths.TunRoutesFile)
+	want := []string{
+		"sudo ip route replace 10.20.0.0/16 dev tun0",
+		"sudo ip route del 10.20.0.0/16 dev tun0",
+		"sudo ip route replace 10.9.0.7/32 dev tun0",
+		"sudo ip route del 10.9.0.7/32 dev tun0",
+	}
+	if !reflect.DeepEqual(r.cmds, want) {
+		t.Fatalf("commands = %q, want %q", r.cmds, want)
+	}
+	if routes := readRoutes(rt.Paths.TunRoutesFile); routes != nil {
+		t.Fatalf("record left behind: %q", routes)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"strings"
 
+	"wslbridge/internal/cli"
 	appruntime "wslbridge/internal/runtime"
 	"wslbridge/internal/tun2socks"
 )
@@ -19,13 +20,62 @@ func (StatusCommand) Name() string { return "status-ubuntu" }
 // Help returns the command description.
 func (StatusCommand) Help() string { return "Show tun2socks and routing status (Ubuntu/WSL)" }
 
+// statusSchemaVersion is the version of the statusReport schema. Fields are
+// only added within a version; renames and removals bump it.
+const statusSchemaVersion = 1
+
+// statusReport is the machine-readable form of `status`. All fields are
+// always present; unknown values are empty strings or zero.
+type statusReport struct {
+	SchemaVersion int `json:"schema_version" yaml:"schema_version"`
+	Config        struct {
+		Path    string `json:"path" yaml:"path"`
+		Exists  bool   `json:"exists" yaml:"exists"`
+		Profile string `json:"profile" yaml:"profile"`
+	} `json:"config" yaml:"config"`
+	WSL   bool `json:"wsl" yaml:"wsl"`
+	Route struct {
+		Default string `json:"default" yaml:"default"`
+		ViaTun  bool   `json:"via_tun" yaml:"via_tun"`
+		// Mode is the tun.mode; Split lists the routes init added for the
+		// include and exclude modes and Bypass the routes that keep the
+		// SOCKS gateway and local networks off the tun device.
+		Mode   string   `json:"mode" yaml:"mode"`
+		Split  []string `json:"split" yaml:"split"`
+		Bypass []string `json:"bypass" yaml:"bypass"`
+	} `json:"route" yaml:"route"`
+	Tun struct {
+		Dev  string `json:"dev" yaml:"dev"`
+		Link bool   `json:"link" yaml:"link"`
+	} `json:"tun" yaml:"tun"`
+	Socks struct {
+		Host string `json:"host" yaml:"host"`
+		Port int    `json:"port" yaml:"port"`
+	} `json:"socks" yaml:"socks"`
+	Tun2socks struct {
+		Running  bool   `json:"running" yaml:"running"`
+		PID      int    `json:"pid" yaml:"pid"`
+		StalePID bool   `json:"stale_pid" yaml:"stale_pid"`
+		PIDFile  string `json:"pid_file" yaml:"pid_file"`
+	} `json:"tun2socks" yaml:"tun2socks"`
+	Watchdog struct {
+		Running bool `json:"running" yaml:"running"`
+		PID     int  `json:"pid" yaml:"pid"`
+	} `json:"watchdog" yaml:"watchdog"`
+	// Health is ok when tun2socks runs and the default route (in include
+	// mode: the split routes) goes through the tun link, down when
+	// tun2socks is not running and degraded otherwise.
+	Health string `json:"health" yaml:"health"`
+}
+
 // Run executes the status workflow for Ubuntu/WSL.
 func (StatusCommand) Run(rt appruntime.Runtime, args []string) error {
-	if err := parseStatusFlags(args); err != nil {
+	format, err := parseStatusFlags(args)
+	if err != nil {
 		return err
 	}
 
-	cfg, _, err := loadConfig(rt.Paths.ConfigPath)
+	cfg, exists, err := loadConfig(rt)
 	if err != nil {
 		return err
 	}
@@ -36,49 +86,104 @@ func (StatusCommand) Run(rt appruntime.Runtime, args []string) error {
 		return err
 	}
 
-	fmt.Println("Config:", rt.Paths.ConfigPath)
-	fmt.Println("WSL:", isWSL())
+	var r statusReport
+	r.SchemaVersion = statusSchemaVersion
+	r.Config.Path = rt.Paths.ConfigPath
+	r.Config.Exists = exists
+	r.Config.Profile = rt.Profile
+	r.WSL = isWSL()
+	r.Route.Default = strings.TrimSpace(defaultRouteLine)
+	r.Route.ViaTun = defaultIsTun(defaultRouteLine, cfg.Tun.Dev)
+	r.Route.Mode = tunMode(cfg)
+	r.Route.Split = append([]string{}, readRoutes(rt.Paths.TunRoutesFile)...)
+	r.Route.Bypass = append([]string{}, readRoutes(rt.Paths.TunBypassFile)...)
+	routed := r.Route.ViaTun
+	if r.Route.Mode == tunModeInclude {
+		routed = len(r.Route.Split) > 0
+	}
+	r.Tun.Dev = cfg.Tun.Dev
+	r.Tun.Link = linkExists(cfg.Tun.Dev)
+	r.Socks.Host = cfg.Socks.Host
+	r.Socks.Port = cfg.Socks.Port
+	r.Tun2socks.Running = tun2socks.IsRunning(rt.Paths.Tun2SocksPIDFile)
+	r.Tun2socks.PIDFile = rt.Paths.Tun2SocksPIDFile
+	if pid, ok := readPID(rt.Paths.Tun2SocksPIDFile); ok {
+		r.Tun2socks.PID = pid
+		r.Tun2socks.StalePID = !r.Tun2socks.Running
+	}
+	r.Watchdog.PID, r.Watchdog.Running = watchdogPID(rt)
+	switch {
+	case !r.Tun2socks.Running:
+		r.Health = "down"
+	case !r.Tun.Link || !routed:
+		r.Health = "degraded"
+	default:
+		r.Health = "ok"
+	}
 
-	routeLine := defaultRouteLine
-	if strings.TrimSpace(routeLine) == "" {
+	if format != cli.OutputText {
+		return cli.WriteOutput(os.Stdout, format, r)
+	}
+	printStatus(r)
+	return nil
+}
+
+func printStatus(r statusReport) {
+	fmt.Println("Config:", r.Config.Path)
+	fmt.Println("WSL:", r.WSL)
+
+	routeLine := r.Route.Default
+	if routeLine == "" {
 		routeLine = "(none)"
 	}
 	fmt.Println("Default route:", routeLine)
-	fmt.Println("Default is tun:", defaultIsTun(defaultRouteLine, cfg.Tun.Dev))
+	fmt.Println("Default is tun:", r.Route.ViaTun)
+	fmt.Println("Tun mode:", r.Route.Mode)
+	if len(r.Route.Split) > 0 {
+		fmt.Println("Split routes:")
+		for _, route := range r.Route.Split {
+			fmt.Println("-", route)
+		}
+	}
+	if len(r.Route.Bypass) > 0 {
+		fmt.Println("Bypass routes:")
+		for _, route := range r.Route.Bypass {
+			fmt.Println("-", route)
+		}
+	}
 
-	fmt.Println("Tun dev:", cfg.Tun.Dev)
-	fmt.Println("Tun link:", boolLabel(linkExists(cfg.Tun.Dev)))
+	fmt.Println("Tun dev:", r.Tun.Dev)
+	fmt.Println("Tun link:", boolLabel(r.Tun.Link))
 
-	if cfg.Socks.Host != "" && cfg.Socks.Port != 0 {
-		fmt.Printf("SOCKS: %s:%d\n", cfg.Socks.Host, cfg.Socks.Port)
+	if r.Socks.Host != "" && r.Socks.Port != 0 {
+		fmt.Printf("SOCKS: %s:%d\n", r.Socks.Host, r.Socks.Port)
 	} else {
 		fmt.Println("SOCKS: (not configured)")
 	}
 
-	running := tun2socks.IsRunning(rt.Paths.Tun2SocksPIDFile)
-	fmt.Println("Tun2socks running:", boolLabel(running))
-	fmt.Println("Tun2socks pid file:", rt.Paths.Tun2SocksPIDFile)
-	if pid, ok := readPID(rt.Paths.Tun2SocksPIDFile); ok {
-		if running {
-			fmt.Println("Tun2socks pid:", pid)
-		} else {
-			fmt.Println("Tun2socks pid:", pid, "(stale)")
-		}
-	} else {
+	fmt.Println("Tun2socks running:", boolLabel(r.Tun2socks.Running))
+	fmt.Println("Tun2socks pid file:", r.Tun2socks.PIDFile)
+	switch {
+	case r.Tun2socks.PID == 0:
 		fmt.Println("Tun2socks pid:", "(not found)")
+	case r.Tun2socks.StalePID:
+		fmt.Println("Tun2socks pid:", r.Tun2socks.PID, "(stale)")
+	default:
+		fmt.Println("Tun2socks pid:", r.Tun2socks.PID)
 	}
-
-	return nil
+	fmt.Println("Watchdog running:", boolLabel(r.Watchdog.Running))
 }
 
-func parseStatusFlags(args []string) error {
-	for _, a := range args {
-		switch a {
-		default:
-			return fmt.Errorf("unknown arg: %s", a)
-		}
+// parseStatusFlags reads `--output json|yaml`.
+func parseStatusFlags(args []string) (string, error) {
+	format, rest, err := cli.OutputFlag(args)
+	if err != nil {
+		return "", err
 	}
-	return nil
+	if len(rest) > 0 {
+		return "", fmt.Errorf("unknown arg: %s", rest[0])
+	}
+	return format, nil
 }
 
 func readPID(path string) (int, bool) {
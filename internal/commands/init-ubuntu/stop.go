@@ -1,12 +1,10 @@
 package init_ubuntu
 
 import (
-	"errors"
 	"fmt"
 	"os"
 	"strings"
 
-	"wslbridge/internal/config"
 	appruntime "wslbridge/internal/runtime"
 	"wslbridge/internal/tun2socks"
 )
@@ -26,10 +24,8 @@ func (StopCommand) Run(rt appruntime.Runtime, args []string) error {
 		return err
 	}
 
-	var cfg config.Config
-	if c, err := config.Load(rt.Paths.ConfigPath); err == nil {
-		cfg = c
-	} else if !errors.Is(err, os.ErrNotExist) {
+	cfg, _, err := loadConfig(rt)
+	if err != nil {
 		return err
 	}
 
@@ -37,6 +33,16 @@ func (StopCommand) Run(rt appruntime.Runtime, args []string) error {
 		cfg.Tun.Dev = "tun0"
 	}
 
+	logStep("Stopping watchdog (if running)")
+	if err := stopWatchdog(rt); err != nil {
+		return err
+	}
+
+	if routes := readRoutes(rt.Paths.TunRoutesFile); len(routes) > 0 {
+		logStep(fmt.Sprintf("Removing %d split tunnel routes", len(routes)))
+		removeRoutes(rt, rt.Paths.TunRoutesFile)
+	}
+
 	restoredRoute := false
 	if b, err := os.ReadFile(rt.Paths.DefaultRouteFile); err == nil {
 		line := strings.TrimSpace(string(b))
@@ -52,6 +58,11 @@ func (StopCommand) Run(rt appruntime.Runtime, args []string) error {
 		logStep("Default route backup not found; skipping route restore")
 	}
 
+	if routes := readRoutes(rt.Paths.TunBypassFile); len(routes) > 0 {
+		logStep(fmt.Sprintf("Removing %d bypass routes", len(routes)))
+		removeRoutes(rt, rt.Paths.TunBypassFile)
+	}
+
 	logStep("Stopping tun2socks (if running)")
 	if err := tun2socks.StopIfRunning(rt, rt.Paths.Tun2SocksPIDFile); err != nil {
 		return err
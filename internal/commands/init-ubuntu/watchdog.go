@@ -0,0 +1,401 @@
+package init_ubuntu
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"wslbridge/internal/config"
+	appruntime "wslbridge/internal/runtime"
+	"wslbridge/internal/socks"
+	"wslbridge/internal/tun2socks"
+)
+
+// WatchdogCommand implements the Ubuntu/WSL tunnel watchdog.
+type WatchdogCommand struct{}
+
+// Name returns the command name.
+func (WatchdogCommand) Name() string { return "watchdog-ubuntu" }
+
+// Help returns the command description.
+func (WatchdogCommand) Help() string {
+	return "Watch the SOCKS proxy and tun2socks, restart tun2socks on failure (Ubuntu/WSL)"
+}
+
+const (
+	defaultWatchdogInterval   = 10 * time.Second
+	defaultWatchdogMaxBackoff = 5 * time.Minute
+)
+
+type watchdogOptions struct {
+	interval   time.Duration
+	maxBackoff time.Duration
+	// restoreAfter is the number of failed checks in a row after which the
+	// saved default route is restored; 0 keeps the route on the tun device.
+	restoreAfter int
+	// probe is the host:port to CONNECT to through the SOCKS proxy.
+	probe string
+}
+
+const watchdogUsage = "usage: watchdog start|run [--interval=10s] [--max-backoff=5m] [--restore-route-after=<n>] [--probe=<host:port>] | watchdog stop|status"
+
+// Run executes the watchdog workflow for Ubuntu/WSL.
+func (WatchdogCommand) Run(rt appruntime.Runtime, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(watchdogUsage)
+	}
+	switch args[0] {
+	case "start":
+		if _, err := parseWatchdogFlags(args[1:]); err != nil {
+			return err
+		}
+		return startWatchdog(rt, args[1:])
+	case "run":
+		opts, err := parseWatchdogFlags(args[1:])
+		if err != nil {
+			return err
+		}
+		return runWatchdog(rt, opts)
+	case "stop", "status":
+		if len(args) > 1 {
+			return fmt.Errorf("unknown arg: %s", args[1])
+		}
+		if args[0] == "stop" {
+			return stopWatchdog(rt)
+		}
+		pid, running := watchdogPID(rt)
+		fmt.Println("Watchdog running:", boolLabel(running))
+		if running {
+			fmt.Println("Watchdog pid:", pid)
+		}
+		fmt.Println("Watchdog log:", rt.Paths.WatchdogLogFile)
+		return nil
+	default:
+		return fmt.Errorf(watchdogUsage)
+	}
+}
+
+func parseWatchdogFlags(args []string) (watchdogOptions, error) {
+	opts := watchdogOptions{interval: defaultWatchdogInterval, maxBackoff: defaultWatchdogMaxBackoff}
+	for _, a := range args {
+		name, val, _ := strings.Cut(a, "=")
+		var err error
+		switch name {
+		case "--interval":
+			opts.interval, err = time.ParseDuration(val)
+			if err == nil && opts.interval < time.Second {
+				err = fmt.Errorf("must be at least 1s")
+			}
+		case "--max-backoff":
+			opts.maxBackoff, err = time.ParseDuration(val)
+			if err == nil && opts.maxBackoff <= 0 {
+				err = fmt.Errorf("must be positive")
+			}
+		case "--restore-route-after":
+			opts.restoreAfter, err = strconv.Atoi(val)
+			if err == nil && opts.restoreAfter < 0 {
+				err = fmt.Errorf("must not be negative")
+			}
+		case "--probe":
+			opts.probe = val
+			_, _, err = net.SplitHostPort(val)
+		default:
+			return watchdogOptions{}, fmt.Errorf("unknown arg: %s", a)
+		}
+		if err != nil {
+			return watchdogOptions{}, fmt.Errorf("invalid %s=%q: %w", name, val, err)
+		}
+	}
+	return opts, nil
+}
+
+// watchdogPID returns the pid of a running watchdog.
+func watchdogPID(rt appruntime.Runtime) (int, bool) {
+	pid, ok := readPID(rt.Paths.WatchdogPIDFile)
+	if !ok || exec.Command("kill", "-0", strconv.Itoa(pid)).Run() != nil {
+		return 0, false
+	}
+	return pid, true
+}
+
+// startWatchdog runs `watchdog run` in the background with the global flags
+// of this invocation, logging to WatchdogLogFile.
+func startWatchdog(rt appruntime.Runtime, args []string) error {
+	if pid, ok := watchdogPID(rt); ok {
+		return fmt.Errorf("watchdog is already running (pid %d)", pid)
+	}
+	if exec.Command("sudo", "-n", "true").Run() != nil {
+		fmt.Println("warning: sudo asks for a password; the watchdog cannot restart tun2socks or restore the route without passwordless sudo")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("resolve executable: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(rt.Paths.WatchdogLogFile), 0o755); err != nil {
+		return err
+	}
+	logf, err := os.OpenFile(rt.Paths.WatchdogLogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open watchdog log: %w", err)
+	}
+	defer logf.Close()
+
+	cmdArgs := []string{exe, "--profile", rt.Profile}
+	keys := make([]string, 0, len(rt.ConfigFlags))
+	for k := range rt.ConfigFlags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		cmdArgs = append(cmdArgs, "--set", k+"="+rt.ConfigFlags[k])
+	}
+	cmdArgs = append(append(cmdArgs, "--non-interactive", "watchdog", "run"), args...)
+
+	cmd := exec.Command("nohup", cmdArgs...)
+	cmd.Stdout = logf
+	cmd.Stderr = logf
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start watchdog: %w", err)
+	}
+	pid := cmd.Process.Pid
+	_ = cmd.Process.Release()
+	if err := os.WriteFile(rt.Paths.WatchdogPIDFile, []byte(fmt.Sprintf("%d\n", pid)), 0o644); err != nil {
+		return err
+	}
+	time.Sleep(500 * time.Millisecond)
+	if _, ok := watchdogPID(rt); !ok {
+		_ = os.Remove(rt.Paths.WatchdogPIDFile)
+		return fmt.Errorf("watchdog exited right after start; see %s", rt.Paths.WatchdogLogFile)
+	}
+	fmt.Println("watchdog pid:", pid)
+	fmt.Println("log:", rt.Paths.WatchdogLogFile)
+	return nil
+}
+
+func stopWatchdog(rt appruntime.Runtime) error {
+	pid, ok := watchdogPID(rt)
+	if !ok {
+		_ = os.Remove(rt.Paths.WatchdogPIDFile)
+		fmt.Println("watchdog is not running")
+		return nil
+	}
+	_ = exec.Command("kill", strconv.Itoa(pid)).Run()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, ok := watchdogPID(rt); !ok {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if _, ok := watchdogPID(rt); ok {
+		_ = exec.Command("kill", "-9", strconv.Itoa(pid)).Run()
+	}
+	_ = os.Remove(rt.Paths.WatchdogPIDFile)
+	fmt.Println("watchdog stopped")
+	return nil
+}
+
+// runWatchdog checks the tunnel every interval until SIGINT or SIGTERM.
+func runWatchdog(rt appruntime.Runtime, opts watchdogOptions) error {
+	cfg, _, err := loadConfig(rt)
+	if err != nil {
+		return err
+	}
+	applyDefaults(&cfg)
+	if cfg.Socks.Host == "" || cfg.Socks.Port == 0 {
+		return fmt.Errorf("SOCKS endpoint is not configured; run `wslbridge init` first")
+	}
+	if opts.probe == "" {
+		host, port := doctorProbe(cfg)
+		opts.probe = net.JoinHostPort(host, port)
+	}
+
+	logger := log.New(os.Stdout, "watchdog: ", log.LstdFlags)
+	w := newWatchdog(rt, cfg, opts, logger.Printf)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger.Printf("started (pid %d): socks %s, probe %s, interval %s, restore route after %d failures",
+		os.Getpid(), socksAddr(cfg), opts.probe, opts.interval, opts.restoreAfter)
+	ticker := time.NewTicker(opts.interval)
+	defer ticker.Stop()
+	for {
+		w.check()
+		select {
+		case <-ctx.Done():
+			logger.Printf("stopped")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Watchdog states.
+const (
+	watchdogHealthy       = "healthy"
+	watchdogSocksDown     = "socks-down"
+	watchdogTunDown       = "tun2socks-down"
+	watchdogRouteRestored = "route-restored"
+)
+
+// watchdog is the check loop state. The hooks are replaced in tests;
+// restartTun reports whether the tun device had to be recreated.
+type watchdog struct {
+	opts         watchdogOptions
+	probe        func() error
+	tunRunning   func() bool
+	restartTun   func() (bool, error)
+	restoreRoute func() error
+	routeViaTun  func() error
+	logf         func(format string, args ...any)
+	now          func() time.Time
+
+	state    string
+	failures int
+	// restoreErr is set when restoring the route failed; it is not retried
+	// until the checks pass again.
+	restoreErr  error
+	backoff     time.Duration
+	nextRestart time.Time
+	// routesLost is set when the tun device was recreated, which drops the
+	// routes through it, and they have not been added back yet.
+	routesLost bool
+}
+
+func newWatchdog(rt appruntime.Runtime, cfg config.Config, opts watchdogOptions, logf func(string, ...any)) *watchdog {
+	return &watchdog{
+		opts: opts,
+		probe: func() error {
+			conn, err := socks.Dial(socksAddr(cfg), opts.probe, doctorTimeout)
+			if err != nil {
+				return err
+			}
+			return conn.Close()
+		},
+		tunRunning: func() bool { return tun2socks.IsRunning(rt.Paths.Tun2SocksPIDFile) },
+		restartTun: func() (bool, error) { return restartTun2socks(rt, cfg) },
+		restoreRoute: func() error {
+			if tunMode(cfg) == tunModeInclude {
+				deleteRoutes(rt, rt.Paths.TunRoutesFile)
+				return nil
+			}
+			b, err := os.ReadFile(rt.Paths.DefaultRouteFile)
+			if err != nil {
+				return fmt.Errorf("no saved default route: %w", err)
+			}
+			return restoreDefaultRoute(rt, strings.TrimSpace(string(b)))
+		},
+		routeViaTun: func() error {
+			if tunMode(cfg) == tunModeInclude {
+				return replayRoutes(rt, rt.Paths.TunRoutesFile)
+			}
+			return routeDefaultViaTun(rt, cfg.Tun.Dev)
+		},
+		logf: logf,
+		now:  time.Now,
+	}
+}
+
+// check runs one round: the SOCKS5 CONNECT probe and the tun2socks pid
+// check. A dead tun2socks is restarted, with the delay between restarts
+// doubling up to maxBackoff. After restoreAfter failed rounds in a row the
+// saved default route is restored (in include mode: the split routes are
+// deleted) so traffic stops black-holing on the tun device; the routes move
+// back once both checks pass again.
+func (w *watchdog) check() {
+	socksErr := w.probe()
+	tunUp := w.tunRunning()
+
+	if socksErr == nil && tunUp {
+		if w.state == watchdogRouteRestored || w.routesLost {
+			if err := w.routeViaTun(); err != nil {
+				w.logf("moving the default route back to tun failed: %v", err)
+				return
+			}
+		}
+		w.failures, w.backoff, w.nextRestart, w.restoreErr, w.routesLost = 0, 0, time.Time{}, nil, false
+		w.transition(watchdogHealthy, "SOCKS and tun2socks are up")
+		return
+	}
+
+	w.failures++
+	switch {
+	case !tunUp:
+		w.transition(watchdogTunDown, "tun2socks is not running")
+		if now := w.now(); !now.Before(w.nextRestart) {
+			w.backoff = min(max(2*w.backoff, w.opts.interval), w.opts.maxBackoff)
+			w.nextRestart = now.Add(w.backoff)
+			recreated, err := w.restartTun()
+			w.routesLost = w.routesLost || recreated
+			if err != nil {
+				w.logf("restarting tun2socks failed: %v (next attempt in %s)", err, w.backoff)
+			} else {
+				w.logf("tun2socks restarted")
+			}
+			// A restored route stays until the checks pass again.
+			if err == nil && w.routesLost && w.state != watchdogRouteRestored {
+				if err := w.routeViaTun(); err != nil {
+					w.logf("re-adding the routes via the recreated tun device failed: %v", err)
+				} else {
+					w.routesLost = false
+				}
+			}
+		}
+	default:
+		w.transition(watchdogSocksDown, socksErr.Error())
+	}
+
+	if w.opts.restoreAfter > 0 && w.failures >= w.opts.restoreAfter && w.state != watchdogRouteRestored && w.restoreErr == nil {
+		if w.restoreErr = w.restoreRoute(); w.restoreErr != nil {
+			w.logf("restoring the default route failed: %v", w.restoreErr)
+			return
+		}
+		w.transition(watchdogRouteRestored, fmt.Sprintf("%d failed checks in a row", w.failures))
+	}
+}
+
+// transition logs a state change. The route-restored state is kept until
+// the checks pass again.
+func (w *watchdog) transition(to, reason string) {
+	if w.state == to || (w.state == watchdogRouteRestored && to != watchdogHealthy) {
+		return
+	}
+	from := w.state
+	if from == "" {
+		from = "starting"
+	}
+	w.logf("%s -> %s: %s", from, to, reason)
+	w.state = to
+}
+
+// restartTun2socks stops tun2socks, recreates the tun device if it is gone
+// and starts tun2socks again. It reports whether the device was recreated.
+func restartTun2socks(rt appruntime.Runtime, cfg config.Config) (bool, error) {
+	_ = tun2socks.StopIfRunning(rt, rt.Paths.Tun2SocksPIDFile)
+	bin, err := tun2socks.FindBin()
+	if err != nil {
+		return false, err
+	}
+	recreated := !linkExists(cfg.Tun.Dev)
+	if recreated {
+		if err := setupTun(rt, cfg); err != nil {
+			return false, err
+		}
+	}
+	pid, err := tun2socks.Start(bin, cfg, rt.Paths.Tun2SocksLogFile)
+	if err != nil {
+		return recreated, err
+	}
+	return recreated, os.WriteFile(rt.Paths.Tun2SocksPIDFile, []byte(fmt.Sprintf("%d\n", pid)), 0o644)
+}
@@ -0,0 +1,138 @@
+package init_ubuntu
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeTunnel struct {
+	socksErr  error
+	tunUp     bool
+	restarts  int
+	restartOK bool
+	recreate  bool
+	restored  int
+	rerouted  int
+	now       time.Time
+	logs      []string
+}
+
+func (f *fakeTunnel) watchdog(opts watchdogOptions) *watchdog {
+	return &watchdog{
+		opts:       opts,
+		probe:      func() error { return f.socksErr },
+		tunRunning: func() bool { return f.tunUp },
+		restartTun: func() (bool, error) {
+			f.restarts++
+			if !f.restartOK {
+				return false, errors.New("sudo: a password is required")
+			}
+			f.tunUp = true
+			return f.recreate, nil
+		},
+		restoreRoute: func() error { f.restored++; return nil },
+		routeViaTun:  func() error { f.rerouted++; return nil },
+		logf:         func(format string, args ...any) { f.logs = append(f.logs, fmt.Sprintf(format, args...)) },
+		now:          func() time.Time { return f.now },
+	}
+}
+
+// TestWatchdogBackoff verifies that restarts of a dead tun2socks back off
+// and that the saved route is restored and undone.
+func TestWatchdogBackoff(t *testing.T) {
+	f := &fakeTunnel{tunUp: true, now: time.Unix(0, 0)}
+	w := f.watchdog(watchdogOptions{interval: 10 * time.Second, maxBackoff: 40 * time.Second, restoreAfter: 3})
+
+	w.check()
+	if w.state != watchdogHealthy || len(f.logs) != 1 || !strings.Contains(f.logs[0], "starting -> healthy") {
+		t.Fatalf("state = %s, logs = %q", w.state, f.logs)
+	}
+
+	f.tunUp = false
+	var attempts []int
+	for i := 0; i < 12; i++ {
+		w.check()
+		attempts = append(attempts, f.restarts)
+		f.now = f.now.Add(10 * time.Second)
+	}
+	// Restarts at 0s, 10s, 30s, 70s and 110s: 10s, 20s, 40s, 40s apart.
+	want := []int{1, 2, 2, 3, 3, 3, 3, 4, 4, 4, 4, 5}
+	if fmt.Sprint(attempts) != fmt.Sprint(want) {
+		t.Fatalf("restart attempts = %v, want %v", attempts, want)
+	}
+	if w.state != watchdogRouteRestored || f.restored != 1 {
+		t.Fatalf("state = %s, restored = %d", w.state, f.restored)
+	}
+
+	f.restartOK = true
+	f.now = f.now.Add(time.Minute)
+	w.check()
+	if f.restarts != 6 || w.state != watchdogRouteRestored {
+		t.Fatalf("restarts = %d, state = %s", f.restarts, w.state)
+	}
+	w.check()
+	if w.state != watchdogHealthy || f.rerouted != 1 || w.failures != 0 || w.backoff != 0 {
+		t.Fatalf("state = %s, rerouted = %d, failures = %d, backoff = %s", w.state, f.rerouted, w.failures, w.backoff)
+	}
+}
+
+// TestWatchdogReaddsRoutesAfterRecreate verifies that the routes are added
+// back when a restart had to recreate the tun device, but not while the
+// saved route is restored.
+func TestWatchdogReaddsRoutesAfterRecreate(t *testing.T) {
+	f := &fakeTunnel{restartOK: true, recreate: true, now: time.Unix(0, 0)}
+	w := f.watchdog(watchdogOptions{interval: 10 * time.Second, maxBackoff: 40 * time.Second, restoreAfter: 3})
+
+	w.check()
+	if f.restarts != 1 || f.rerouted != 1 || w.routesLost {
+		t.Fatalf("restarts = %d, rerouted = %d, routesLost = %v", f.restarts, f.rerouted, w.routesLost)
+	}
+	w.check()
+	if w.state != watchdogHealthy || f.rerouted != 1 {
+		t.Fatalf("state = %s, rerouted = %d", w.state, f.rerouted)
+	}
+
+	w.state = watchdogRouteRestored
+	f.tunUp = false
+	f.socksErr = errors.New("connection refused")
+	w.check()
+	if f.restarts != 2 || f.rerouted != 1 || !w.routesLost {
+		t.Fatalf("restarts = %d, rerouted = %d, routesLost = %v", f.restarts, f.rerouted, w.routesLost)
+	}
+	f.socksErr = nil
+	w.check()
+	if w.state != watchdogHealthy || f.rerouted != 2 || w.routesLost {
+		t.Fatalf("state = %s, rerouted = %d, routesLost = %v", w.state, f.rerouted, w.routesLost)
+	}
+}
+
+// TestWatchdogSocksDown verifies that a dead SOCKS proxy does not restart
+// tun2socks and that each transition is logged once.
+func TestWatchdogSocksDown(t *testing.T) {
+	f := &fakeTunnel{tunUp: true, socksErr: errors.New("connection refused"), now: time.Unix(0, 0)}
+	w := f.watchdog(watchdogOptions{interval: time.Second, maxBackoff: time.Minute})
+	for i := 0; i < 3; i++ {
+		w.check()
+	}
+	if f.restarts != 0 || f.restored != 0 || w.state != watchdogSocksDown {
+		t.Fatalf("restarts = %d, restored = %d, state = %s", f.restarts, f.restored, w.state)
+	}
+	if len(f.logs) != 1 || !strings.Contains(f.logs[0], "starting -> socks-down: connection refused") {
+		t.Fatalf("logs = %q", f.logs)
+	}
+}
+
+func TestParseWatchdogFlags(t *testing.T) {
+	opts, err := parseWatchdogFlags([]string{"--interval=30s", "--restore-route-after=5", "--probe=10.0.0.1:443"})
+	if err != nil || opts.interval != 30*time.Second || opts.restoreAfter != 5 || opts.probe != "10.0.0.1:443" || opts.maxBackoff != defaultWatchdogMaxBackoff {
+		t.Fatalf("opts = %+v, err = %v", opts, err)
+	}
+	for _, bad := range []string{"--interval=10ms", "--restore-route-after=-1", "--probe=host", "--max-backoff=0s", "--bogus"} {
+		if _, err := parseWatchdogFlags([]string{bad}); err == nil {
+			t.Fatalf("%s: expected error", bad)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package profilecmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	configcmd "wslbridge/internal/commands/config"
+	"wslbridge/internal/config"
+	appruntime "wslbridge/internal/runtime"
+)
+
+// maxExtendsShown bounds the printed `extends` chain of a broken (cyclic)
+// profile; Load reports the cycle itself.
+const maxExtendsShown = 8
+
+// Command implements named config profiles.
+type Command struct{}
+
+// Name returns the command name.
+func (Command) Name() string { return "profile" }
+
+// Help returns the command description.
+func (Command) Help() string {
+	return "Manage named config profiles (list|use|show)"
+}
+
+// Run executes profile command.
+func (Command) Run(rt appruntime.Runtime, args []string) error {
+	if len(args) == 0 {
+		return show(rt, rt.Profile)
+	}
+
+	switch args[0] {
+	case "list":
+		if len(args) > 1 {
+			return fmt.Errorf("unknown arg: %s", args[1])
+		}
+		return list(rt)
+	case "use":
+		return runUse(args[1:])
+	case "show":
+		switch len(args) {
+		case 1:
+			return show(rt, rt.Profile)
+		case 2:
+			return show(rt, args[1])
+		default:
+			return fmt.Errorf("usage: profile show [<name>]")
+		}
+	default:
+		return fmt.Errorf("unknown profile subcommand: %s", args[0])
+	}
+}
+
+func list(rt appruntime.Runtime) error {
+	names, err := appruntime.ListProfiles()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		mark := " "
+		if name == rt.Profile {
+			mark = "*"
+		}
+		path, err := appruntime.ProfileConfigPath(name)
+		if err != nil {
+			return err
+		}
+		line := fmt.Sprintf("%s %s: %s", mark, name, path)
+		if extends := config.ReadExtends(path); extends != "" {
+			line += fmt.Sprintf(" (extends %s)", extends)
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+func runUse(args []string) error {
+	var name, base string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case strings.HasPrefix(a, "--extends="):
+			base = strings.TrimPrefix(a, "--extends=")
+		case a == "--extends":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--extends requires a profile name")
+			}
+			i++
+			base = args[i]
+		case strings.HasPrefix(a, "-"):
+			return fmt.Errorf("unknown arg: %s", a)
+		case name == "":
+			name = a
+		default:
+			return fmt.Errorf("unknown arg: %s", a)
+		}
+	}
+	if name == "" {
+		return fmt.Errorf("usage: profile use <name> [--extends=<base>]")
+	}
+	name = strings.ToLower(strings.TrimSpace(name))
+	if err := appruntime.ValidateProfileName(name); err != nil {
+		return err
+	}
+
+	if appruntime.ProfileExists(name) {
+		if base != "" {
+			return fmt.Errorf("profile %q already exists; --extends only applies to new profiles", name)
+		}
+	} else {
+		if base == "" {
+			base = appruntime.DefaultProfile
+		}
+		path, err := appruntime.CreateProfile(name, base)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("profile created: %s (extends %s): %s\n", name, strings.ToLower(base), path)
+	}
+
+	if err := appruntime.SelectProfile(name); err != nil {
+		return err
+	}
+	fmt.Println("profile:", name)
+	if env := strings.TrimSpace(os.Getenv(appruntime.ProfileEnv)); env != "" {
+		fmt.Printf("note: %s=%s overrides the saved profile\n", appruntime.ProfileEnv, env)
+	}
+	return nil
+}
+
+func show(rt appruntime.Runtime, nameArg string) error {
+	name := strings.ToLower(strings.TrimSpace(nameArg))
+	if name == "" {
+		name = appruntime.DefaultProfile
+	}
+	if err := appruntime.ValidateProfileName(name); err != nil {
+		return err
+	}
+	if !appruntime.ProfileExists(name) {
+		return fmt.Errorf("profile %q not found (create it with `profile use %s`)", name, name)
+	}
+	paths, err := appruntime.PathsForProfile(name)
+	if err != nil {
+		return err
+	}
+
+	label := name
+	if name == rt.Profile {
+		label += " (active)"
+	}
+	fmt.Println("Profile:", label)
+	fmt.Println("Config:", paths.ConfigPath)
+	path := paths.ConfigPath
+	for depth := 0; depth < maxExtendsShown; depth++ {
+		extends := config.ReadExtends(path)
+		if extends == "" {
+			break
+		}
+		path = config.ResolveExtends(path, extends)
+		fmt.Printf("Extends: %s (%s)\n", extends, path)
+	}
+	fmt.Println("State dir:", paths.StateDir)
+
+	cfg, err := config.Load(paths.ConfigPath, appruntime.SecretStores(paths)...)
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Println("Effective config: not found (run `init` or `db init`)")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	b, err := config.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	if b, err = configcmd.MaskYAML(b); err != nil {
+		return err
+	}
+	fmt.Println("Effective config:")
+	fmt.Print(string(b))
+	return nil
+}
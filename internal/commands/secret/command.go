@@ -0,0 +1,127 @@
+package secretcmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"wslbridge/internal/cli"
+	appruntime "wslbridge/internal/runtime"
+	"wslbridge/internal/secrets"
+)
+
+// Command manages the local encrypted secret store of the active profile that
+// secret://name config values are read from.
+type Command struct{}
+
+// Name returns the command name.
+func (Command) Name() string { return "secret" }
+
+// Help returns the command description.
+func (Command) Help() string {
+	return "Manage the local encrypted secret store (set|get|rm|list)"
+}
+
+// Run executes secret command.
+func (Command) Run(rt appruntime.Runtime, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: secret set|get|rm|list")
+	}
+	store := secrets.Open(rt.Paths.SecretStoreDir)
+
+	switch args[0] {
+	case "set":
+		switch len(args) {
+		case 2:
+			value, err := readValue(rt, args[1])
+			if err != nil {
+				return err
+			}
+			return set(store, args[1], value)
+		case 3:
+			return set(store, args[1], args[2])
+		default:
+			return fmt.Errorf("usage: secret set <name> [<value>]")
+		}
+	case "get":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: secret get <name>")
+		}
+		val, err := store.Get(args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Println(val)
+		return nil
+	case "rm":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: secret rm <name>")
+		}
+		if err := store.Remove(args[1]); err != nil {
+			return err
+		}
+		fmt.Println("Removed secret:", args[1])
+		return nil
+	case "list":
+		if len(args) > 1 {
+			return fmt.Errorf("unknown arg: %s", args[1])
+		}
+		names, err := store.List()
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			fmt.Println("No secrets stored")
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown secret subcommand: %s", args[0])
+	}
+}
+
+func set(store secrets.Store, name, value string) error {
+	if value == "" {
+		return fmt.Errorf("secret %s: empty value", name)
+	}
+	if err := store.Set(name, value); err != nil {
+		return err
+	}
+	fmt.Printf("Stored secret %s; use %s%s in config values\n", name, secrets.SchemeSecret, name)
+	return nil
+}
+
+// readValue reads the secret from stdin so it stays out of shell history.
+// On a terminal the input is not echoed.
+func readValue(rt appruntime.Runtime, name string) (string, error) {
+	if err := secrets.ValidateName(name); err != nil {
+		return "", err
+	}
+	if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		if rt.NonInteractive {
+			return "", fmt.Errorf("missing <value> (pass it or pipe it to stdin): %w", cli.ErrNonInteractive)
+		}
+		fmt.Printf("Value for %s: ", name)
+		if err := rt.Runner.Run("stty", "-echo"); err == nil {
+			defer func() {
+				_ = rt.Runner.Run("stty", "echo")
+				fmt.Println()
+			}()
+		}
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	b, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -12,19 +15,122 @@ type SocksConfig struct {
 	Port int    `yaml:"port"`
 }
 
+// TunConfig configures the tun device. Mode selects what goes through it:
+// full (the default route), include (only Routes and Domains) or exclude
+// (everything except Routes and Domains). Domains are resolved to IPv4
+// routes when init runs. Bypass lists extra IPs or CIDRs that always use
+// the original default route, like the SOCKS gateway and the eth0 subnet.
 type TunConfig struct {
-	Dev  string `yaml:"dev"`
-	CIDR string `yaml:"cidr"`
+	Dev     string   `yaml:"dev"`
+	CIDR    string   `yaml:"cidr"`
+	Mode    string   `yaml:"mode,omitempty"`
+	Routes  []string `yaml:"routes,omitempty"`
+	Domains []string `yaml:"domains,omitempty"`
+	Bypass  []string `yaml:"bypass,omitempty"`
 }
 
 type DNSConfig struct {
 	Nameserver string `yaml:"nameserver"`
 }
 
+// DBRouteRule routes client connections whose startup parameters match glob
+// patterns. Empty patterns match anything, but at least one must be set.
+// Rules with an empty Service resolve the requested database via the endpoint
+// mask; Role overrides the preferred endpoint role for the rule.
+type DBRouteRule struct {
+	Database        string `yaml:"database,omitempty"`
+	User            string `yaml:"user,omitempty"`
+	ApplicationName string `yaml:"application_name,omitempty"`
+	Service         string `yaml:"service,omitempty"`
+	Role            string `yaml:"role,omitempty"`
+}
+
+// DBEndpointMapping maps a non-standard HTTP service discovery response to
+// endpoints. Each field is a JSONPath-like selector such as `$.hosts` or
+// `meta.role`; List selects the array of entries, the rest are relative to
+// an entry. Address may be replaced by Host and Port.
+type DBEndpointMapping struct {
+	List     string `yaml:"list,omitempty"`
+	Address  string `yaml:"address,omitempty"`
+	Host     string `yaml:"host,omitempty"`
+	Port     string `yaml:"port,omitempty"`
+	Instance string `yaml:"instance,omitempty"`
+	Role     string `yaml:"role,omitempty"`
+	Weight   string `yaml:"weight,omitempty"`
+	Default  string `yaml:"default_route,omitempty"`
+	Release  string `yaml:"release,omitempty"`
+	Version  string `yaml:"version,omitempty"`
+}
+
+// DBDiscoveryAuth configures authentication for HTTP service discovery
+// requests. Secrets are not stored inline: Token and Password hold secret
+// references (`db init` saves them as secret://name), or the bearer token and
+// the basic auth password are read from files (or the token from a command
+// output).
+type DBDiscoveryAuth struct {
+	Method       string            `yaml:"method,omitempty"`
+	Headers      map[string]string `yaml:"headers,omitempty"`
+	Token        string            `yaml:"token,omitempty"`
+	TokenFile    string            `yaml:"token_file,omitempty"`
+	TokenCommand string            `yaml:"token_command,omitempty"`
+	Username     string            `yaml:"username,omitempty"`
+	Password     string            `yaml:"password,omitempty"`
+	PasswordFile string            `yaml:"password_file,omitempty"`
+	ClientCert   string            `yaml:"client_cert,omitempty"`
+	ClientKey    string            `yaml:"client_key,omitempty"`
+	CAFile       string            `yaml:"ca_file,omitempty"`
+}
+
+// IsZero reports whether no auth settings are configured.
+func (a DBDiscoveryAuth) IsZero() bool {
+	return a.Method == "" && len(a.Headers) == 0 && a.Token == "" && a.TokenFile == "" && a.TokenCommand == "" &&
+		a.Username == "" && a.Password == "" && a.PasswordFile == "" && a.ClientCert == "" && a.ClientKey == "" && a.CAFile == ""
+}
+
+// DBEnvironment holds the discovery settings and services of a named
+// environment that is not active. The active environment lives in the
+// top-level DBConfig fields and is swapped with `db env use`.
+type DBEnvironment struct {
+	DiscoveryProvider      string            `yaml:"discovery_provider,omitempty"`
+	DiscoveryServiceMask   string            `yaml:"discovery_service_mask,omitempty"`
+	DiscoveryFile          string            `yaml:"discovery_file,omitempty"`
+	ServiceDiscoveryScheme string            `yaml:"service_discovery_scheme,omitempty"`
+	ServiceDiscoveryHost   string            `yaml:"service_discovery_host,omitempty"`
+	ServiceDiscoveryHosts  []string          `yaml:"service_discovery_hosts,omitempty"`
+	DiscoveryHostOrder     string            `yaml:"discovery_host_order,omitempty"`
+	DiscoveryAttempts      int               `yaml:"discovery_attempts,omitempty"`
+	DiscoveryBackoff       string            `yaml:"discovery_backoff,omitempty"`
+	EndpointMask           string            `yaml:"endpoint_mask,omitempty"`
+	CatalogMask            string            `yaml:"catalog_mask,omitempty"`
+	EndpointMapping        DBEndpointMapping `yaml:"endpoint_mapping,omitempty"`
+	DiscoveryAuth          DBDiscoveryAuth   `yaml:"discovery_auth,omitempty"`
+	DiscoveryCacheTTL      string            `yaml:"discovery_cache_ttl,omitempty"`
+	PreferRole             string            `yaml:"prefer_role,omitempty"`
+	ServiceName            string            `yaml:"service_name,omitempty"`
+	ServiceNames           []string          `yaml:"service_names,omitempty"`
+	ServiceTargets         map[string]string `yaml:"service_targets,omitempty"`
+	ServiceInstances       map[string]string `yaml:"service_instances,omitempty"`
+	PinnedInstances        map[string]string `yaml:"pinned_instances,omitempty"`
+	PinnedAddresses        map[string]string `yaml:"pinned_addresses,omitempty"`
+	ServiceReleases        map[string]string `yaml:"service_releases,omitempty"`
+	ServiceVersions        map[string]string `yaml:"service_versions,omitempty"`
+}
+
 type DBConfig struct {
+	DiscoveryProvider      string
+	DiscoveryServiceMask   string
+	DiscoveryFile          string
 	ServiceDiscoveryScheme string
 	ServiceDiscoveryHost   string
+	ServiceDiscoveryHosts  []string
+	DiscoveryHostOrder     string
+	DiscoveryAttempts      int
+	DiscoveryBackoff       string
 	EndpointMask           string
+	CatalogMask            string
+	EndpointMapping        DBEndpointMapping
+	DiscoveryAuth          DBDiscoveryAuth
+	DiscoveryCacheTTL      string
 	AuthLookupUser         string
 	AuthLookupPass         string
 	AuthQuery              string
@@ -33,12 +139,78 @@ type DBConfig struct {
 	ServicePorts           map[string]int
 	ServiceTargets         map[string]string
 	ServiceInstances       map[string]string
-	ServiceDiscoveryURL    string
+	PinnedInstances        map[string]string
+	PinnedAddresses        map[string]string
+	ServiceReleases        map[string]string
+	ServiceVersions        map[string]string
 	LocalHost              string
 	LocalPort              int
 	PreferRole             string
 	TargetAddress          string
 	TargetInstance         string
+	AutoResolve            bool
+	AutoResolvePattern     string
+	RouteRules             []DBRouteRule
+	Environment            string
+	Environments           map[string]DBEnvironment
+}
+
+// ActiveEnvironment returns the environment settings held in the top-level fields.
+func (c DBConfig) ActiveEnvironment() DBEnvironment {
+	return DBEnvironment{
+		DiscoveryProvider:      c.DiscoveryProvider,
+		DiscoveryServiceMask:   c.DiscoveryServiceMask,
+		DiscoveryFile:          c.DiscoveryFile,
+		ServiceDiscoveryScheme: c.ServiceDiscoveryScheme,
+		ServiceDiscoveryHost:   c.ServiceDiscoveryHost,
+		ServiceDiscoveryHosts:  c.ServiceDiscoveryHosts,
+		DiscoveryHostOrder:     c.DiscoveryHostOrder,
+		DiscoveryAttempts:      c.DiscoveryAttempts,
+		DiscoveryBackoff:       c.DiscoveryBackoff,
+		EndpointMask:           c.EndpointMask,
+		CatalogMask:            c.CatalogMask,
+		EndpointMapping:        c.EndpointMapping,
+		DiscoveryAuth:          c.DiscoveryAuth,
+		DiscoveryCacheTTL:      c.DiscoveryCacheTTL,
+		PreferRole:             c.PreferRole,
+		ServiceName:            c.ServiceName,
+		ServiceNames:           c.ServiceNames,
+		ServiceTargets:         c.ServiceTargets,
+		ServiceInstances:       c.ServiceInstances,
+		PinnedInstances:        c.PinnedInstances,
+		PinnedAddresses:        c.PinnedAddresses,
+		ServiceReleases:        c.ServiceReleases,
+		ServiceVersions:        c.ServiceVersions,
+	}
+}
+
+// ApplyEnvironment replaces the top-level environment fields with e.
+func (c *DBConfig) ApplyEnvironment(e DBEnvironment) {
+	c.DiscoveryProvider = e.DiscoveryProvider
+	c.DiscoveryServiceMask = e.DiscoveryServiceMask
+	c.DiscoveryFile = e.DiscoveryFile
+	c.ServiceDiscoveryScheme = e.ServiceDiscoveryScheme
+	c.ServiceDiscoveryHost = e.ServiceDiscoveryHost
+	c.ServiceDiscoveryHosts = e.ServiceDiscoveryHosts
+	c.DiscoveryHostOrder = e.DiscoveryHostOrder
+	c.DiscoveryAttempts = e.DiscoveryAttempts
+	c.DiscoveryBackoff = e.DiscoveryBackoff
+	c.EndpointMask = e.EndpointMask
+	c.CatalogMask = e.CatalogMask
+	c.EndpointMapping = e.EndpointMapping
+	c.DiscoveryAuth = e.DiscoveryAuth
+	c.DiscoveryCacheTTL = e.DiscoveryCacheTTL
+	c.PreferRole = e.PreferRole
+	c.ServiceName = e.ServiceName
+	c.ServiceNames = e.ServiceNames
+	c.ServiceTargets = e.ServiceTargets
+	c.ServiceInstances = e.ServiceInstances
+	c.PinnedInstances = e.PinnedInstances
+	c.PinnedAddresses = e.PinnedAddresses
+	c.ServiceReleases = e.ServiceReleases
+	c.ServiceVersions = e.ServiceVersions
+	c.TargetAddress = ""
+	c.TargetInstance = ""
 }
 
 // Config holds wslbridge configuration.
@@ -47,40 +219,76 @@ type Config struct {
 	Tun   TunConfig
 	DNS   DNSConfig
 	DB    DBConfig
+
+	// secrets maps the path of every field loaded from a secret reference
+	// to that reference; writes put it back while the field still holds
+	// the resolved value.
+	secrets map[string]secretRef
 }
 
 type dbDiskConfig struct {
-	ServiceDiscoveryScheme string            `yaml:"service_discovery_scheme,omitempty"`
-	ServiceDiscoveryHost   string            `yaml:"service_discovery_host,omitempty"`
-	EndpointMask           string            `yaml:"endpoint_mask,omitempty"`
-	AuthLookupUser         string            `yaml:"auth_lookup_user,omitempty"`
-	AuthLookupPass         string            `yaml:"auth_lookup_password,omitempty"`
-	AuthQuery              string            `yaml:"auth_query,omitempty"`
-	ServiceName            string            `yaml:"service_name,omitempty"`
-	ServiceNames           []string          `yaml:"service_names,omitempty"`
-	ServicePorts           map[string]int    `yaml:"service_ports,omitempty"`
-	ServiceTargets         map[string]string `yaml:"service_targets,omitempty"`
-	ServiceInstances       map[string]string `yaml:"service_instances,omitempty"`
-	ServiceDiscoveryURL    string            `yaml:"service_discovery_url,omitempty"`
-	LocalHost              string            `yaml:"local_host,omitempty"`
-	LocalPort              int               `yaml:"local_port,omitempty"`
-	PreferRole             string            `yaml:"prefer_role,omitempty"`
-	TargetAddress          string            `yaml:"target_address,omitempty"`
-	TargetInstance         string            `yaml:"target_instance,omitempty"`
+	DiscoveryProvider      string                   `yaml:"discovery_provider,omitempty"`
+	DiscoveryServiceMask   string                   `yaml:"discovery_service_mask,omitempty"`
+	DiscoveryFile          string                   `yaml:"discovery_file,omitempty"`
+	ServiceDiscoveryScheme string                   `yaml:"service_discovery_scheme,omitempty"`
+	ServiceDiscoveryHost   string                   `yaml:"service_discovery_host,omitempty"`
+	ServiceDiscoveryHosts  []string                 `yaml:"service_discovery_hosts,omitempty"`
+	DiscoveryHostOrder     string                   `yaml:"discovery_host_order,omitempty"`
+	DiscoveryAttempts      int                      `yaml:"discovery_attempts,omitempty"`
+	DiscoveryBackoff       string                   `yaml:"discovery_backoff,omitempty"`
+	EndpointMask           string                   `yaml:"endpoint_mask,omitempty"`
+	CatalogMask            string                   `yaml:"catalog_mask,omitempty"`
+	EndpointMapping        DBEndpointMapping        `yaml:"endpoint_mapping,omitempty"`
+	DiscoveryAuth          DBDiscoveryAuth          `yaml:"discovery_auth,omitempty"`
+	DiscoveryCacheTTL      string                   `yaml:"discovery_cache_ttl,omitempty"`
+	AuthLookupUser         string                   `yaml:"auth_lookup_user,omitempty"`
+	AuthLookupPass         string                   `yaml:"auth_lookup_password,omitempty"`
+	AuthQuery              string                   `yaml:"auth_query,omitempty"`
+	ServiceName            string                   `yaml:"service_name,omitempty"`
+	ServiceNames           []string                 `yaml:"service_names,omitempty"`
+	ServicePorts           map[string]int           `yaml:"service_ports,omitempty"`
+	ServiceTargets         map[string]string        `yaml:"service_targets,omitempty"`
+	ServiceInstances       map[string]string        `yaml:"service_instances,omitempty"`
+	PinnedInstances        map[string]string        `yaml:"pinned_instances,omitempty"`
+	PinnedAddresses        map[string]string        `yaml:"pinned_addresses,omitempty"`
+	ServiceReleases        map[string]string        `yaml:"service_releases,omitempty"`
+	ServiceVersions        map[string]string        `yaml:"service_versions,omitempty"`
+	LocalHost              string                   `yaml:"local_host,omitempty"`
+	LocalPort              int                      `yaml:"local_port,omitempty"`
+	PreferRole             string                   `yaml:"prefer_role,omitempty"`
+	TargetAddress          string                   `yaml:"target_address,omitempty"`
+	TargetInstance         string                   `yaml:"target_instance,omitempty"`
+	AutoResolve            bool                     `yaml:"auto_resolve,omitempty"`
+	AutoResolvePattern     string                   `yaml:"auto_resolve_pattern,omitempty"`
+	RouteRules             []DBRouteRule            `yaml:"route_rules,omitempty"`
+	Environment            string                   `yaml:"environment,omitempty"`
+	Environments           map[string]DBEnvironment `yaml:"environments,omitempty"`
 }
 
 type configDisk struct {
-	Socks SocksConfig  `yaml:"socks"`
-	Tun   TunConfig    `yaml:"tun"`
-	DNS   DNSConfig    `yaml:"dns"`
-	DB    dbDiskConfig `yaml:"db"`
+	Version int          `yaml:"version"`
+	Socks   SocksConfig  `yaml:"socks"`
+	Tun     TunConfig    `yaml:"tun"`
+	DNS     DNSConfig    `yaml:"dns"`
+	DB      dbDiskConfig `yaml:"db"`
 }
 
 func (d dbDiskConfig) toRuntime() DBConfig {
 	return DBConfig{
+		DiscoveryProvider:      d.DiscoveryProvider,
+		DiscoveryServiceMask:   d.DiscoveryServiceMask,
+		DiscoveryFile:          d.DiscoveryFile,
 		ServiceDiscoveryScheme: d.ServiceDiscoveryScheme,
 		ServiceDiscoveryHost:   d.ServiceDiscoveryHost,
+		ServiceDiscoveryHosts:  d.ServiceDiscoveryHosts,
+		DiscoveryHostOrder:     d.DiscoveryHostOrder,
+		DiscoveryAttempts:      d.DiscoveryAttempts,
+		DiscoveryBackoff:       d.DiscoveryBackoff,
 		EndpointMask:           d.EndpointMask,
+		CatalogMask:            d.CatalogMask,
+		EndpointMapping:        d.EndpointMapping,
+		DiscoveryAuth:          d.DiscoveryAuth,
+		DiscoveryCacheTTL:      d.DiscoveryCacheTTL,
 		AuthLookupUser:         d.AuthLookupUser,
 		AuthLookupPass:         d.AuthLookupPass,
 		AuthQuery:              d.AuthQuery,
@@ -89,19 +297,38 @@ func (d dbDiskConfig) toRuntime() DBConfig {
 		ServicePorts:           d.ServicePorts,
 		ServiceTargets:         d.ServiceTargets,
 		ServiceInstances:       d.ServiceInstances,
-		ServiceDiscoveryURL:    d.ServiceDiscoveryURL,
+		PinnedInstances:        d.PinnedInstances,
+		PinnedAddresses:        d.PinnedAddresses,
+		ServiceReleases:        d.ServiceReleases,
+		ServiceVersions:        d.ServiceVersions,
 		LocalHost:              d.LocalHost,
 		LocalPort:              d.LocalPort,
 		PreferRole:             d.PreferRole,
 		TargetAddress:          d.TargetAddress,
 		TargetInstance:         d.TargetInstance,
+		AutoResolve:            d.AutoResolve,
+		AutoResolvePattern:     d.AutoResolvePattern,
+		RouteRules:             d.RouteRules,
+		Environment:            d.Environment,
+		Environments:           d.Environments,
 	}
 }
 
 func (d dbDiskConfig) isZero() bool {
-	return d.ServiceDiscoveryScheme == "" &&
+	return d.DiscoveryProvider == "" &&
+		d.DiscoveryServiceMask == "" &&
+		d.DiscoveryFile == "" &&
+		d.ServiceDiscoveryScheme == "" &&
 		d.ServiceDiscoveryHost == "" &&
+		len(d.ServiceDiscoveryHosts) == 0 &&
+		d.DiscoveryHostOrder == "" &&
+		d.DiscoveryAttempts == 0 &&
+		d.DiscoveryBackoff == "" &&
 		d.EndpointMask == "" &&
+		d.CatalogMask == "" &&
+		d.EndpointMapping == (DBEndpointMapping{}) &&
+		d.DiscoveryAuth.IsZero() &&
+		d.DiscoveryCacheTTL == "" &&
 		d.AuthLookupUser == "" &&
 		d.AuthLookupPass == "" &&
 		d.AuthQuery == "" &&
@@ -110,19 +337,38 @@ func (d dbDiskConfig) isZero() bool {
 		len(d.ServicePorts) == 0 &&
 		len(d.ServiceTargets) == 0 &&
 		len(d.ServiceInstances) == 0 &&
-		d.ServiceDiscoveryURL == "" &&
+		len(d.PinnedInstances) == 0 &&
+		len(d.PinnedAddresses) == 0 &&
+		len(d.ServiceReleases) == 0 &&
+		len(d.ServiceVersions) == 0 &&
 		d.LocalHost == "" &&
 		d.LocalPort == 0 &&
 		d.PreferRole == "" &&
 		d.TargetAddress == "" &&
-		d.TargetInstance == ""
+		d.TargetInstance == "" &&
+		!d.AutoResolve &&
+		d.AutoResolvePattern == "" &&
+		len(d.RouteRules) == 0 &&
+		d.Environment == "" &&
+		len(d.Environments) == 0
 }
 
 func dbDiskFromRuntime(c DBConfig) dbDiskConfig {
 	return dbDiskConfig{
+		DiscoveryProvider:      c.DiscoveryProvider,
+		DiscoveryServiceMask:   c.DiscoveryServiceMask,
+		DiscoveryFile:          c.DiscoveryFile,
 		ServiceDiscoveryScheme: c.ServiceDiscoveryScheme,
 		ServiceDiscoveryHost:   c.ServiceDiscoveryHost,
+		ServiceDiscoveryHosts:  c.ServiceDiscoveryHosts,
+		DiscoveryHostOrder:     c.DiscoveryHostOrder,
+		DiscoveryAttempts:      c.DiscoveryAttempts,
+		DiscoveryBackoff:       c.DiscoveryBackoff,
 		EndpointMask:           c.EndpointMask,
+		CatalogMask:            c.CatalogMask,
+		EndpointMapping:        c.EndpointMapping,
+		DiscoveryAuth:          c.DiscoveryAuth,
+		DiscoveryCacheTTL:      c.DiscoveryCacheTTL,
 		AuthLookupUser:         c.AuthLookupUser,
 		AuthLookupPass:         c.AuthLookupPass,
 		AuthQuery:              c.AuthQuery,
@@ -131,51 +377,144 @@ func dbDiskFromRuntime(c DBConfig) dbDiskConfig {
 		ServicePorts:           c.ServicePorts,
 		ServiceTargets:         c.ServiceTargets,
 		ServiceInstances:       c.ServiceInstances,
-		ServiceDiscoveryURL:    c.ServiceDiscoveryURL,
+		PinnedInstances:        c.PinnedInstances,
+		PinnedAddresses:        c.PinnedAddresses,
+		ServiceReleases:        c.ServiceReleases,
+		ServiceVersions:        c.ServiceVersions,
 		LocalHost:              c.LocalHost,
 		LocalPort:              c.LocalPort,
 		PreferRole:             c.PreferRole,
 		TargetAddress:          c.TargetAddress,
 		TargetInstance:         c.TargetInstance,
+		AutoResolve:            c.AutoResolve,
+		AutoResolvePattern:     c.AutoResolvePattern,
+		RouteRules:             c.RouteRules,
+		Environment:            c.Environment,
+		Environments:           c.Environments,
 	}
 }
 
-// Load reads config from the given path.
-func Load(path string) (Config, error) {
-	b, err := os.ReadFile(path)
+// Load reads config from the given path, merged over the base config it
+// extends, if any. Files written by older versions are migrated in memory
+// only; Save and Migrate write the upgrade. secret:// references resolve
+// from the first of secretStores that has the name.
+func Load(path string, secretStores ...string) (Config, error) {
+	disk, err := loadDisk(path)
 	if err != nil {
 		return Config{}, err
 	}
+	refs := resolveSecrets(&disk, secretStores)
+	c := runtimeFromDisk(disk)
+	c.secrets = refs
+	return c, nil
+}
+
+// loadDisk is Load without resolving secret references.
+func loadDisk(path string) (configDisk, error) {
+	tree, err := loadYAMLTree(path, 0)
+	if err != nil {
+		return configDisk{}, err
+	}
+	b, err := yaml.Marshal(tree)
+	if err != nil {
+		return configDisk{}, err
+	}
 
 	var disk configDisk
 	if err := yaml.Unmarshal(b, &disk); err != nil {
+		return configDisk{}, err
+	}
+	return disk, nil
+}
+
+// LoadStrict is Load that also rejects unknown keys in the file at path.
+// Keys of older schema versions are migrated before the check.
+func LoadStrict(path string, secretStores ...string) (Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
 		return Config{}, err
 	}
+	tree := map[string]any{}
+	if err := yaml.Unmarshal(b, &tree); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if tree == nil {
+		tree = map[string]any{}
+	}
+	if _, err := migrateTree(path, tree); err != nil {
+		return Config{}, err
+	}
+	if keys := unknownKeys(tree, reflect.TypeOf(configDisk{}), ""); len(keys) > 0 {
+		return Config{}, fmt.Errorf("%s: unknown keys: %s", path, strings.Join(keys, ", "))
+	}
+	return Load(path, secretStores...)
+}
 
-	return Config{
-		Socks: disk.Socks,
-		Tun:   disk.Tun,
-		DNS:   disk.DNS,
-		DB:    disk.DB.toRuntime(),
-	}, nil
+// Marshal returns c in the on-disk YAML format. Resolved secrets are
+// written as the references they were loaded from.
+func Marshal(c Config) ([]byte, error) {
+	return yaml.Marshal(writableDisk(c))
 }
 
-// Save writes config to the given path.
+// Save writes config to the given path. When the file extends a base config
+// only the values that differ from the base are written. A file of an older
+// schema version is backed up first.
 func Save(path string, c Config) error {
-	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+	backup, err := save(path, c)
+	if err != nil {
 		return err
 	}
+	if backup != "" {
+		warnf("%s: upgraded config to version %d (backup: %s)", path, CurrentVersion, backup)
+	}
+	return nil
+}
 
-	disk := configDisk{
-		Socks: c.Socks,
-		Tun:   c.Tun,
-		DNS:   c.DNS,
-		DB:    dbDiskFromRuntime(c.DB),
+// save is Save that returns the backup of an upgraded file.
+func save(path string, c Config) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
 	}
 
-	b, err := yaml.Marshal(disk)
+	b, err := Marshal(c)
 	if err != nil {
-		return err
+		return "", err
+	}
+	if extends := ReadExtends(path); extends != "" {
+		if b, err = marshalOverBase(path, extends, c); err != nil {
+			return "", err
+		}
+	}
+	backup, err := backupBeforeUpgrade(path)
+	if err != nil {
+		return "", err
+	}
+	return backup, os.WriteFile(path, b, 0o600)
+}
+
+func runtimeFromDisk(d configDisk) Config {
+	return Config{
+		Socks: d.Socks,
+		Tun:   d.Tun,
+		DNS:   d.DNS,
+		DB:    d.DB.toRuntime(),
+	}
+}
+
+// writableDisk is diskFromRuntime with secret values replaced by their
+// references.
+func writableDisk(c Config) configDisk {
+	d := diskFromRuntime(c)
+	hideSecrets(&d, c.secrets)
+	return d
+}
+
+func diskFromRuntime(c Config) configDisk {
+	return configDisk{
+		Version: CurrentVersion,
+		Socks:   c.Socks,
+		Tun:     c.Tun,
+		DNS:     c.DNS,
+		DB:      dbDiskFromRuntime(c.DB),
 	}
-	return os.WriteFile(path, b, 0o600)
 }
@@ -16,19 +16,55 @@ func TestSaveLoad(t *testing.T) {
 	want.Socks.Port = 1080
 	want.Tun.Dev = "tun0"
 	want.Tun.CIDR = "10.0.0.2/24"
+	want.Tun.Mode = "include"
+	want.Tun.Routes = []string{"10.20.0.0/16", "192.168.7.10"}
+	want.Tun.Domains = []string{"git.example.internal"}
+	want.Tun.Bypass = []string{"192.168.0.0/16"}
 	want.DNS.Nameserver = "8.8.8.8"
+	want.DB.DiscoveryProvider = "consul"
+	want.DB.DiscoveryServiceMask = "<db>-pg"
 	want.DB.ServiceDiscoveryScheme = "http"
 	want.DB.ServiceDiscoveryHost = "service-discovery.example.internal"
+	want.DB.ServiceDiscoveryHosts = []string{"sd-2.example.internal", "sd-3.example.internal"}
+	want.DB.DiscoveryHostOrder = "random"
+	want.DB.DiscoveryAttempts = 4
+	want.DB.DiscoveryBackoff = "250ms"
 	want.DB.EndpointMask = "/endpoints?service=<db>.pg:bouncer"
+	want.DB.CatalogMask = "/services"
+	want.DB.EndpointMapping = DBEndpointMapping{List: "$.hosts", Host: "host", Port: "port", Role: "tags"}
+	want.DB.DiscoveryAuth = DBDiscoveryAuth{Method: "bearer", Headers: map[string]string{"X-Team": "db"}, TokenFile: "/tmp/token", CAFile: "/tmp/ca.pem"}
+	want.DB.DiscoveryCacheTTL = "72h"
 	want.DB.AuthLookupUser = "db_auth"
 	want.DB.AuthLookupPass = "secret"
 	want.DB.AuthQuery = "SELECT usename, passwd FROM pg_catalog.pg_shadow WHERE usename=$1"
 	want.DB.ServiceName = "analytics-db"
 	want.DB.ServiceNames = []string{"analytics-db"}
 	want.DB.ServiceTargets = map[string]string{"analytics-db": "10.0.0.1:6432"}
+	want.DB.PinnedInstances = map[string]string{"analytics-db": "db-b"}
+	want.DB.PinnedAddresses = map[string]string{"reports-db": "10.0.0.9:6432"}
+	want.DB.ServiceReleases = map[string]string{"analytics-db": "blue"}
+	want.DB.ServiceVersions = map[string]string{"analytics-db": ">=15"}
 	want.DB.LocalHost = "127.0.0.1"
 	want.DB.LocalPort = 15432
 	want.DB.PreferRole = "master"
+	want.DB.AutoResolve = true
+	want.DB.AutoResolvePattern = "*-db"
+	want.DB.RouteRules = []DBRouteRule{
+		{Database: "tenant_*", Service: "tenants-shard"},
+		{Database: "*-db"},
+		{User: "reporting", Service: "analytics-db", Role: "async"},
+		{ApplicationName: "sandbox-*", Service: "sandbox-db"},
+	}
+	want.DB.Environment = "stage"
+	want.DB.Environments = map[string]DBEnvironment{
+		"prod": {
+			ServiceDiscoveryScheme: "https",
+			ServiceDiscoveryHost:   "sd.prod.example.internal",
+			EndpointMask:           "/endpoints?service=<db>",
+			ServiceNames:           []string{"analytics-db"},
+			ServiceTargets:         map[string]string{"analytics-db": "10.1.0.1:6432"},
+		},
+	}
 
 	if err := Save(path, want); err != nil {
 		t.Fatalf("Save error: %v", err)
@@ -43,3 +79,28 @@ func TestSaveLoad(t *testing.T) {
 		t.Fatalf("Load mismatch: want %+v, got %+v", want, got)
 	}
 }
+
+// TestApplyEnvironment verifies that environment fields round-trip through
+// the top-level db config.
+func TestApplyEnvironment(t *testing.T) {
+	env := DBEnvironment{
+		DiscoveryProvider:    "consul",
+		DiscoveryServiceMask: "<db>-pg",
+		ServiceDiscoveryHost: "consul.dev.example.internal",
+		PreferRole:           "sync",
+		ServiceName:          "example-db",
+		ServiceNames:         []string{"example-db"},
+		ServiceTargets:       map[string]string{"example-db": "10.0.0.1:6432"},
+		PinnedInstances:      map[string]string{"example-db": "db-a"},
+	}
+	var c DBConfig
+	c.TargetAddress = "10.9.9.9:6432"
+	c.LocalPort = 15432
+	c.ApplyEnvironment(env)
+	if !reflect.DeepEqual(c.ActiveEnvironment(), env) {
+		t.Fatalf("ActiveEnvironment() = %+v, want %+v", c.ActiveEnvironment(), env)
+	}
+	if c.TargetAddress != "" || c.LocalPort != 15432 {
+		t.Fatalf("ApplyEnvironment() target=%q port=%d", c.TargetAddress, c.LocalPort)
+	}
+}
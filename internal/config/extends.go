@@ -0,0 +1,174 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A config file may inherit from a base file with a top-level `extends` key.
+// The value is either a sibling profile name (`office` -> office.yaml) or a
+// path relative to the file. Load merges the file over its base; Save writes
+// only the keys that differ from the base so later base changes still apply.
+
+const extendsKey = "extends"
+
+// maxExtendsDepth bounds `extends` chains and catches cycles.
+const maxExtendsDepth = 8
+
+// ReadExtends returns the `extends` value of the config file at path, or ""
+// when the file does not exist or extends nothing.
+func ReadExtends(path string) string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	var head struct {
+		Extends string `yaml:"extends"`
+	}
+	if err := yaml.Unmarshal(b, &head); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(head.Extends)
+}
+
+// ResolveExtends returns the path of the base config named by extends in
+// the config file at path.
+func ResolveExtends(path, extends string) string {
+	val := strings.TrimSpace(extends)
+	ext := filepath.Ext(val)
+	if !strings.ContainsRune(val, filepath.Separator) && !strings.ContainsRune(val, '/') && ext != ".yaml" && ext != ".yml" {
+		return filepath.Join(filepath.Dir(path), val+".yaml")
+	}
+	if filepath.IsAbs(val) {
+		return val
+	}
+	return filepath.Join(filepath.Dir(path), val)
+}
+
+// loadYAMLTree reads the config at path as a generic YAML tree with its
+// `extends` chain merged in.
+func loadYAMLTree(path string, depth int) (map[string]any, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tree := map[string]any{}
+	if err := yaml.Unmarshal(b, &tree); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if tree == nil {
+		tree = map[string]any{}
+	}
+	if _, err := migrateTree(path, tree); err != nil {
+		return nil, err
+	}
+	if keys := unknownKeys(tree, reflect.TypeOf(configDisk{}), ""); len(keys) > 0 {
+		warnf("%s: unknown keys %s are ignored and will be dropped on the next save", path, strings.Join(keys, ", "))
+	}
+	delete(tree, versionKey)
+	extends, _ := tree[extendsKey].(string)
+	delete(tree, extendsKey)
+	if strings.TrimSpace(extends) == "" {
+		return tree, nil
+	}
+	if depth >= maxExtendsDepth {
+		return nil, fmt.Errorf("%s: extends chain is deeper than %d (cycle?)", path, maxExtendsDepth)
+	}
+	base, err := loadYAMLTree(ResolveExtends(path, extends), depth+1)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%s: base config %q not found", path, extends)
+		}
+		return nil, err
+	}
+	return mergeYAMLTrees(base, tree), nil
+}
+
+// mergeYAMLTrees overlays over on base. Nested mappings merge, everything
+// else (including lists) is replaced.
+func mergeYAMLTrees(base, over map[string]any) map[string]any {
+	out := make(map[string]any, len(base)+len(over))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range over {
+		bm, bok := out[k].(map[string]any)
+		om, ook := v.(map[string]any)
+		if bok && ook {
+			out[k] = mergeYAMLTrees(bm, om)
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// diffYAMLTrees returns the parts of cur that differ from base.
+func diffYAMLTrees(cur, base map[string]any) map[string]any {
+	out := map[string]any{}
+	for k, v := range cur {
+		bv, ok := base[k]
+		if ok && reflect.DeepEqual(v, bv) {
+			continue
+		}
+		cm, cok := v.(map[string]any)
+		bm, bok := bv.(map[string]any)
+		if cok && bok {
+			if d := diffYAMLTrees(cm, bm); len(d) > 0 {
+				out[k] = d
+			}
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+func toYAMLTree(v any) (map[string]any, error) {
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	tree := map[string]any{}
+	if err := yaml.Unmarshal(b, &tree); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// marshalOverBase returns the config file body that keeps extends and
+// stores only the values of c that differ from the base.
+func marshalOverBase(path, extends string, c Config) ([]byte, error) {
+	// The base is compared as written, with its references unresolved.
+	baseDisk, err := loadDisk(ResolveExtends(path, extends))
+	if err != nil {
+		return nil, err
+	}
+	base, err := toYAMLTree(writableDisk(runtimeFromDisk(baseDisk)))
+	if err != nil {
+		return nil, err
+	}
+	cur, err := toYAMLTree(writableDisk(c))
+	if err != nil {
+		return nil, err
+	}
+	head, err := yaml.Marshal(map[string]any{extendsKey: extends, versionKey: CurrentVersion})
+	if err != nil {
+		return nil, err
+	}
+	diff := diffYAMLTrees(cur, base)
+	if len(diff) == 0 {
+		return head, nil
+	}
+	body, err := yaml.Marshal(diff)
+	if err != nil {
+		return nil, err
+	}
+	return append(head, body...), nil
+}
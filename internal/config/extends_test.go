@@ -0,0 +1,94 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestLoadSave_Extends verifies that a config inherits its base and that
+// Save keeps only the overridden values.
+func TestLoadSave_Extends(t *testing.T) {
+	dir := t.TempDir()
+	basePath := filepath.Join(dir, "config.yaml")
+	profilePath := filepath.Join(dir, "profiles", "office.yaml")
+
+	var base Config
+	base.Socks.Host = "127.0.0.1"
+	base.Socks.Port = 1080
+	base.DB.LocalPort = 5432
+	base.DB.ServiceNames = []string{"example-db"}
+	if err := Save(basePath, base); err != nil {
+		t.Fatalf("Save(base) error: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(profilePath), 0o755); err != nil {
+		t.Fatalf("MkdirAll error: %v", err)
+	}
+	if err := os.WriteFile(profilePath, []byte("extends: ../config.yaml\nsocks:\n  port: 1081\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+
+	got, err := Load(profilePath)
+	if err != nil {
+		t.Fatalf("Load(profile) error: %v", err)
+	}
+	if got.Socks.Host != "127.0.0.1" || got.Socks.Port != 1081 || got.DB.LocalPort != 5432 {
+		t.Fatalf("Load(profile) = %+v", got)
+	}
+
+	got.DB.LocalPort = 6432
+	if err := Save(profilePath, got); err != nil {
+		t.Fatalf("Save(profile) error: %v", err)
+	}
+	b, err := os.ReadFile(profilePath)
+	if err != nil {
+		t.Fatalf("ReadFile error: %v", err)
+	}
+	body := string(b)
+	if !strings.HasPrefix(body, "extends: ../config.yaml\n") || strings.Contains(body, "127.0.0.1") || strings.Contains(body, "example-db") {
+		t.Fatalf("saved profile keeps inherited values:\n%s", body)
+	}
+
+	base.DB.ServiceNames = []string{"billing-db"}
+	if err := Save(basePath, base); err != nil {
+		t.Fatalf("Save(base) error: %v", err)
+	}
+	got, err = Load(profilePath)
+	if err != nil {
+		t.Fatalf("Load(profile) error: %v", err)
+	}
+	if got.DB.LocalPort != 6432 || got.Socks.Port != 1081 || len(got.DB.ServiceNames) != 1 || got.DB.ServiceNames[0] != "billing-db" {
+		t.Fatalf("Load(profile) after base change = %+v", got)
+	}
+}
+
+func TestLoad_ExtendsCycle(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.yaml"), []byte("extends: b\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "b.yaml"), []byte("extends: a\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+	if _, err := Load(filepath.Join(dir, "a.yaml")); err == nil {
+		t.Fatalf("Load() expected error for an extends cycle")
+	}
+	if _, err := Load(filepath.Join(dir, "missing-base.yaml")); err == nil {
+		t.Fatalf("Load() expected error for a missing file")
+	}
+}
+
+func TestResolveExtends(t *testing.T) {
+	path := filepath.Join("/cfg", "profiles", "office.yaml")
+	tests := map[string]string{
+		"home":               filepath.Join("/cfg", "profiles", "home.yaml"),
+		"../config.yaml":     filepath.Join("/cfg", "config.yaml"),
+		"/etc/wslbridge.yml": "/etc/wslbridge.yml",
+	}
+	for extends, want := range tests {
+		if got := ResolveExtends(path, extends); got != want {
+			t.Fatalf("ResolveExtends(%q) = %q, want %q", extends, got, want)
+		}
+	}
+}
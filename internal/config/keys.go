@@ -0,0 +1,262 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix prefixes the environment variable of every config key:
+// `db.local_port` is read from WSLBRIDGE_DB_LOCAL_PORT.
+const EnvPrefix = "WSLBRIDGE_"
+
+// Key is one config value addressed by its dotted YAML path, such as
+// `socks.port` or `db.prefer_role`. Lists and maps are single keys.
+type Key struct {
+	Path  string
+	index []int
+	typ   reflect.Type
+}
+
+// EnvVar returns the environment variable that overrides k.
+func (k Key) EnvVar() string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(k.Path, ".", "_"))
+}
+
+// Type returns a short description of the accepted value format.
+func (k Key) Type() string {
+	switch k.typ.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Int:
+		return "int"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Slice:
+		if k.typ.Elem().Kind() == reflect.String {
+			return "list (a,b)"
+		}
+	case reflect.Map:
+		if isScalarKind(k.typ.Elem().Kind()) {
+			return "map (k=v,k2=v2)"
+		}
+	}
+	return "yaml"
+}
+
+var configKeys = schemaKeys(reflect.TypeOf(configDisk{}), "", nil)
+
+// Keys returns every config key in file order.
+func Keys() []Key {
+	return append([]Key(nil), configKeys...)
+}
+
+// LookupKey returns the key with the given dotted path.
+func LookupKey(path string) (Key, bool) {
+	path = strings.ToLower(strings.TrimSpace(path))
+	for _, k := range configKeys {
+		if k.Path == path {
+			return k, true
+		}
+	}
+	return Key{}, false
+}
+
+// schemaKeys walks the YAML tags of t. Nested structs are sections, every
+// other field is a key.
+func schemaKeys(t reflect.Type, prefix string, index []int) []Key {
+	var out []Key
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" || (prefix == "" && name == versionKey) {
+			continue
+		}
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		idx := append(append([]int(nil), index...), i)
+		if f.Type.Kind() == reflect.Struct {
+			out = append(out, schemaKeys(f.Type, path, idx)...)
+			continue
+		}
+		out = append(out, Key{Path: path, index: idx, typ: f.Type})
+	}
+	return out
+}
+
+func (k Key) field(d *configDisk) reflect.Value {
+	return reflect.ValueOf(d).Elem().FieldByIndex(k.index)
+}
+
+// parse converts raw into a value of k's type. Lists are comma-separated,
+// string maps use `k=v` pairs and anything else is YAML (flow style works
+// on one line).
+func (k Key) parse(raw string) (reflect.Value, error) {
+	v := reflect.New(k.typ).Elem()
+	raw = strings.TrimSpace(raw)
+	switch {
+	case isScalarKind(k.typ.Kind()):
+		s, err := parseScalar(k.typ, raw)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%s: %w", k.Path, err)
+		}
+		return s, nil
+	case k.typ.Kind() == reflect.Slice && k.typ.Elem().Kind() == reflect.String:
+		for _, part := range strings.Split(raw, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				v = reflect.Append(v, reflect.ValueOf(part))
+			}
+		}
+		return v, nil
+	case k.typ.Kind() == reflect.Map && k.typ.Key().Kind() == reflect.String && isScalarKind(k.typ.Elem().Kind()):
+		v = reflect.MakeMap(k.typ)
+		for _, part := range strings.Split(raw, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			name, val, ok := strings.Cut(part, "=")
+			if !ok || strings.TrimSpace(name) == "" {
+				return reflect.Value{}, fmt.Errorf("%s: expected k=v pairs, got %q", k.Path, part)
+			}
+			elem, err := parseScalar(k.typ.Elem(), strings.TrimSpace(val))
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("%s[%s]: %w", k.Path, name, err)
+			}
+			v.SetMapIndex(reflect.ValueOf(strings.TrimSpace(name)), elem)
+		}
+		return v, nil
+	default:
+		ptr := reflect.New(k.typ)
+		if err := yaml.Unmarshal([]byte(raw), ptr.Interface()); err != nil {
+			return reflect.Value{}, fmt.Errorf("%s: %w", k.Path, err)
+		}
+		return ptr.Elem(), nil
+	}
+}
+
+func isScalarKind(kind reflect.Kind) bool {
+	return kind == reflect.String || kind == reflect.Int || kind == reflect.Bool
+}
+
+func parseScalar(t reflect.Type, raw string) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid boolean %q", raw)
+		}
+		v.SetBool(b)
+	default:
+		v.SetString(raw)
+	}
+	return v, nil
+}
+
+// formatValue renders v in the format parse accepts.
+func formatValue(v reflect.Value) string {
+	switch {
+	case isScalarKind(v.Kind()):
+		return fmt.Sprint(v.Interface())
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		parts := make([]string, v.Len())
+		for i := range parts {
+			parts[i] = v.Index(i).String()
+		}
+		return strings.Join(parts, ",")
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String && isScalarKind(v.Type().Elem().Kind()):
+		parts := make([]string, 0, v.Len())
+		for _, name := range v.MapKeys() {
+			parts = append(parts, fmt.Sprintf("%s=%v", name.String(), v.MapIndex(name).Interface()))
+		}
+		sort.Strings(parts)
+		return strings.Join(parts, ",")
+	default:
+		var n yaml.Node
+		if err := n.Encode(v.Interface()); err != nil {
+			return fmt.Sprint(v.Interface())
+		}
+		setFlowStyle(&n)
+		b, err := yaml.Marshal(&n)
+		if err != nil {
+			return fmt.Sprint(v.Interface())
+		}
+		return strings.TrimSpace(string(b))
+	}
+}
+
+func setFlowStyle(n *yaml.Node) {
+	if n.Kind == yaml.MappingNode || n.Kind == yaml.SequenceNode {
+		n.Style |= yaml.FlowStyle
+	}
+	for _, c := range n.Content {
+		setFlowStyle(c)
+	}
+}
+
+// isEmptyValue reports whether v counts as unset: the zero value or an empty
+// list or map.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	default:
+		return v.IsZero()
+	}
+}
+
+// Get returns the value of key in c in the format Set accepts; ok is false
+// when the key is unset. Resolved secrets are returned as their references.
+func Get(c Config, key string) (string, bool, error) {
+	k, ok := LookupKey(key)
+	if !ok {
+		return "", false, fmt.Errorf("unknown config key %q", key)
+	}
+	disk := writableDisk(c)
+	v := k.field(&disk)
+	if isEmptyValue(v) {
+		return "", false, nil
+	}
+	return formatValue(v), true, nil
+}
+
+// Set parses raw and stores it at key in c.
+func Set(c *Config, key, raw string) error {
+	k, ok := LookupKey(key)
+	if !ok {
+		return fmt.Errorf("unknown config key %q", key)
+	}
+	v, err := k.parse(raw)
+	if err != nil {
+		return err
+	}
+	disk := diskFromRuntime(*c)
+	k.field(&disk).Set(v)
+	*c = withSecrets(runtimeFromDisk(disk), c.secrets)
+	return nil
+}
+
+// Unset clears key in c so lower layers and defaults apply again.
+func Unset(c *Config, key string) error {
+	k, ok := LookupKey(key)
+	if !ok {
+		return fmt.Errorf("unknown config key %q", key)
+	}
+	disk := diskFromRuntime(*c)
+	k.field(&disk).SetZero()
+	*c = withSecrets(runtimeFromDisk(disk), c.secrets)
+	return nil
+}
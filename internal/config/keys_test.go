@@ -0,0 +1,76 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestKeyParseFormat(t *testing.T) {
+	for _, tt := range []struct{ key, raw string }{
+		{"socks.port", "1080"},
+		{"db.auto_resolve", "true"},
+		{"db.service_discovery_hosts", "a,b"},
+		{"db.service_ports", "a-db=15433,b-db=15434"},
+		{"db.discovery_auth.headers", "X-Token=abc"},
+		{"db.route_rules", "[{database: app, service: a-db}]"},
+	} {
+		k, ok := LookupKey(tt.key)
+		if !ok {
+			t.Fatalf("LookupKey(%q) not found", tt.key)
+		}
+		v, err := k.parse(tt.raw)
+		if err != nil {
+			t.Fatalf("parse(%q, %q) error: %v", tt.key, tt.raw, err)
+		}
+		if got := formatValue(v); got != tt.raw {
+			t.Fatalf("formatValue(parse(%q)) = %q", tt.raw, got)
+		}
+	}
+	if k, _ := LookupKey("db.local_port"); k.EnvVar() != "WSLBRIDGE_DB_LOCAL_PORT" {
+		t.Fatalf("EnvVar() = %q", k.EnvVar())
+	}
+}
+
+func TestGetSetUnset(t *testing.T) {
+	var c Config
+	if err := Set(&c, "db.service_ports", "a-db=15433"); err != nil {
+		t.Fatalf("Set error: %v", err)
+	}
+	if err := Set(&c, "Socks.Port", "1081"); err != nil {
+		t.Fatalf("Set error: %v", err)
+	}
+	if c.DB.ServicePorts["a-db"] != 15433 || c.Socks.Port != 1081 {
+		t.Fatalf("Set() = %+v", c)
+	}
+	if val, ok, err := Get(c, "socks.port"); err != nil || !ok || val != "1081" {
+		t.Fatalf("Get(socks.port) = %q, %v, %v", val, ok, err)
+	}
+	if err := Unset(&c, "socks.port"); err != nil {
+		t.Fatalf("Unset error: %v", err)
+	}
+	if _, ok, _ := Get(c, "socks.port"); ok {
+		t.Fatalf("Get(socks.port) after Unset expected unset")
+	}
+	if err := Set(&c, "db.nope", "1"); err == nil {
+		t.Fatalf("Set() expected error for an unknown key")
+	}
+	if err := Set(&c, "db.local_port", "abc"); err == nil {
+		t.Fatalf("Set() expected error for an invalid integer")
+	}
+}
+
+func TestLoadStrict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("db:\n  local_port: 6432\n  lokal_host: x\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+	if _, err := Load(path); err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	_, err := LoadStrict(path)
+	if err == nil || !strings.Contains(err.Error(), "unknown keys: db.lokal_host") {
+		t.Fatalf("LoadStrict() error = %v", err)
+	}
+}
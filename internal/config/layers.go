@@ -0,0 +1,218 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// Origins of effective config values, lowest precedence first.
+const (
+	OriginDefault = "default"
+	OriginUser    = "user config"
+	OriginProject = "project config"
+	OriginEnv     = "env"
+	OriginFlag    = "flag"
+)
+
+// Sources lists the config layers in precedence order: defaults < user
+// config < project config < environment < command-line flags. A layer sets
+// a key only when the value is not empty, and lists and maps are replaced
+// as a whole.
+type Sources struct {
+	Defaults    Config
+	UserPath    string
+	ProjectPath string
+	// Env holds KEY=value pairs (os.Environ()); only WSLBRIDGE_* config
+	// keys are read.
+	Env []string
+	// Flags maps dotted keys to raw values, e.g. from `--set db.local_port=6432`.
+	Flags map[string]string
+	// SecretStores are the store directories secret:// references resolve
+	// from, searched in order.
+	SecretStores []string
+}
+
+// TargetPath returns the file that saves write to: the project config when
+// there is one, the user config otherwise.
+func (s Sources) TargetPath() string {
+	if s.ProjectPath != "" {
+		return s.ProjectPath
+	}
+	return s.UserPath
+}
+
+// Resolved is the effective config together with where each value came from.
+type Resolved struct {
+	Config Config
+	// Exists reports whether a user or project config file was found.
+	Exists bool
+
+	path    string
+	layers  []configLayer
+	origins map[string]int
+}
+
+type configLayer struct {
+	origin string
+	detail string
+	disk   configDisk
+	target bool
+}
+
+// Resolve loads every layer of src and merges them.
+func Resolve(src Sources) (Resolved, error) {
+	res := Resolved{path: src.TargetPath(), origins: map[string]int{}}
+	var refs map[string]secretRef
+	res.layers = append(res.layers, configLayer{origin: OriginDefault, disk: diskFromRuntime(src.Defaults)})
+
+	for _, f := range []struct {
+		origin string
+		path   string
+	}{
+		{OriginUser, src.UserPath},
+		{OriginProject, src.ProjectPath},
+	} {
+		if f.path == "" {
+			continue
+		}
+		c, err := Load(f.path, src.SecretStores...)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return Resolved{}, err
+		}
+		res.Exists = true
+		res.layers = append(res.layers, configLayer{origin: f.origin, detail: f.path, disk: diskFromRuntime(c), target: f.path == res.path})
+		refs = mergeSecrets(refs, c.secrets)
+	}
+
+	envLayer, err := envConfigLayer(src.Env)
+	if err != nil {
+		return Resolved{}, err
+	}
+	flagLayer, err := flagConfigLayer(src.Flags)
+	if err != nil {
+		return Resolved{}, err
+	}
+	refs = mergeSecrets(refs, resolveSecrets(&envLayer.disk, src.SecretStores))
+	refs = mergeSecrets(refs, resolveSecrets(&flagLayer.disk, src.SecretStores))
+	res.layers = append(res.layers, envLayer, flagLayer)
+
+	var disk configDisk
+	for _, k := range configKeys {
+		for i := len(res.layers) - 1; i >= 0; i-- {
+			v := k.field(&res.layers[i].disk)
+			if isEmptyValue(v) {
+				continue
+			}
+			k.field(&disk).Set(v)
+			res.origins[k.Path] = i
+			break
+		}
+	}
+	res.Config = withSecrets(runtimeFromDisk(disk), refs)
+	return res, nil
+}
+
+func envConfigLayer(env []string) (configLayer, error) {
+	vars := make(map[string]string, len(env))
+	for _, kv := range env {
+		if name, val, ok := strings.Cut(kv, "="); ok && strings.HasPrefix(name, EnvPrefix) {
+			vars[name] = val
+		}
+	}
+	layer := configLayer{origin: OriginEnv}
+	for _, k := range configKeys {
+		raw := strings.TrimSpace(vars[k.EnvVar()])
+		if raw == "" {
+			continue
+		}
+		v, err := k.parse(raw)
+		if err != nil {
+			return configLayer{}, fmt.Errorf("%s: %w", k.EnvVar(), err)
+		}
+		k.field(&layer.disk).Set(v)
+	}
+	return layer, nil
+}
+
+func flagConfigLayer(flags map[string]string) (configLayer, error) {
+	layer := configLayer{origin: OriginFlag}
+	for path, raw := range flags {
+		k, ok := LookupKey(path)
+		if !ok {
+			return configLayer{}, fmt.Errorf("unknown config key %q", path)
+		}
+		v, err := k.parse(raw)
+		if err != nil {
+			return configLayer{}, err
+		}
+		k.field(&layer.disk).Set(v)
+	}
+	return layer, nil
+}
+
+// Path returns the file Save writes to.
+func (r Resolved) Path() string { return r.path }
+
+// Origin returns where the effective value of key came from, e.g.
+// `env WSLBRIDGE_DB_LOCAL_PORT` or `user config /home/u/.config/...`, and
+// false when no layer sets it.
+func (r Resolved) Origin(key string) (string, bool) {
+	i, ok := r.origins[key]
+	if !ok {
+		return "", false
+	}
+	layer := r.layers[i]
+	switch layer.origin {
+	case OriginEnv:
+		k, _ := LookupKey(key)
+		return OriginEnv + " " + k.EnvVar(), true
+	case OriginFlag:
+		return OriginFlag + " --set " + key, true
+	case OriginUser, OriginProject:
+		return layer.origin + " " + layer.detail, true
+	default:
+		return layer.origin, true
+	}
+}
+
+// Value returns the effective value of key in the format accepted by env
+// vars and --set.
+func (r Resolved) Value(key string) (string, bool) {
+	val, ok, err := Get(r.Config, key)
+	return val, ok && err == nil
+}
+
+// Save writes c to the target file. Values that are unchanged from what the
+// environment, flags, defaults or the user config provided are not copied
+// into the target; the target keeps its own value for those keys instead.
+func (r Resolved) Save(c Config) error {
+	if r.path == "" {
+		return fmt.Errorf("no config path to save to")
+	}
+	disk := diskFromRuntime(c)
+	effective := diskFromRuntime(r.Config)
+	var target configDisk
+	for _, layer := range r.layers {
+		if layer.target {
+			target = layer.disk
+		}
+	}
+	for _, k := range configKeys {
+		i, ok := r.origins[k.Path]
+		if !ok || r.layers[i].target {
+			continue
+		}
+		if !reflect.DeepEqual(k.field(&disk).Interface(), k.field(&effective).Interface()) {
+			continue
+		}
+		k.field(&disk).Set(k.field(&target))
+	}
+	refs := mergeSecrets(mergeSecrets(nil, r.Config.secrets), c.secrets)
+	return Save(r.path, withSecrets(runtimeFromDisk(disk), refs))
+}
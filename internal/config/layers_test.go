@@ -0,0 +1,90 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestResolve validates layer precedence, origins and that Save does not
+// persist overrides.
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	userPath := filepath.Join(dir, "user.yaml")
+	projectPath := filepath.Join(dir, "project.yaml")
+
+	var user Config
+	user.Socks.Host = "10.0.0.1"
+	user.Socks.Port = 1080
+	user.DB.LocalPort = 5432
+	if err := Save(userPath, user); err != nil {
+		t.Fatalf("Save(user) error: %v", err)
+	}
+	var project Config
+	project.Socks.Port = 1081
+	project.DB.PreferRole = "sync"
+	if err := Save(projectPath, project); err != nil {
+		t.Fatalf("Save(project) error: %v", err)
+	}
+
+	var defaults Config
+	defaults.DB.LocalHost = "127.0.0.1"
+	defaults.DB.LocalPort = 15432
+	src := Sources{
+		Defaults:    defaults,
+		UserPath:    userPath,
+		ProjectPath: projectPath,
+		Env:         []string{"WSLBRIDGE_DB_LOCAL_PORT=6432", "WSLBRIDGE_DB_SERVICE_NAMES=a-db, b-db", "WSLBRIDGE_PROFILE=x", "HOME=/root"},
+		Flags:       map[string]string{"db.prefer_role": "async"},
+	}
+	res, err := Resolve(src)
+	if err != nil {
+		t.Fatalf("Resolve error: %v", err)
+	}
+	if !res.Exists || res.Path() != projectPath {
+		t.Fatalf("Resolve() Exists=%v Path=%q", res.Exists, res.Path())
+	}
+
+	tests := []struct {
+		key, value, origin string
+	}{
+		{"socks.host", "10.0.0.1", OriginUser + " " + userPath},
+		{"socks.port", "1081", OriginProject + " " + projectPath},
+		{"db.local_host", "127.0.0.1", OriginDefault},
+		{"db.local_port", "6432", "env WSLBRIDGE_DB_LOCAL_PORT"},
+		{"db.service_names", "a-db,b-db", "env WSLBRIDGE_DB_SERVICE_NAMES"},
+		{"db.prefer_role", "async", "flag --set db.prefer_role"},
+	}
+	for _, tt := range tests {
+		val, _ := res.Value(tt.key)
+		origin, _ := res.Origin(tt.key)
+		if val != tt.value || origin != tt.origin {
+			t.Fatalf("%s = %q from %q, want %q from %q", tt.key, val, origin, tt.value, tt.origin)
+		}
+	}
+	if _, ok := res.Origin("db.auth_query"); ok {
+		t.Fatalf("Origin(db.auth_query) expected unset")
+	}
+
+	cfg := res.Config
+	cfg.DB.AuthQuery = "select 1"
+	if err := res.Save(cfg); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+	got, err := Load(projectPath)
+	if err != nil {
+		t.Fatalf("Load(project) error: %v", err)
+	}
+	if got.DB.AuthQuery != "select 1" || got.DB.PreferRole != "sync" || got.DB.LocalPort != 0 || got.Socks.Host != "" || got.DB.LocalHost != "" || got.Socks.Port != 1081 {
+		t.Fatalf("saved project config = %+v", got)
+	}
+
+	src.Env = []string{"WSLBRIDGE_SOCKS_PORT=abc"}
+	if _, err := Resolve(src); err == nil {
+		t.Fatalf("Resolve() expected error for an invalid env value")
+	}
+	src.Env = nil
+	src.Flags = map[string]string{"db.missing": "1"}
+	if _, err := Resolve(src); err == nil {
+		t.Fatalf("Resolve() expected error for an unknown flag key")
+	}
+}
@@ -0,0 +1,245 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the config schema version written by Save. Files
+// without a `version` key are version 1.
+const CurrentVersion = 2
+
+const versionKey = "version"
+
+// migration upgrades a config tree from version from to from+1.
+type migration struct {
+	from  int
+	about string
+	apply func(tree map[string]any)
+}
+
+var migrations = []migration{
+	{from: 1, about: "single-service db fields moved to per-service maps", apply: migrateSingleService},
+}
+
+// migrateTree upgrades tree to CurrentVersion step by step and reports the
+// version it started from.
+func migrateTree(path string, tree map[string]any) (int, error) {
+	version, err := treeVersion(tree)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	if version > CurrentVersion {
+		return 0, fmt.Errorf("%s: config version %d is newer than supported version %d (upgrade wslbridge)", path, version, CurrentVersion)
+	}
+	for _, m := range migrations {
+		if m.from >= version {
+			m.apply(tree)
+		}
+	}
+	tree[versionKey] = CurrentVersion
+	return version, nil
+}
+
+func treeVersion(tree map[string]any) (int, error) {
+	raw, ok := tree[versionKey]
+	if !ok || raw == nil {
+		return 1, nil
+	}
+	v, ok := raw.(int)
+	if !ok || v < 1 {
+		return 0, fmt.Errorf("invalid config version %v", raw)
+	}
+	return v, nil
+}
+
+// fileVersion returns the schema version of the config file at path and
+// its contents; ok is false when there is no readable, versioned YAML.
+func fileVersion(path string) (version int, original []byte, ok bool) {
+	b, err := os.ReadFile(path)
+	if err != nil || len(b) == 0 {
+		return 0, nil, false
+	}
+	tree := map[string]any{}
+	if err := yaml.Unmarshal(b, &tree); err != nil {
+		return 0, nil, false
+	}
+	if version, err = treeVersion(tree); err != nil {
+		return 0, nil, false
+	}
+	return version, b, true
+}
+
+// backupBeforeUpgrade copies a config file of an older version to
+// <path>.v<version>.bak before it is replaced and returns the backup path,
+// or "" for current files. An existing backup is kept, so it always holds
+// the original file.
+func backupBeforeUpgrade(path string) (string, error) {
+	from, original, ok := fileVersion(path)
+	if !ok || from >= CurrentVersion {
+		return "", nil
+	}
+	backup := fmt.Sprintf("%s.v%d.bak", path, from)
+	if _, err := os.Stat(backup); err == nil {
+		return backup, nil
+	}
+	if err := os.WriteFile(backup, original, 0o600); err != nil {
+		return "", fmt.Errorf("%s: back up before upgrading to version %d: %w", path, CurrentVersion, err)
+	}
+	return backup, nil
+}
+
+// Migrate upgrades the config file at path to CurrentVersion and returns
+// the version it had and the backup of the original. Current files are
+// left alone and have no backup.
+func Migrate(path string) (int, string, error) {
+	from, _, ok := fileVersion(path)
+	if !ok {
+		if _, err := os.Stat(path); err != nil {
+			return 0, "", err
+		}
+		return CurrentVersion, "", nil
+	}
+	if from >= CurrentVersion {
+		return from, "", nil
+	}
+	// References are kept unresolved: they are written back as they are.
+	disk, err := loadDisk(path)
+	if err != nil {
+		return 0, "", err
+	}
+	backup, err := save(path, runtimeFromDisk(disk))
+	return from, backup, err
+}
+
+// migrateSingleService folds the fields of the single-service era into the
+// per-service layout: service_discovery_url becomes scheme and host, and
+// service_name with target_address/target_instance fill service_names,
+// service_targets and service_instances. Named db environments are folded
+// the same way.
+func migrateSingleService(tree map[string]any) {
+	db, ok := tree["db"].(map[string]any)
+	if !ok {
+		return
+	}
+	foldSingleService(db)
+	envs, _ := db["environments"].(map[string]any)
+	for _, env := range envs {
+		if env, ok := env.(map[string]any); ok {
+			foldSingleService(env)
+		}
+	}
+}
+
+func foldSingleService(db map[string]any) {
+	if legacy, _ := db["service_discovery_url"].(string); strings.TrimSpace(legacy) != "" {
+		if scheme, host, ok := splitDiscoveryURL(legacy); ok {
+			if s, _ := db["service_discovery_scheme"].(string); strings.TrimSpace(s) == "" {
+				db["service_discovery_scheme"] = scheme
+			}
+			if h, _ := db["service_discovery_host"].(string); strings.TrimSpace(h) == "" {
+				db["service_discovery_host"] = host
+			}
+			delete(db, "service_discovery_url")
+		}
+	}
+
+	service, _ := db["service_name"].(string)
+	service = strings.TrimSpace(service)
+	if service == "" {
+		return
+	}
+	names, _ := db["service_names"].([]any)
+	found := false
+	for _, n := range names {
+		if s, _ := n.(string); strings.EqualFold(strings.TrimSpace(s), service) {
+			found = true
+		}
+	}
+	if !found {
+		db["service_names"] = append(names, service)
+	}
+	for field, mapKey := range map[string]string{"target_address": "service_targets", "target_instance": "service_instances"} {
+		val, _ := db[field].(string)
+		if strings.TrimSpace(val) == "" {
+			continue
+		}
+		values, _ := db[mapKey].(map[string]any)
+		if values == nil {
+			values = map[string]any{}
+		}
+		key := strings.ToLower(service)
+		if cur, _ := values[key].(string); strings.TrimSpace(cur) == "" {
+			values[key] = val
+		}
+		db[mapKey] = values
+	}
+}
+
+func splitDiscoveryURL(raw string) (string, string, bool) {
+	val := strings.TrimSpace(raw)
+	if !strings.Contains(val, "://") {
+		val = "http://" + val
+	}
+	u, err := url.Parse(val)
+	if err != nil || u.Host == "" {
+		return "", "", false
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != "http" && scheme != "https" {
+		return "", "", false
+	}
+	return scheme, strings.ToLower(u.Host), true
+}
+
+// unknownKeys returns the dotted paths in tree that t has no field for.
+// Values of list and map keys are not inspected.
+func unknownKeys(tree map[string]any, t reflect.Type, prefix string) []string {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		fields[name] = t.Field(i).Type
+	}
+	var out []string
+	for key, val := range tree {
+		if prefix == "" && (key == extendsKey || key == versionKey) {
+			continue
+		}
+		ft, ok := fields[key]
+		if !ok {
+			out = append(out, prefix+key)
+			continue
+		}
+		if sub, isMap := val.(map[string]any); isMap && ft.Kind() == reflect.Struct {
+			out = append(out, unknownKeys(sub, ft, prefix+key+".")...)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+var (
+	warnOutput io.Writer = os.Stderr
+	warnedMu   sync.Mutex
+	warned     = map[string]bool{}
+)
+
+// warnf prints a warning once per process.
+func warnf(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	warnedMu.Lock()
+	defer warnedMu.Unlock()
+	if warned[msg] {
+		return
+	}
+	warned[msg] = true
+	fmt.Fprintln(warnOutput, "warning:", msg)
+}
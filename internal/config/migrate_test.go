@@ -0,0 +1,125 @@
+package config
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	warnOutput = io.Discard
+	os.Exit(m.Run())
+}
+
+// TestLoad_MigratesSingleServiceConfig verifies that unversioned configs are
+// upgraded in memory on load and on disk, with a backup of the original,
+// only by Migrate.
+func TestLoad_MigratesSingleServiceConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	original := []byte(`# team defaults
+db:
+  service_discovery_url: https://sd.example.internal:8443/endpoints
+  service_name: Example-DB
+  target_address: 10.0.0.1:6432
+  target_instance: db-a
+  local_port: 15432
+`)
+	if err := os.WriteFile(path, original, 0o600); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+
+	for _, load := range []func(string, ...string) (Config, error){Load, LoadStrict} {
+		got, err := load(path)
+		if err != nil {
+			t.Fatalf("Load error: %v", err)
+		}
+		db := got.DB
+		if db.ServiceDiscoveryScheme != "https" || db.ServiceDiscoveryHost != "sd.example.internal:8443" {
+			t.Fatalf("discovery = %q %q", db.ServiceDiscoveryScheme, db.ServiceDiscoveryHost)
+		}
+		if !reflect.DeepEqual(db.ServiceNames, []string{"Example-DB"}) ||
+			db.ServiceTargets["example-db"] != "10.0.0.1:6432" ||
+			db.ServiceInstances["example-db"] != "db-a" {
+			t.Fatalf("services = %v %v %v", db.ServiceNames, db.ServiceTargets, db.ServiceInstances)
+		}
+	}
+	if b, err := os.ReadFile(path); err != nil || !bytes.Equal(b, original) {
+		t.Fatalf("Load rewrote the file: %q, %v", b, err)
+	}
+	if _, err := os.Stat(path + ".v1.bak"); !os.IsNotExist(err) {
+		t.Fatalf("Load wrote a backup: %v", err)
+	}
+
+	from, backupPath, err := Migrate(path)
+	if err != nil || from != 1 || backupPath != path+".v1.bak" {
+		t.Fatalf("Migrate() = %d, %q, %v", from, backupPath, err)
+	}
+	backup, err := os.ReadFile(backupPath)
+	if err != nil || !bytes.Equal(backup, original) {
+		t.Fatalf("backup = %q, %v", backup, err)
+	}
+	upgraded, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile error: %v", err)
+	}
+	if !strings.Contains(string(upgraded), "version: 2") || strings.Contains(string(upgraded), "service_discovery_url") {
+		t.Fatalf("upgraded file:\n%s", upgraded)
+	}
+	if from, _, err := Migrate(path); err != nil || from != CurrentVersion {
+		t.Fatalf("Migrate() of a current file = %d, %v", from, err)
+	}
+
+	if err := os.WriteFile(path, []byte("version: 99\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Fatalf("Load() expected error for a newer config version")
+	}
+}
+
+// TestLoad_MigratesEnvironmentServiceName verifies that the single-service
+// fold also applies to named db environments.
+func TestLoad_MigratesEnvironmentServiceName(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := []byte(`db:
+  environments:
+    stage:
+      service_name: Stage-DB
+      service_names: [other-db]
+`)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+	got, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if names := got.DB.Environments["stage"].ServiceNames; !reflect.DeepEqual(names, []string{"other-db", "Stage-DB"}) {
+		t.Fatalf("stage service_names = %v", names)
+	}
+}
+
+func TestLoad_WarnsAboutUnknownKeys(t *testing.T) {
+	var out bytes.Buffer
+	warnOutput = &out
+	defer func() { warnOutput = io.Discard }()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	body := "version: 2\nsocks:\n  hots: 10.0.0.1\ndb:\n  local_port: 6432\n  service_ports:\n    any-name: 1\nextra: true\n"
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+	if _, err := Load(path); err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if !strings.Contains(out.String(), "unknown keys extra, socks.hots are ignored") {
+		t.Fatalf("warning = %q", out.String())
+	}
+	if _, err := os.Stat(path + ".v1.bak"); !os.IsNotExist(err) {
+		t.Fatalf("current-version config was backed up: %v", err)
+	}
+}
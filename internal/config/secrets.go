@@ -0,0 +1,157 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"wslbridge/internal/secrets"
+)
+
+// secretFields are the paths whose values may be secret references, with
+// list indexes and map keys written as [*]. References anywhere else are
+// kept as plain strings: config files can sit in a shared checkout, and a
+// cmd:// value in an ordinary key must not run on load.
+var secretFields = map[string]bool{
+	"db.auth_lookup_password":                      true,
+	"db.discovery_auth.token":                      true,
+	"db.discovery_auth.password":                   true,
+	"db.discovery_auth.headers[*]":                 true,
+	"db.environments[*].discovery_auth.token":      true,
+	"db.environments[*].discovery_auth.password":   true,
+	"db.environments[*].discovery_auth.headers[*]": true,
+}
+
+var pathKeyRE = regexp.MustCompile(`\[[^\]]*\]`)
+
+// isSecretField reports whether the value at path (as walkStrings names it)
+// may be a secret reference.
+func isSecretField(path string) bool {
+	return secretFields[pathKeyRE.ReplaceAllString(path, "[*]")]
+}
+
+// secretRef is a secret reference and the value it resolved to.
+type secretRef struct {
+	ref string
+	val string
+}
+
+// resolveSecrets replaces the secret references in the secret fields of d
+// (see secrets.Resolver) with the secrets they point to and returns the
+// references by field path, so writes can put them back. secret://
+// references are looked up in stores in order. A reference that cannot be
+// resolved, or that is not in a secret field, is kept as it is with a
+// warning.
+func resolveSecrets(d *configDisk, stores []string) map[string]secretRef {
+	var refs map[string]secretRef
+	resolver := secrets.Resolver{Dirs: stores}
+	walkStrings(reflect.ValueOf(d).Elem(), "", func(path, s string) string {
+		if !secrets.IsRef(s) {
+			return s
+		}
+		if !isSecretField(path) {
+			warnf("%s: secret references are only resolved in passwords, tokens and discovery headers; keeping %s as is", path, s)
+			return s
+		}
+		val, err := resolver.Resolve(s)
+		if err != nil {
+			warnf("cannot resolve %s: %v", s, err)
+			return s
+		}
+		if val == "" {
+			warnf("%s resolves to an empty value", s)
+			return s
+		}
+		if refs == nil {
+			refs = map[string]secretRef{}
+		}
+		refs[path] = secretRef{ref: s, val: val}
+		return val
+	})
+	return refs
+}
+
+// hideSecrets puts the references back into the fields they were resolved
+// from, as long as those fields still hold the resolved value.
+func hideSecrets(d *configDisk, refs map[string]secretRef) {
+	if len(refs) == 0 {
+		return
+	}
+	walkStrings(reflect.ValueOf(d).Elem(), "", func(path, s string) string {
+		if r, ok := refs[path]; ok && r.val == s {
+			return r.ref
+		}
+		return s
+	})
+}
+
+func withSecrets(c Config, refs map[string]secretRef) Config {
+	c.secrets = refs
+	return c
+}
+
+func mergeSecrets(into map[string]secretRef, from map[string]secretRef) map[string]secretRef {
+	if len(from) == 0 {
+		return into
+	}
+	if into == nil {
+		into = map[string]secretRef{}
+	}
+	for path, r := range from {
+		into[path] = r
+	}
+	return into
+}
+
+// walkStrings replaces every string reachable from v with fn(path, s), where
+// path is the dotted YAML path of the string below path, with list indexes
+// and map keys in brackets (`db.discovery_auth.headers[Authorization]`).
+// Slices and maps are copied before they change, so values shared with the
+// caller's config are left alone.
+func walkStrings(v reflect.Value, path string, fn func(path, s string) string) {
+	switch v.Kind() {
+	case reflect.String:
+		if s := fn(path, v.String()); s != v.String() {
+			v.SetString(s)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+			if name == "" {
+				name = strings.ToLower(f.Name)
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			walkStrings(v.Field(i), name, fn)
+		}
+	case reflect.Slice:
+		if v.IsNil() {
+			return
+		}
+		cp := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(cp, v)
+		for i := 0; i < cp.Len(); i++ {
+			walkStrings(cp.Index(i), fmt.Sprintf("%s[%d]", path, i), fn)
+		}
+		v.Set(cp)
+	case reflect.Map:
+		if v.IsNil() {
+			return
+		}
+		cp := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(iter.Value())
+			walkStrings(elem, fmt.Sprintf("%s[%v]", path, iter.Key().Interface()), fn)
+			cp.SetMapIndex(iter.Key(), elem)
+		}
+		v.Set(cp)
+	}
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"wslbridge/internal/secrets"
+)
+
+// TestLoad_ResolvesSecretRefs verifies that references are resolved on load
+// and written back as references, never as the resolved values.
+func TestLoad_ResolvesSecretRefs(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := filepath.Join(home, "secret-store")
+	if err := secrets.Open(dir).Set("lookup-pass", "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("WSLBRIDGE_TEST_TOKEN", "Bearer t0ken")
+
+	path := filepath.Join(home, "config.yaml")
+	body := "version: 2\n" +
+		"db:\n" +
+		"  auth_lookup_user: pgbouncer\n" +
+		"  auth_lookup_password: secret://lookup-pass\n" +
+		"  discovery_auth:\n" +
+		"    headers:\n" +
+		"      Authorization: env://WSLBRIDGE_TEST_TOKEN\n" +
+		"    token: secret://missing\n" +
+		"  auth_query: cmd://touch " + filepath.Join(home, "ran") + "\n"
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path, dir)
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if cfg.DB.AuthLookupPass != "hunter2" {
+		t.Fatalf("auth_lookup_password = %q", cfg.DB.AuthLookupPass)
+	}
+	if got := cfg.DB.DiscoveryAuth.Headers["Authorization"]; got != "Bearer t0ken" {
+		t.Fatalf("Authorization header = %q", got)
+	}
+	if cfg.DB.DiscoveryAuth.Token != "secret://missing" {
+		t.Fatalf("unresolvable ref must be kept, got %q", cfg.DB.DiscoveryAuth.Token)
+	}
+	if _, err := os.Stat(filepath.Join(home, "ran")); !os.IsNotExist(err) || !strings.HasPrefix(cfg.DB.AuthQuery, "cmd://") {
+		t.Fatalf("reference outside a secret field was resolved: %q, %v", cfg.DB.AuthQuery, err)
+	}
+	if val, _, _ := Get(cfg, "db.auth_lookup_password"); val != "secret://lookup-pass" {
+		t.Fatalf("Get = %q, want the reference", val)
+	}
+
+	cfg.DB.LocalPort = 6432
+	if err := Save(path, cfg); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+	if cfg.DB.AuthLookupPass != "hunter2" {
+		t.Fatalf("Save changed the in-memory config")
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, leak := range []string{"hunter2", "t0ken"} {
+		if strings.Contains(string(b), leak) {
+			t.Fatalf("saved config leaks %q:\n%s", leak, b)
+		}
+	}
+	for _, ref := range []string{"secret://lookup-pass", "env://WSLBRIDGE_TEST_TOKEN", "secret://missing"} {
+		if !strings.Contains(string(b), ref) {
+			t.Fatalf("saved config lost %q:\n%s", ref, b)
+		}
+	}
+}
+
+// TestSave_RestoresRefsByField verifies that a reference is written back
+// only into the field it was loaded from, not into other fields that happen
+// to hold the same value, and not once the field has changed.
+func TestSave_RestoresRefsByField(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("WSLBRIDGE_TEST_DISCOVERY_PASS", "pgbouncer")
+	t.Setenv("WSLBRIDGE_TEST_PASS", "hunter2")
+
+	path := filepath.Join(home, "config.yaml")
+	body := "version: 2\n" +
+		"db:\n" +
+		"  auth_lookup_user: pgbouncer\n" +
+		"  auth_lookup_password: env://WSLBRIDGE_TEST_PASS\n" +
+		"  discovery_auth:\n" +
+		"    password: env://WSLBRIDGE_TEST_DISCOVERY_PASS\n"
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if cfg.DB.DiscoveryAuth.Password != "pgbouncer" || cfg.DB.AuthLookupUser != "pgbouncer" {
+		t.Fatalf("discovery password = %q, auth_lookup_user = %q", cfg.DB.DiscoveryAuth.Password, cfg.DB.AuthLookupUser)
+	}
+	cfg.DB.AuthLookupPass = "changed"
+	if err := Save(path, cfg); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+	saved, err := LoadStrict(path)
+	if err != nil {
+		t.Fatalf("LoadStrict error: %v", err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "password: env://WSLBRIDGE_TEST_DISCOVERY_PASS") ||
+		!strings.Contains(string(b), "auth_lookup_user: pgbouncer") ||
+		saved.DB.AuthLookupPass != "changed" {
+		t.Fatalf("saved config:\n%s", b)
+	}
+}
+
+// TestResolve_KeepsSecretRefs verifies that Resolved.Save keeps references
+// in the target file and writes no secret resolved from another layer.
+func TestResolve_KeepsSecretRefs(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("WSLBRIDGE_TEST_PASS", "from-env")
+	userPath := filepath.Join(home, "user.yaml")
+	if err := os.WriteFile(userPath, []byte("version: 2\ndb:\n  auth_lookup_password: env://WSLBRIDGE_TEST_PASS\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	projectPath := filepath.Join(home, "project.yaml")
+	if err := os.WriteFile(projectPath, []byte("version: 2\ndb:\n  discovery_auth:\n    token: env://WSLBRIDGE_TEST_PASS\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := Resolve(Sources{
+		UserPath:    userPath,
+		ProjectPath: projectPath,
+		Env:         []string{"WSLBRIDGE_DB_DISCOVERY_AUTH_PASSWORD=env://WSLBRIDGE_TEST_PASS"},
+	})
+	if err != nil {
+		t.Fatalf("Resolve error: %v", err)
+	}
+	auth := res.Config.DB.DiscoveryAuth
+	if res.Config.DB.AuthLookupPass != "from-env" || auth.Password != "from-env" || auth.Token != "from-env" {
+		t.Fatalf("unresolved config: %+v", res.Config.DB)
+	}
+	cfg := res.Config
+	cfg.DB.LocalPort = 6432
+	if err := res.Save(cfg); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+	b, err := os.ReadFile(projectPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "from-env") || strings.Contains(string(b), "password") ||
+		!strings.Contains(string(b), "token: env://WSLBRIDGE_TEST_PASS") {
+		t.Fatalf("project config:\n%s", b)
+	}
+}
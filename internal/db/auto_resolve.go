@@ -0,0 +1,393 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"wslbridge/internal/config"
+	"wslbridge/internal/secrets"
+)
+
+// autoResolveRetryInterval limits how often a failed name is looked up again.
+const autoResolveRetryInterval = 30 * time.Second
+
+// proxyDiscovery carries the service discovery settings the proxy daemon
+// needs to resolve databases that are not pinned to a fixed target.
+type proxyDiscovery struct {
+	Provider     string                    `json:"provider,omitempty"`
+	Scheme       string                    `json:"scheme"`
+	Host         string                    `json:"host"`
+	Hosts        []string                  `json:"hosts,omitempty"`
+	HostOrder    string                    `json:"host_order,omitempty"`
+	Attempts     int                       `json:"attempts,omitempty"`
+	Backoff      string                    `json:"backoff,omitempty"`
+	EndpointMask string                    `json:"endpoint_mask"`
+	Mapping      *config.DBEndpointMapping `json:"mapping,omitempty"`
+	Auth         *config.DBDiscoveryAuth   `json:"auth,omitempty"`
+	// SecretStores are where the daemon resolves the secret:// token and
+	// password of Auth from; the routes file keeps only the references.
+	SecretStores []string `json:"secret_stores,omitempty"`
+	ServiceMask  string   `json:"service_mask,omitempty"`
+	File         string   `json:"file,omitempty"`
+	PreferRole   string   `json:"prefer_role,omitempty"`
+}
+
+func (d proxyDiscovery) dbConfig() (config.DBConfig, error) {
+	cfg := config.DBConfig{
+		DiscoveryProvider:      d.Provider,
+		DiscoveryServiceMask:   d.ServiceMask,
+		DiscoveryFile:          d.File,
+		ServiceDiscoveryScheme: d.Scheme,
+		ServiceDiscoveryHost:   d.Host,
+		ServiceDiscoveryHosts:  d.Hosts,
+		DiscoveryHostOrder:     d.HostOrder,
+		DiscoveryAttempts:      d.Attempts,
+		DiscoveryBackoff:       d.Backoff,
+		EndpointMask:           d.EndpointMask,
+		PreferRole:             d.PreferRole,
+	}
+	if d.Mapping != nil {
+		cfg.EndpointMapping = *d.Mapping
+	}
+	if d.Auth != nil {
+		cfg.DiscoveryAuth = *d.Auth
+		resolver := secrets.Resolver{Dirs: d.SecretStores}
+		for _, val := range []*string{&cfg.DiscoveryAuth.Token, &cfg.DiscoveryAuth.Password} {
+			if !secrets.IsRef(*val) {
+				continue
+			}
+			resolved, err := resolver.Resolve(*val)
+			if err != nil {
+				return config.DBConfig{}, fmt.Errorf("service discovery auth: resolve %s: %w", *val, err)
+			}
+			*val = resolved
+		}
+	}
+	return cfg, nil
+}
+
+// proxyAutoResolve enables on-demand resolution for databases that match no
+// configured service or rule.
+type proxyAutoResolve struct {
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// nextAutoResolvePattern returns the pattern that `db auto on --pattern`
+// leaves in config: an empty pattern keeps cur, `*` resets it to match
+// every name.
+func nextAutoResolvePattern(cur, pattern string) string {
+	switch pattern = strings.TrimSpace(pattern); pattern {
+	case "":
+		return cur
+	case "*":
+		return ""
+	default:
+		return pattern
+	}
+}
+
+// proxyRouter selects upstream routes for the proxy daemon and resolves
+// databases on demand for pattern rules without a fixed service and in auto mode.
+type proxyRouter struct {
+	routesFile string
+
+	// mu guards live, failures and inflight only; lookups run without it
+	// so one slow database does not block the other client sessions.
+	mu       sync.Mutex
+	live     map[string]proxyRoute
+	failures map[string]time.Time
+	inflight map[string]*autoResolveCall
+
+	// persistMu serializes read-modify-write updates of routesFile.
+	persistMu sync.Mutex
+}
+
+// autoResolveCall is a lookup in progress; concurrent requests for the same
+// key wait for it instead of querying service discovery again.
+type autoResolveCall struct {
+	done  chan struct{}
+	route proxyRoute
+	err   error
+}
+
+func newProxyRouter(routesFile string) *proxyRouter {
+	return &proxyRouter{
+		routesFile: routesFile,
+		live:       make(map[string]proxyRoute),
+		failures:   make(map[string]time.Time),
+		inflight:   make(map[string]*autoResolveCall),
+	}
+}
+
+func (r *proxyRouter) route(req startupRequest) (proxyRoute, error) {
+	routes, err := loadProxyRoutes(r.routesFile)
+	if err != nil {
+		return proxyRoute{}, fmt.Errorf("wslbridge proxy routes are not available")
+	}
+
+	// Environment-prefixed names are served from static routes only.
+	if routes.environmentDatabase(req.Database) {
+		return findProxyRoute(routes, req.Database, req.User)
+	}
+
+	// Precedence: rules on user/application_name, exact services, rules on
+	// the database name only, then auto mode.
+	if rule, ok := matchProxyRouteRule(routes.Rules, req, true); ok {
+		return r.ruleRoute(routes, rule, req)
+	}
+	route, err := findProxyRoute(routes, req.Database, req.User)
+	if err == nil {
+		return route, nil
+	}
+	if rule, ok := matchProxyRouteRule(routes.Rules, req, false); ok {
+		return r.ruleRoute(routes, rule, req)
+	}
+	if routes.Auto != nil && matchAutoResolvePattern(routes.Auto.Pattern, req.Database) {
+		return r.autoResolve(routes.Discovery, req.Database, "", true)
+	}
+	return proxyRoute{}, err
+}
+
+func (r *proxyRouter) ruleRoute(routes proxyRoutesFile, rule proxyRouteRule, req startupRequest) (proxyRoute, error) {
+	if rule.Service != "" {
+		return rule.route(req.Database)
+	}
+	return r.autoResolve(routes.Discovery, req.Database, rule.Role, rule.persistable())
+}
+
+// autoResolve resolves database via service discovery, optionally with a role
+// override. Only persistable routes are written back to the routes file.
+func (r *proxyRouter) autoResolve(discovery *proxyDiscovery, database, role string, persist bool) (proxyRoute, error) {
+	if discovery == nil {
+		return proxyRoute{}, fmt.Errorf("database %q cannot be resolved: service discovery is not configured", database)
+	}
+	target := routeTarget{Service: database, Role: role}
+	key := target.key()
+	settings := *discovery
+	if role != "" {
+		settings.PreferRole = role
+	}
+
+	r.mu.Lock()
+	if route, ok := r.live[key]; ok {
+		r.mu.Unlock()
+		return route, nil
+	}
+	if failedAt, ok := r.failures[key]; ok && time.Since(failedAt) < autoResolveRetryInterval {
+		r.mu.Unlock()
+		return proxyRoute{}, fmt.Errorf("database %q could not be resolved via service discovery (retry later)", database)
+	}
+	if call, ok := r.inflight[key]; ok {
+		r.mu.Unlock()
+		<-call.done
+		return call.route, call.err
+	}
+	call := &autoResolveCall{done: make(chan struct{})}
+	r.inflight[key] = call
+	r.mu.Unlock()
+
+	call.route, call.err = r.resolve(settings, key, database, persist)
+
+	r.mu.Lock()
+	delete(r.inflight, key)
+	if call.err != nil {
+		r.failures[key] = time.Now()
+	} else {
+		delete(r.failures, key)
+		r.live[key] = call.route
+	}
+	r.mu.Unlock()
+	close(call.done)
+	return call.route, call.err
+}
+
+func (r *proxyRouter) resolve(settings proxyDiscovery, key, database string, persist bool) (proxyRoute, error) {
+	route, err := resolveAutoRoute(settings, database)
+	if err != nil {
+		fmt.Printf("auto-resolve %s failed: %v\n", database, err)
+		return proxyRoute{}, fmt.Errorf("database %q could not be resolved via service discovery: %v", database, err)
+	}
+	if persist {
+		r.persistMu.Lock()
+		err := persistAutoRoute(r.routesFile, key, route)
+		r.persistMu.Unlock()
+		if err != nil {
+			fmt.Printf("auto-resolve %s: persist route: %v\n", database, err)
+		}
+	}
+	fmt.Printf("auto-resolved %s -> %s\n", database, route.TargetAddr)
+	return route, nil
+}
+
+// evict forgets the auto-resolved routes to target, in memory and in the
+// routes file, so the next connection resolves the database again.
+func (r *proxyRouter) evict(target string) {
+	r.mu.Lock()
+	for key, route := range r.live {
+		if route.TargetAddr == target {
+			delete(r.live, key)
+		}
+	}
+	r.mu.Unlock()
+
+	r.persistMu.Lock()
+	defer r.persistMu.Unlock()
+	routes, err := loadProxyRoutes(r.routesFile)
+	if err != nil {
+		return
+	}
+	evicted := false
+	for key, route := range routes.Services {
+		if route.Auto && route.TargetAddr == target {
+			delete(routes.Services, key)
+			evicted = true
+		}
+	}
+	if !evicted {
+		return
+	}
+	if err := writeProxyRoutes(r.routesFile, routes); err != nil {
+		fmt.Printf("auto-resolve: evict %s: %v\n", target, err)
+		return
+	}
+	fmt.Printf("auto-resolve: evicted unreachable %s\n", target)
+}
+
+func resolveAutoRoute(discovery proxyDiscovery, database string) (proxyRoute, error) {
+	cfg, err := discovery.dbConfig()
+	if err != nil {
+		return proxyRoute{}, err
+	}
+	provider, err := NewDiscoveryProvider(cfg)
+	if err != nil {
+		return proxyRoute{}, err
+	}
+	_, endpoints, err := provider.Resolve(database)
+	if err != nil {
+		return proxyRoute{}, err
+	}
+	ep, err := ChooseEndpoint(endpoints, discovery.PreferRole)
+	if err != nil {
+		return proxyRoute{}, err
+	}
+	if err := CheckTCPConnectivity(ep.Address, defaultConnectivityTimeout); err != nil {
+		return proxyRoute{}, err
+	}
+	return proxyRoute{
+		Service:    database,
+		TargetAddr: ep.Address,
+		Instance:   ep.InstanceName,
+		Auto:       true,
+	}, nil
+}
+
+func persistAutoRoute(routesFile, key string, route proxyRoute) error {
+	routes, err := loadProxyRoutes(routesFile)
+	if err != nil {
+		return err
+	}
+	if _, ok := routes.Services[key]; ok {
+		return nil
+	}
+	if routes.Services == nil {
+		routes.Services = make(map[string]proxyRoute)
+	}
+	routes.Services[key] = route
+	return writeProxyRoutes(routesFile, routes)
+}
+
+func writeProxyRoutes(routesFile string, routes proxyRoutesFile) error {
+	b, err := json.MarshalIndent(routes, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal proxy routes: %w", err)
+	}
+	if err := writeFileAtomic(routesFile, b); err != nil {
+		return fmt.Errorf("write proxy routes: %w", err)
+	}
+	return nil
+}
+
+func proxyDiscoveryFromConfig(cfg config.Config, secretStores []string) *proxyDiscovery {
+	if ensureServiceDiscoveryConfigured(cfg) != nil {
+		return nil
+	}
+	discovery := &proxyDiscovery{
+		Provider:     cfg.DB.DiscoveryProvider,
+		ServiceMask:  cfg.DB.DiscoveryServiceMask,
+		File:         cfg.DB.DiscoveryFile,
+		Scheme:       cfg.DB.ServiceDiscoveryScheme,
+		Host:         cfg.DB.ServiceDiscoveryHost,
+		Hosts:        cfg.DB.ServiceDiscoveryHosts,
+		HostOrder:    cfg.DB.DiscoveryHostOrder,
+		Attempts:     cfg.DB.DiscoveryAttempts,
+		Backoff:      cfg.DB.DiscoveryBackoff,
+		EndpointMask: cfg.DB.EndpointMask,
+		PreferRole:   cfg.DB.PreferRole,
+	}
+	if cfg.DB.EndpointMapping != (config.DBEndpointMapping{}) {
+		mapping := cfg.DB.EndpointMapping
+		discovery.Mapping = &mapping
+	}
+	if !cfg.DB.DiscoveryAuth.IsZero() {
+		auth := cfg.DB.DiscoveryAuth
+		auth.Token = secretRef(cfg, "db.discovery_auth.token", auth.Token)
+		auth.Password = secretRef(cfg, "db.discovery_auth.password", auth.Password)
+		discovery.Auth = &auth
+		discovery.SecretStores = secretStores
+	}
+	return discovery
+}
+
+// secretRef returns the reference the value of key was loaded from, or val
+// when it was set inline.
+func secretRef(cfg config.Config, key, val string) string {
+	if ref, ok, err := config.Get(cfg, key); err == nil && ok && secrets.IsRef(ref) {
+		return ref
+	}
+	return val
+}
+
+func proxyAutoResolveFromConfig(cfg config.Config) *proxyAutoResolve {
+	if !cfg.DB.AutoResolve {
+		return nil
+	}
+	return &proxyAutoResolve{Pattern: cfg.DB.AutoResolvePattern}
+}
+
+// allowsDynamicRoute reports whether the daemon may resolve database on demand,
+// either through a pattern rule without a fixed service or through auto mode.
+func (routes proxyRoutesFile) allowsDynamicRoute(database string) bool {
+	if rule, ok := matchProxyRouteRule(routes.Rules, startupRequest{Database: database}, false); ok {
+		return rule.Service == "" && rule.persistable()
+	}
+	return routes.Auto != nil && matchAutoResolvePattern(routes.Auto.Pattern, database)
+}
+
+// matchAutoResolvePattern reports whether database is allowed for on-demand
+// resolution. The pattern is a comma-separated list of globs; empty allows all.
+func matchAutoResolvePattern(pattern, database string) bool {
+	if strings.TrimSpace(pattern) == "" {
+		return true
+	}
+	for _, p := range strings.Split(pattern, ",") {
+		if matchRoutePattern(p, database) {
+			return true
+		}
+	}
+	return false
+}
+
+func validateAutoResolvePattern(pattern string) error {
+	for _, p := range strings.Split(pattern, ",") {
+		if strings.TrimSpace(p) == "" {
+			continue
+		}
+		if err := validateRoutePattern(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,313 @@
+package db
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"wslbridge/internal/config"
+	"wslbridge/internal/secrets"
+)
+
+// TestMatchAutoResolvePattern verifies allowlist glob matching.
+func TestMatchAutoResolvePattern(t *testing.T) {
+	cases := []struct {
+		pattern  string
+		database string
+		want     bool
+	}{
+		{"", "anything", true},
+		{"*-db", "example-db", true},
+		{"*-db", "EXAMPLE-DB", true},
+		{"*-db", "example-dbx", false},
+		{"tenant_*, *-db", "tenant_42", true},
+		{"tenant_*", "tenant", false},
+	}
+
+	for _, tc := range cases {
+		if got := matchAutoResolvePattern(tc.pattern, tc.database); got != tc.want {
+			t.Fatalf("matchAutoResolvePattern(%q, %q) = %v, want %v", tc.pattern, tc.database, got, tc.want)
+		}
+	}
+}
+
+// TestNextAutoResolvePattern verifies that `db auto on` keeps the pattern
+// without --pattern and resets it with `--pattern=` or `--pattern='*'`.
+func TestNextAutoResolvePattern(t *testing.T) {
+	for _, tc := range []struct{ cur, pattern, want string }{
+		{"tenant_*", "", "tenant_*"},
+		{"tenant_*", "*-db", "*-db"},
+		{"tenant_*", "*", ""},
+		{"tenant_*", " * ", ""},
+		{"", "", ""},
+	} {
+		if got := nextAutoResolvePattern(tc.cur, tc.pattern); got != tc.want {
+			t.Fatalf("nextAutoResolvePattern(%q, %q) = %q, want %q", tc.cur, tc.pattern, got, tc.want)
+		}
+	}
+}
+
+// TestValidateAutoResolvePattern verifies rejection of malformed globs.
+func TestValidateAutoResolvePattern(t *testing.T) {
+	if err := validateAutoResolvePattern("*-db,tenant_*"); err != nil {
+		t.Fatalf("validateAutoResolvePattern error: %v", err)
+	}
+	if err := validateAutoResolvePattern("[bad"); err == nil {
+		t.Fatalf("validateAutoResolvePattern expected error for malformed glob")
+	}
+}
+
+// TestProxyRouter_AutoResolve verifies on-demand resolution and route persistence.
+func TestProxyRouter_AutoResolve(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen error: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	serviceDiscovery, seenQueries := startServiceDiscoveryStub(t, map[string]string{
+		"example-db": ln.Addr().String(),
+	})
+	defer serviceDiscovery.Close()
+	sdURL, err := url.Parse(serviceDiscovery.URL)
+	if err != nil {
+		t.Fatalf("parse Service discovery URL: %v", err)
+	}
+
+	routesPath := filepath.Join(t.TempDir(), "db-routes.json")
+	if err := writeProxyRoutes(routesPath, proxyRoutesFile{
+		Discovery: &proxyDiscovery{
+			Scheme:       "http",
+			Host:         sdURL.Host,
+			EndpointMask: defaultEndpointMask,
+			PreferRole:   "master",
+		},
+		Auto: &proxyAutoResolve{Pattern: "*-db"},
+	}); err != nil {
+		t.Fatalf("writeProxyRoutes error: %v", err)
+	}
+
+	router := newProxyRouter(routesPath)
+	route, err := router.route(startupRequest{Database: "example-db"})
+	if err != nil {
+		t.Fatalf("route(example-db) error: %v", err)
+	}
+	if route.TargetAddr != ln.Addr().String() || !route.Auto {
+		t.Fatalf("route(example-db) = %+v", route)
+	}
+
+	routes := mustLoadRoutes(t, routesPath)
+	if got := routes.Services["example-db"].TargetAddr; got != ln.Addr().String() {
+		t.Fatalf("persisted route target=%q, want %q", got, ln.Addr().String())
+	}
+
+	if _, err := router.route(startupRequest{Database: "typo"}); err == nil || !strings.Contains(err.Error(), "is not configured") {
+		t.Fatalf("route(typo) error=%v, want not configured", err)
+	}
+	if seenQueries.Contains("typo.pg:bouncer") {
+		t.Fatalf("Service discovery must not be queried for names outside the pattern")
+	}
+
+	if _, err := router.route(startupRequest{Database: "missing-db"}); err == nil {
+		t.Fatalf("route(missing-db) expected error")
+	}
+	if _, err := router.route(startupRequest{Database: "missing-db"}); err == nil || !strings.Contains(err.Error(), "retry later") {
+		t.Fatalf("route(missing-db) second error=%v, want retry later", err)
+	}
+}
+
+// startAcceptingListener returns a listener that accepts and closes
+// connections until the test ends.
+func startAcceptingListener(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen error: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+	return ln
+}
+
+func writeAutoRoutes(t *testing.T, discoveryURL string) string {
+	t.Helper()
+	sdURL, err := url.Parse(discoveryURL)
+	if err != nil {
+		t.Fatalf("parse Service discovery URL: %v", err)
+	}
+	routesPath := filepath.Join(t.TempDir(), "db-routes.json")
+	if err := writeProxyRoutes(routesPath, proxyRoutesFile{
+		Discovery: &proxyDiscovery{
+			Scheme:       "http",
+			Host:         sdURL.Host,
+			EndpointMask: defaultEndpointMask,
+			PreferRole:   "master",
+		},
+		Auto: &proxyAutoResolve{},
+	}); err != nil {
+		t.Fatalf("writeProxyRoutes error: %v", err)
+	}
+	return routesPath
+}
+
+// TestProxyRouter_AutoResolveConcurrent verifies that a slow lookup neither
+// blocks other databases nor is repeated for concurrent sessions.
+func TestProxyRouter_AutoResolveConcurrent(t *testing.T) {
+	ln := startAcceptingListener(t)
+	release := make(chan struct{})
+	var slowQueries atomic.Int32
+	serviceDiscovery := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		service := strings.TrimSuffix(r.URL.Query().Get("service"), ".pg:bouncer")
+		if service == "slow-db" {
+			slowQueries.Add(1)
+			<-release
+		}
+		_ = json.NewEncoder(w).Encode([]Endpoint{{InstanceName: "db-" + service, Address: ln.Addr().String(), Role: "master", IsDefaultRoute: true}})
+	}))
+	defer serviceDiscovery.Close()
+	defer close(release)
+
+	router := newProxyRouter(writeAutoRoutes(t, serviceDiscovery.URL))
+	var wg sync.WaitGroup
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := router.route(startupRequest{Database: "slow-db"})
+			errs <- err
+		}()
+	}
+	for slowQueries.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := router.route(startupRequest{Database: "fast-db"})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("route(fast-db) error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("route(fast-db) blocked behind slow-db")
+	}
+
+	release <- struct{}{}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("route(slow-db) error: %v", err)
+		}
+	}
+	if n := slowQueries.Load(); n != 1 {
+		t.Fatalf("slow-db was queried %d times, want 1", n)
+	}
+}
+
+// TestProxyRouter_EvictUnreachable verifies that an auto-resolved target
+// that stops answering is forgotten and resolved again.
+func TestProxyRouter_EvictUnreachable(t *testing.T) {
+	ln := startAcceptingListener(t)
+	serviceDiscovery, seenQueries := startServiceDiscoveryStub(t, map[string]string{
+		"example-db": ln.Addr().String(),
+	})
+	defer serviceDiscovery.Close()
+
+	routesPath := writeAutoRoutes(t, serviceDiscovery.URL)
+	router := newProxyRouter(routesPath)
+	if _, err := router.route(startupRequest{Database: "example-db"}); err != nil {
+		t.Fatalf("route(example-db) error: %v", err)
+	}
+
+	router.evict(ln.Addr().String())
+	if _, ok := mustLoadRoutes(t, routesPath).Services["example-db"]; ok {
+		t.Fatalf("evicted route is still persisted")
+	}
+	if _, err := router.route(startupRequest{Database: "example-db"}); err != nil {
+		t.Fatalf("route(example-db) after evict error: %v", err)
+	}
+	if n := seenQueries.Count("example-db.pg:bouncer"); n != 2 {
+		t.Fatalf("example-db was queried %d times, want 2", n)
+	}
+}
+
+// TestProxyDiscoveryFromConfig_KeepsSecretRefs verifies that the routes file
+// carries the secret:// reference of the discovery token, not the token, and
+// that the daemon resolves it from the stores or reports why it cannot.
+func TestProxyDiscoveryFromConfig_KeepsSecretRefs(t *testing.T) {
+	dir := t.TempDir()
+	store := filepath.Join(dir, "secret-store")
+	if err := secrets.Open(store).Set(discoveryTokenSecret, "s3cret"); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "config.yaml")
+	body := "version: 2\n" +
+		"db:\n" +
+		"  service_discovery_scheme: https\n" +
+		"  service_discovery_host: sd.example.internal\n" +
+		"  endpoint_mask: /endpoints/{service}\n" +
+		"  discovery_auth:\n" +
+		"    method: bearer\n" +
+		"    token: secret://" + discoveryTokenSecret + "\n"
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path, store)
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if cfg.DB.DiscoveryAuth.Token != "s3cret" {
+		t.Fatalf("loaded token = %q", cfg.DB.DiscoveryAuth.Token)
+	}
+
+	discovery := proxyDiscoveryFromConfig(cfg, []string{t.TempDir(), store})
+	if discovery == nil || discovery.Auth == nil {
+		t.Fatalf("proxyDiscoveryFromConfig = %+v", discovery)
+	}
+	b, err := json.Marshal(discovery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "s3cret") {
+		t.Fatalf("routes discovery leaks the token: %s", b)
+	}
+	if got, err := discovery.dbConfig(); err != nil || got.DiscoveryAuth.Token != "s3cret" {
+		t.Fatalf("daemon token = %q, %v", got.DiscoveryAuth.Token, err)
+	}
+	discovery.SecretStores = nil
+	if _, err := discovery.dbConfig(); err == nil {
+		t.Fatalf("dbConfig expected error for an unresolvable token")
+	}
+}
@@ -0,0 +1,441 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"wslbridge/internal/config"
+	"wslbridge/internal/secrets"
+)
+
+// BundleKind marks a file written by `config export --db`.
+const BundleKind = "wslbridge-db-bundle"
+
+const (
+	bundleVersion   = 1
+	bundleMaxSize   = 1 << 20
+	bundleFetchWait = 15 * time.Second
+)
+
+// Bundle is the portable part of the db config that a team can share:
+// discovery settings, masks, services with their per-service options and
+// role routing. Machine-specific state (targets, instances, pins, local
+// listen address, file paths) and credentials are left out.
+type Bundle struct {
+	Kind    string   `yaml:"kind"`
+	Version int      `yaml:"version"`
+	DB      BundleDB `yaml:"db"`
+}
+
+// BundleDB holds the db settings of a bundle. The keys match the db.* config
+// keys; discovery_auth only carries the method, credentials are set locally.
+type BundleDB struct {
+	DiscoveryProvider      string                   `yaml:"discovery_provider,omitempty"`
+	DiscoveryServiceMask   string                   `yaml:"discovery_service_mask,omitempty"`
+	ServiceDiscoveryScheme string                   `yaml:"service_discovery_scheme,omitempty"`
+	ServiceDiscoveryHost   string                   `yaml:"service_discovery_host,omitempty"`
+	ServiceDiscoveryHosts  []string                 `yaml:"service_discovery_hosts,omitempty"`
+	DiscoveryHostOrder     string                   `yaml:"discovery_host_order,omitempty"`
+	DiscoveryAttempts      int                      `yaml:"discovery_attempts,omitempty"`
+	DiscoveryBackoff       string                   `yaml:"discovery_backoff,omitempty"`
+	EndpointMask           string                   `yaml:"endpoint_mask,omitempty"`
+	CatalogMask            string                   `yaml:"catalog_mask,omitempty"`
+	EndpointMapping        config.DBEndpointMapping `yaml:"endpoint_mapping,omitempty"`
+	DiscoveryAuth          config.DBDiscoveryAuth   `yaml:"discovery_auth,omitempty"`
+	DiscoveryCacheTTL      string                   `yaml:"discovery_cache_ttl,omitempty"`
+	AuthQuery              string                   `yaml:"auth_query,omitempty"`
+	PreferRole             string                   `yaml:"prefer_role,omitempty"`
+	AutoResolve            bool                     `yaml:"auto_resolve,omitempty"`
+	AutoResolvePattern     string                   `yaml:"auto_resolve_pattern,omitempty"`
+	RouteRules             []config.DBRouteRule     `yaml:"route_rules,omitempty"`
+	ServiceNames           []string                 `yaml:"service_names,omitempty"`
+	ServicePorts           map[string]int           `yaml:"service_ports,omitempty"`
+	ServiceReleases        map[string]string        `yaml:"service_releases,omitempty"`
+	ServiceVersions        map[string]string        `yaml:"service_versions,omitempty"`
+}
+
+// ExportBundle returns the portable settings of c. Values that are secret
+// references are dropped: they name entries of the local store.
+func ExportBundle(c config.DBConfig) Bundle {
+	names := normalizeServiceNames(c.ServiceNames)
+	ports := map[string]int{}
+	for name, port := range c.ServicePorts {
+		if key := serviceKey(name); port != 0 && containsServiceKey(names, key) {
+			ports[key] = port
+		}
+	}
+	if len(ports) == 0 {
+		ports = nil
+	}
+	return Bundle{
+		Kind:    BundleKind,
+		Version: bundleVersion,
+		DB: BundleDB{
+			DiscoveryProvider:      portable(c.DiscoveryProvider),
+			DiscoveryServiceMask:   portable(c.DiscoveryServiceMask),
+			ServiceDiscoveryScheme: portable(c.ServiceDiscoveryScheme),
+			ServiceDiscoveryHost:   portable(c.ServiceDiscoveryHost),
+			ServiceDiscoveryHosts:  c.ServiceDiscoveryHosts,
+			DiscoveryHostOrder:     portable(c.DiscoveryHostOrder),
+			DiscoveryAttempts:      c.DiscoveryAttempts,
+			DiscoveryBackoff:       portable(c.DiscoveryBackoff),
+			EndpointMask:           portable(c.EndpointMask),
+			CatalogMask:            portable(c.CatalogMask),
+			EndpointMapping:        c.EndpointMapping,
+			DiscoveryAuth:          config.DBDiscoveryAuth{Method: portable(c.DiscoveryAuth.Method)},
+			DiscoveryCacheTTL:      portable(c.DiscoveryCacheTTL),
+			AuthQuery:              portable(c.AuthQuery),
+			PreferRole:             portable(c.PreferRole),
+			AutoResolve:            c.AutoResolve,
+			AutoResolvePattern:     portable(c.AutoResolvePattern),
+			RouteRules:             c.RouteRules,
+			ServiceNames:           names,
+			ServicePorts:           ports,
+			ServiceReleases:        portableValues(names, c.ServiceReleases),
+			ServiceVersions:        portableValues(names, c.ServiceVersions),
+		},
+	}
+}
+
+func portable(s string) string {
+	if secrets.IsRef(s) {
+		return ""
+	}
+	return s
+}
+
+func portableValues(names []string, values map[string]string) map[string]string {
+	out := normalizeServiceValues(names, values)
+	for name, val := range out {
+		if secrets.IsRef(val) {
+			delete(out, name)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func containsServiceKey(names []string, key string) bool {
+	for _, name := range names {
+		if serviceKey(name) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalBundle returns b as YAML.
+func MarshalBundle(b Bundle) ([]byte, error) {
+	return yaml.Marshal(b)
+}
+
+// ReadBundle reads a bundle from a file or an http(s) URL.
+func ReadBundle(source string) ([]byte, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.ReadFile(source)
+	}
+	client := &http.Client{Timeout: bundleFetchWait}
+	resp, err := client.Get(source)
+	if err != nil {
+		return nil, fmt.Errorf("fetch bundle: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch bundle %s: %s", source, resp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, bundleMaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("fetch bundle: %w", err)
+	}
+	if len(b) > bundleMaxSize {
+		return nil, fmt.Errorf("fetch bundle %s: larger than %d bytes", source, bundleMaxSize)
+	}
+	return b, nil
+}
+
+// ParseBundle decodes and validates a bundle.
+func ParseBundle(data []byte) (Bundle, error) {
+	var b Bundle
+	dec := yaml.NewDecoder(strings.NewReader(string(data)))
+	dec.KnownFields(true)
+	if err := dec.Decode(&b); err != nil {
+		return Bundle{}, fmt.Errorf("parse bundle: %w", err)
+	}
+	if b.Kind != BundleKind {
+		return Bundle{}, fmt.Errorf("not a db bundle: kind is %q, want %q", b.Kind, BundleKind)
+	}
+	if b.Version != bundleVersion {
+		return Bundle{}, fmt.Errorf("unsupported bundle version %d (supported: %d)", b.Version, bundleVersion)
+	}
+	// A bundle may come from anywhere; it must not bring references (a
+	// cmd:// one runs a command) into the local config.
+	if path, ref, ok := bundleSecretRef(reflect.ValueOf(b.DB), "db"); ok {
+		return Bundle{}, fmt.Errorf("bundle: %s: secret reference %q is not allowed in a bundle", path, ref)
+	}
+	var cfg config.Config
+	cfg.DB = b.DB.toRuntime()
+	for _, k := range config.Keys() {
+		if !strings.HasPrefix(k.Path, "db.") {
+			continue
+		}
+		if err := ValidateConfigKey(cfg, k.Path); err != nil {
+			return Bundle{}, fmt.Errorf("bundle: %w", err)
+		}
+	}
+	return b, nil
+}
+
+// bundleSecretRef returns the YAML path and value of the first string under v
+// that is a secret reference, the values portable drops on export.
+func bundleSecretRef(v reflect.Value, path string) (string, string, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		if secrets.IsRef(v.String()) {
+			return path, v.String(), true
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("yaml"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			if p, ref, ok := bundleSecretRef(v.Field(i), path+"."+name); ok {
+				return p, ref, true
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if p, ref, ok := bundleSecretRef(v.Index(i), fmt.Sprintf("%s[%d]", path, i)); ok {
+				return p, ref, true
+			}
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			p := fmt.Sprintf("%s.%v", path, key.Interface())
+			if secrets.IsRef(fmt.Sprint(key.Interface())) {
+				return p, fmt.Sprint(key.Interface()), true
+			}
+			if p, ref, ok := bundleSecretRef(v.MapIndex(key), p); ok {
+				return p, ref, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+func (b BundleDB) toRuntime() config.DBConfig {
+	return config.DBConfig{
+		DiscoveryProvider:      b.DiscoveryProvider,
+		DiscoveryServiceMask:   b.DiscoveryServiceMask,
+		ServiceDiscoveryScheme: b.ServiceDiscoveryScheme,
+		ServiceDiscoveryHost:   b.ServiceDiscoveryHost,
+		ServiceDiscoveryHosts:  b.ServiceDiscoveryHosts,
+		DiscoveryHostOrder:     b.DiscoveryHostOrder,
+		DiscoveryAttempts:      b.DiscoveryAttempts,
+		DiscoveryBackoff:       b.DiscoveryBackoff,
+		EndpointMask:           b.EndpointMask,
+		CatalogMask:            b.CatalogMask,
+		EndpointMapping:        b.EndpointMapping,
+		DiscoveryAuth:          config.DBDiscoveryAuth{Method: b.DiscoveryAuth.Method},
+		DiscoveryCacheTTL:      b.DiscoveryCacheTTL,
+		AuthQuery:              b.AuthQuery,
+		PreferRole:             b.PreferRole,
+		AutoResolve:            b.AutoResolve,
+		AutoResolvePattern:     b.AutoResolvePattern,
+		RouteRules:             b.RouteRules,
+		ServiceNames:           b.ServiceNames,
+		ServicePorts:           b.ServicePorts,
+		ServiceReleases:        b.ServiceReleases,
+		ServiceVersions:        b.ServiceVersions,
+	}
+}
+
+// ImportChange is one difference between the local config and a bundle.
+// Conflict is set when the local config already has another value.
+type ImportChange struct {
+	Key      string
+	Old      string
+	New      string
+	Conflict bool
+
+	apply func(*config.DBConfig)
+}
+
+// Apply writes the bundle value of the change into c.
+func (ch ImportChange) Apply(c *config.DBConfig) { ch.apply(c) }
+
+// PlanImport lists the changes that merging b into cur would make, in config
+// key order. Services are added to the local list; local values equal to the
+// bundle, and bundle values that are empty or secret references, are skipped.
+func PlanImport(cur config.DBConfig, b Bundle) []ImportChange {
+	in := b.DB
+	var out []ImportChange
+	add := func(ch *ImportChange) {
+		if ch != nil {
+			out = append(out, *ch)
+		}
+	}
+	add(planString("db.discovery_provider", cur.DiscoveryProvider, in.DiscoveryProvider, func(c *config.DBConfig, v string) { c.DiscoveryProvider = v }))
+	add(planString("db.discovery_service_mask", cur.DiscoveryServiceMask, in.DiscoveryServiceMask, func(c *config.DBConfig, v string) { c.DiscoveryServiceMask = v }))
+	add(planString("db.service_discovery_scheme", cur.ServiceDiscoveryScheme, in.ServiceDiscoveryScheme, func(c *config.DBConfig, v string) { c.ServiceDiscoveryScheme = v }))
+	add(planString("db.service_discovery_host", cur.ServiceDiscoveryHost, in.ServiceDiscoveryHost, func(c *config.DBConfig, v string) { c.ServiceDiscoveryHost = v }))
+	add(planValue("db.service_discovery_hosts", cur.ServiceDiscoveryHosts, in.ServiceDiscoveryHosts, func(c *config.DBConfig, v []string) { c.ServiceDiscoveryHosts = v }))
+	add(planString("db.discovery_host_order", cur.DiscoveryHostOrder, in.DiscoveryHostOrder, func(c *config.DBConfig, v string) { c.DiscoveryHostOrder = v }))
+	add(planValue("db.discovery_attempts", cur.DiscoveryAttempts, in.DiscoveryAttempts, func(c *config.DBConfig, v int) { c.DiscoveryAttempts = v }))
+	add(planString("db.discovery_backoff", cur.DiscoveryBackoff, in.DiscoveryBackoff, func(c *config.DBConfig, v string) { c.DiscoveryBackoff = v }))
+	add(planString("db.endpoint_mask", cur.EndpointMask, in.EndpointMask, func(c *config.DBConfig, v string) { c.EndpointMask = v }))
+	add(planString("db.catalog_mask", cur.CatalogMask, in.CatalogMask, func(c *config.DBConfig, v string) { c.CatalogMask = v }))
+	add(planValue("db.endpoint_mapping", cur.EndpointMapping, in.EndpointMapping, func(c *config.DBConfig, v config.DBEndpointMapping) { c.EndpointMapping = v }))
+	add(planString("db.discovery_auth.method", cur.DiscoveryAuth.Method, in.DiscoveryAuth.Method, func(c *config.DBConfig, v string) { c.DiscoveryAuth.Method = v }))
+	add(planString("db.discovery_cache_ttl", cur.DiscoveryCacheTTL, in.DiscoveryCacheTTL, func(c *config.DBConfig, v string) { c.DiscoveryCacheTTL = v }))
+	add(planString("db.auth_query", cur.AuthQuery, in.AuthQuery, func(c *config.DBConfig, v string) { c.AuthQuery = v }))
+	add(planString("db.prefer_role", cur.PreferRole, in.PreferRole, func(c *config.DBConfig, v string) { c.PreferRole = v }))
+	add(planValue("db.auto_resolve", cur.AutoResolve, in.AutoResolve, func(c *config.DBConfig, v bool) { c.AutoResolve = v }))
+	add(planString("db.auto_resolve_pattern", cur.AutoResolvePattern, in.AutoResolvePattern, func(c *config.DBConfig, v string) { c.AutoResolvePattern = v }))
+	add(planValue("db.route_rules", cur.RouteRules, in.RouteRules, func(c *config.DBConfig, v []config.DBRouteRule) { c.RouteRules = v }))
+
+	for _, name := range normalizeServiceNames(in.ServiceNames) {
+		if containsServiceKey(cur.ServiceNames, serviceKey(name)) {
+			continue
+		}
+		name := name
+		out = append(out, ImportChange{
+			Key:   "db.service_names",
+			New:   "+" + name,
+			apply: func(c *config.DBConfig) { c.ServiceNames = upsertServiceName(c.ServiceNames, name) },
+		})
+	}
+	for _, name := range sortedKeys(in.ServicePorts) {
+		key, port := serviceKey(name), in.ServicePorts[name]
+		old := cur.ServicePorts[key]
+		if port == 0 || old == port {
+			continue
+		}
+		out = append(out, ImportChange{
+			Key:      "db.service_ports." + key,
+			Old:      portText(old),
+			New:      strconv.Itoa(port),
+			Conflict: old != 0,
+			apply: func(c *config.DBConfig) {
+				if c.ServicePorts == nil {
+					c.ServicePorts = map[string]int{}
+				}
+				c.ServicePorts[key] = port
+			},
+		})
+	}
+	out = append(out, planServiceValues("db.service_releases", cur.ServiceReleases, in.ServiceReleases, func(c *config.DBConfig) *map[string]string { return &c.ServiceReleases })...)
+	out = append(out, planServiceValues("db.service_versions", cur.ServiceVersions, in.ServiceVersions, func(c *config.DBConfig) *map[string]string { return &c.ServiceVersions })...)
+	return out
+}
+
+// ImportNotes returns what still has to be set up locally after an import,
+// such as discovery credentials that bundles do not carry.
+func ImportNotes(c config.DBConfig) []string {
+	var notes []string
+	if err := validateDiscoveryAuth(c.DiscoveryAuth); err != nil {
+		notes = append(notes, err.Error()+": set it with `config set db.discovery_auth.<key> <value>`")
+	}
+	return notes
+}
+
+func planString(key, cur, next string, set func(*config.DBConfig, string)) *ImportChange {
+	cur, next = strings.TrimSpace(cur), strings.TrimSpace(portable(next))
+	if next == "" || cur == next {
+		return nil
+	}
+	return &ImportChange{Key: key, Old: cur, New: next, Conflict: cur != "", apply: func(c *config.DBConfig) { set(c, next) }}
+}
+
+func planValue[T any](key string, cur, next T, set func(*config.DBConfig, T)) *ImportChange {
+	if isZeroValue(next) || reflect.DeepEqual(cur, next) {
+		return nil
+	}
+	return &ImportChange{
+		Key:      key,
+		Old:      bundleValueText(cur),
+		New:      bundleValueText(next),
+		Conflict: !isZeroValue(cur),
+		apply:    func(c *config.DBConfig) { set(c, next) },
+	}
+}
+
+func planServiceValues(key string, cur, next map[string]string, field func(*config.DBConfig) *map[string]string) []ImportChange {
+	var out []ImportChange
+	for _, name := range sortedKeys(next) {
+		svc, val := serviceKey(name), strings.TrimSpace(portable(next[name]))
+		old := getServiceValue(cur, svc)
+		if val == "" || old == val {
+			continue
+		}
+		out = append(out, ImportChange{
+			Key:      key + "." + svc,
+			Old:      old,
+			New:      val,
+			Conflict: old != "",
+			apply: func(c *config.DBConfig) {
+				values := field(c)
+				if *values == nil {
+					*values = map[string]string{}
+				}
+				(*values)[svc] = val
+			},
+		})
+	}
+	return out
+}
+
+func isZeroValue(v any) bool {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map:
+		return rv.Len() == 0
+	default:
+		return rv.IsZero()
+	}
+}
+
+func bundleValueText(v any) string {
+	if isZeroValue(v) {
+		return ""
+	}
+	switch val := v.(type) {
+	case []string:
+		return strings.Join(val, ",")
+	case string, int, bool:
+		return fmt.Sprint(val)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+func portText(port int) string {
+	if port == 0 {
+		return ""
+	}
+	return strconv.Itoa(port)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
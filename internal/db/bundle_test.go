@@ -0,0 +1,122 @@
+package db
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"wslbridge/internal/config"
+)
+
+// TestExportBundle verifies that bundles keep shared settings and drop
+// machine-specific state and secrets.
+func TestExportBundle(t *testing.T) {
+	c := config.DBConfig{
+		ServiceDiscoveryHost: "sd.example.com",
+		EndpointMask:         "%s.db.local",
+		DiscoveryAuth:        config.DBDiscoveryAuth{Method: DiscoveryAuthBearer, TokenFile: "/home/u/token"},
+		AuthLookupUser:       "pgbouncer",
+		AuthLookupPass:       "hunter2",
+		AuthQuery:            "secret://query",
+		ServiceNames:         []string{"Orders-DB", "billing-db"},
+		ServicePorts:         map[string]int{"orders-db": 6433, "gone-db": 7000},
+		ServiceTargets:       map[string]string{"orders-db": "10.0.0.5:5432"},
+		PinnedInstances:      map[string]string{"orders-db": "pg-2"},
+		ServiceVersions:      map[string]string{"billing-db": ">=15"},
+		LocalPort:            15432,
+		PreferRole:           "sync",
+	}
+	b := ExportBundle(c)
+	data, err := MarshalBundle(b)
+	if err != nil {
+		t.Fatalf("MarshalBundle error: %v", err)
+	}
+	for _, leak := range []string{"hunter2", "token", "10.0.0.5", "pg-2", "15432", "pgbouncer", "secret://", "gone-db"} {
+		if strings.Contains(string(data), leak) {
+			t.Fatalf("bundle leaks %q:\n%s", leak, data)
+		}
+	}
+
+	parsed, err := ParseBundle(data)
+	if err != nil {
+		t.Fatalf("ParseBundle error: %v", err)
+	}
+	if !reflect.DeepEqual(parsed, b) {
+		t.Fatalf("round trip mismatch:\ngot  %+v\nwant %+v", parsed, b)
+	}
+	if parsed.DB.DiscoveryAuth.Method != DiscoveryAuthBearer || parsed.DB.ServicePorts["orders-db"] != 6433 {
+		t.Fatalf("bundle lost settings: %+v", parsed.DB)
+	}
+}
+
+func TestParseBundle_Rejects(t *testing.T) {
+	for name, body := range map[string]string{
+		"kind":     "kind: other\nversion: 1\n",
+		"version":  "kind: wslbridge-db-bundle\nversion: 9\n",
+		"unknown":  "kind: wslbridge-db-bundle\nversion: 1\ndb:\n  local_port: 1\n",
+		"invalid":  "kind: wslbridge-db-bundle\nversion: 1\ndb:\n  prefer_role: leader\n",
+		"cmd ref":  "kind: wslbridge-db-bundle\nversion: 1\ndb:\n  auth_query: \"cmd://touch /tmp/x\"\n",
+		"map ref":  "kind: wslbridge-db-bundle\nversion: 1\ndb:\n  service_names: [a]\n  service_releases:\n    a: cmd://id\n",
+		"rule ref": "kind: wslbridge-db-bundle\nversion: 1\ndb:\n  route_rules:\n    - database: env://HOME\n      service: a\n",
+	} {
+		if _, err := ParseBundle([]byte(body)); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+// TestPlanImport verifies additions, conflicts and applying changes.
+func TestPlanImport(t *testing.T) {
+	cur := config.DBConfig{
+		ServiceDiscoveryHost: "old.example.com",
+		EndpointMask:         "%s.db.local",
+		ServiceNames:         []string{"orders-db"},
+		ServicePorts:         map[string]int{"orders-db": 6433},
+		ServiceTargets:       map[string]string{"orders-db": "10.0.0.5:5432"},
+	}
+	b := Bundle{Kind: BundleKind, Version: bundleVersion, DB: BundleDB{
+		ServiceDiscoveryHost: "sd.example.com",
+		EndpointMask:         "%s.db.local",
+		PreferRole:           "sync",
+		ServiceNames:         []string{"orders-db", "billing-db"},
+		ServicePorts:         map[string]int{"orders-db": 7433, "billing-db": 6434},
+	}}
+
+	changes := PlanImport(cur, b)
+	got := map[string]bool{}
+	for _, ch := range changes {
+		got[ch.Key+"="+ch.New] = ch.Conflict
+	}
+	want := map[string]bool{
+		"db.service_discovery_host=sd.example.com": true,
+		"db.prefer_role=sync":                      false,
+		"db.service_names=+billing-db":             false,
+		"db.service_ports.billing-db=6434":         false,
+		"db.service_ports.orders-db=7433":          true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("PlanImport = %v, want %v", got, want)
+	}
+
+	for _, ch := range changes {
+		if !ch.Conflict {
+			ch.Apply(&cur)
+		}
+	}
+	if cur.ServiceDiscoveryHost != "old.example.com" || cur.ServicePorts["orders-db"] != 6433 {
+		t.Fatalf("kept values changed: %+v", cur)
+	}
+	if cur.PreferRole != "sync" || cur.ServicePorts["billing-db"] != 6434 || !reflect.DeepEqual(cur.ServiceNames, []string{"orders-db", "billing-db"}) {
+		t.Fatalf("changes not applied: %+v", cur)
+	}
+	if cur.ServiceTargets["orders-db"] != "10.0.0.5:5432" {
+		t.Fatalf("local targets must be kept: %+v", cur.ServiceTargets)
+	}
+	if len(PlanImport(cur, Bundle{DB: BundleDB{PreferRole: "sync"}})) != 0 {
+		t.Fatalf("equal values must not be planned")
+	}
+	refs := Bundle{DB: BundleDB{AuthQuery: "cmd://touch /tmp/x", ServiceReleases: map[string]string{"orders-db": "cmd://id"}}}
+	if changes := PlanImport(cur, refs); len(changes) != 0 {
+		t.Fatalf("secret references must not be planned: %+v", changes)
+	}
+}
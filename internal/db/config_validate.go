@@ -0,0 +1,156 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"wslbridge/internal/cli"
+	"wslbridge/internal/config"
+)
+
+// ValidateConfigKey checks the db.* value at the dotted key of cfg, as set by
+// `config set` or `config edit`. Empty values are valid: defaults apply.
+// Keys without a validator are accepted as they are.
+func ValidateConfigKey(cfg config.Config, key string) error {
+	c := cfg.DB
+	var err error
+	switch key {
+	case "db.discovery_provider":
+		err = validateOptional(c.DiscoveryProvider, validateDiscoveryProvider)
+	case "db.discovery_service_mask":
+		err = validateOptional(c.DiscoveryServiceMask, validateServiceNameMask)
+	case "db.discovery_file":
+		err = validateOptional(c.DiscoveryFile, validateReadableFile)
+	case "db.service_discovery_scheme":
+		err = validateOptional(c.ServiceDiscoveryScheme, validateServiceDiscoveryScheme)
+	case "db.service_discovery_host":
+		err = validateOptional(c.ServiceDiscoveryHost, validateServiceDiscoveryInput)
+	case "db.service_discovery_hosts":
+		err = validateOptional(strings.Join(c.ServiceDiscoveryHosts, ","), validateDiscoveryHosts)
+	case "db.discovery_host_order":
+		err = validateDiscoveryHostOrder(c.DiscoveryHostOrder)
+	case "db.discovery_attempts":
+		if c.DiscoveryAttempts < 0 {
+			err = fmt.Errorf("must not be negative")
+		}
+	case "db.discovery_backoff":
+		_, err = newDiscoveryRetry(config.DBConfig{DiscoveryBackoff: c.DiscoveryBackoff})
+	case "db.discovery_cache_ttl":
+		_, err = discoveryCacheTTL(c)
+	case "db.endpoint_mask":
+		err = validateOptional(c.EndpointMask, validateEndpointMask)
+	case "db.catalog_mask":
+		err = validateOptional(c.CatalogMask, func(s string) error {
+			_, err := NormalizeCatalogMask(s)
+			return err
+		})
+	case "db.endpoint_mapping.list", "db.endpoint_mapping.address", "db.endpoint_mapping.host",
+		"db.endpoint_mapping.port", "db.endpoint_mapping.instance", "db.endpoint_mapping.role",
+		"db.endpoint_mapping.weight", "db.endpoint_mapping.default_route", "db.endpoint_mapping.release",
+		"db.endpoint_mapping.version":
+		if c.EndpointMapping != (config.DBEndpointMapping{}) {
+			err = validateEndpointMapping(c.EndpointMapping)
+		}
+	case "db.discovery_auth.method":
+		err = validateOptional(c.DiscoveryAuth.Method, validateDiscoveryAuthMethod)
+	case "db.discovery_auth.token_file", "db.discovery_auth.password_file", "db.discovery_auth.client_cert", "db.discovery_auth.client_key":
+		err = validateOptional(discoveryAuthFile(c.DiscoveryAuth, key), validateReadableFile)
+	case "db.discovery_auth.ca_file":
+		err = validateOptional(c.DiscoveryAuth.CAFile, validateCAFile)
+	case "db.service_name":
+		err = validateOptional(c.ServiceName, validateServiceName)
+	case "db.service_names":
+		for _, name := range c.ServiceNames {
+			if err = validateServiceName(name); err != nil {
+				break
+			}
+		}
+	case "db.service_ports":
+		for name, port := range c.ServicePorts {
+			if err = cli.ValidatePort(strconv.Itoa(port)); err != nil {
+				err = fmt.Errorf("%s: %w", name, err)
+				break
+			}
+		}
+	case "db.service_targets", "db.pinned_addresses":
+		values := c.ServiceTargets
+		if key == "db.pinned_addresses" {
+			values = c.PinnedAddresses
+		}
+		for name, addr := range values {
+			if err = validatePinAddress(addr); err != nil {
+				err = fmt.Errorf("%s: %w", name, err)
+				break
+			}
+		}
+	case "db.service_versions":
+		for name, constraint := range c.ServiceVersions {
+			if err = validateVersionConstraints(constraint); err != nil {
+				err = fmt.Errorf("%s: %w", name, err)
+				break
+			}
+		}
+	case "db.local_host":
+		err = validateOptional(c.LocalHost, cli.ValidateHostOrIP)
+	case "db.local_port":
+		if c.LocalPort != 0 {
+			err = cli.ValidatePort(strconv.Itoa(c.LocalPort))
+		}
+	case "db.prefer_role":
+		err = validateOptional(c.PreferRole, validateRole)
+	case "db.target_address":
+		err = validateOptional(c.TargetAddress, validatePinAddress)
+	case "db.auto_resolve_pattern":
+		err = validateAutoResolvePattern(c.AutoResolvePattern)
+	case "db.route_rules":
+		for i, rule := range c.RouteRules {
+			if err = validateRouteRule(rule); err != nil {
+				err = fmt.Errorf("rule %d: %w", i+1, err)
+				break
+			}
+		}
+	case "db.environment":
+		err = validateOptional(c.Environment, validateEnvironmentName)
+	case "db.environments":
+		for name := range c.Environments {
+			if err = validateEnvironmentName(name); err != nil {
+				err = fmt.Errorf("%s: %w", name, err)
+				break
+			}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	return nil
+}
+
+func validateOptional(s string, validate func(string) error) error {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	return validate(s)
+}
+
+func validateServiceDiscoveryScheme(s string) error {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "http", "https":
+		return nil
+	default:
+		return fmt.Errorf("must be one of: http, https")
+	}
+}
+
+func discoveryAuthFile(auth config.DBDiscoveryAuth, key string) string {
+	switch key {
+	case "db.discovery_auth.token_file":
+		return auth.TokenFile
+	case "db.discovery_auth.password_file":
+		return auth.PasswordFile
+	case "db.discovery_auth.client_cert":
+		return auth.ClientCert
+	default:
+		return auth.ClientKey
+	}
+}
@@ -0,0 +1,45 @@
+package db
+
+import (
+	"testing"
+
+	"wslbridge/internal/config"
+)
+
+func TestValidateConfigKey(t *testing.T) {
+	var cfg config.Config
+	for _, k := range config.Keys() {
+		if err := ValidateConfigKey(cfg, k.Path); err != nil {
+			t.Fatalf("ValidateConfigKey(empty, %q) error: %v", k.Path, err)
+		}
+	}
+
+	tests := []struct {
+		key, value string
+		ok         bool
+	}{
+		{"db.prefer_role", "async", true},
+		{"db.prefer_role", "primary", false},
+		{"db.local_port", "6432", true},
+		{"db.local_port", "70000", false},
+		{"db.service_ports", "a-db=15433", true},
+		{"db.service_ports", "a-db=0", false},
+		{"db.pinned_addresses", "a-db=10.0.0.1:6432", true},
+		{"db.pinned_addresses", "a-db=10.0.0.1", false},
+		{"db.service_versions", "a-db=>=15", true},
+		{"db.discovery_backoff", "1s", true},
+		{"db.discovery_backoff", "soon", false},
+		{"db.discovery_provider", "etcd", false},
+		{"db.route_rules", "[{database: app, service: a-db}]", true},
+		{"db.route_rules", "[{service: a-db}]", false},
+	}
+	for _, tt := range tests {
+		var cfg config.Config
+		if err := config.Set(&cfg, tt.key, tt.value); err != nil {
+			t.Fatalf("Set(%q, %q) error: %v", tt.key, tt.value, err)
+		}
+		if err := ValidateConfigKey(cfg, tt.key); (err == nil) != tt.ok {
+			t.Fatalf("ValidateConfigKey(%q=%q) = %v, want ok=%v", tt.key, tt.value, err, tt.ok)
+		}
+	}
+}
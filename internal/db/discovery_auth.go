@@ -0,0 +1,263 @@
+package db
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"wslbridge/internal/config"
+	"wslbridge/internal/secrets"
+)
+
+// Service discovery auth methods supported by `db init`.
+const (
+	DiscoveryAuthNone   = "none"
+	DiscoveryAuthBearer = "bearer"
+	DiscoveryAuthBasic  = "basic"
+	DiscoveryAuthMTLS   = "mtls"
+)
+
+const (
+	discoveryTokenSecret    = "db-discovery-token"
+	discoveryPasswordSecret = "db-discovery-password"
+	tokenCommandTimeout     = 10 * time.Second
+)
+
+// discoveryClient performs Service discovery HTTP requests with the
+// configured auth. The zero value sends plain unauthenticated requests.
+type discoveryClient struct {
+	http *http.Client
+	auth config.DBDiscoveryAuth
+}
+
+func newDiscoveryClient(auth config.DBDiscoveryAuth) (discoveryClient, error) {
+	if err := validateDiscoveryAuth(auth); err != nil {
+		return discoveryClient{}, err
+	}
+	c := discoveryClient{auth: auth}
+	if discoveryAuthMethod(auth) != DiscoveryAuthMTLS && strings.TrimSpace(auth.CAFile) == "" {
+		return c, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if discoveryAuthMethod(auth) == DiscoveryAuthMTLS {
+		cert, err := tls.LoadX509KeyPair(auth.ClientCert, auth.ClientKey)
+		if err != nil {
+			return discoveryClient{}, fmt.Errorf("load service discovery client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if ca := strings.TrimSpace(auth.CAFile); ca != "" {
+		pem, err := os.ReadFile(ca)
+		if err != nil {
+			return discoveryClient{}, fmt.Errorf("read service discovery CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return discoveryClient{}, fmt.Errorf("service discovery CA bundle %s has no certificates", ca)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	c.http = &http.Client{Timeout: 10 * time.Second, Transport: transport}
+	return c, nil
+}
+
+// getJSON performs an authenticated GET request and decodes a JSON response body.
+func (c discoveryClient) getJSON(rawURL string, out any) error {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return fmt.Errorf("fetch Service discovery endpoints: %w", err)
+	}
+	if err := c.authorize(req); err != nil {
+		return err
+	}
+
+	client := c.http
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetch Service discovery endpoints: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &discoveryStatusError{StatusCode: resp.StatusCode}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("read Service discovery response: %w", err)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("decode Service discovery response: %w", err)
+	}
+	return nil
+}
+
+func (c discoveryClient) authorize(req *http.Request) error {
+	for name, value := range c.auth.Headers {
+		req.Header.Set(name, value)
+	}
+	switch discoveryAuthMethod(c.auth) {
+	case DiscoveryAuthBearer:
+		token, err := readDiscoveryToken(c.auth)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	case DiscoveryAuthBasic:
+		password, err := readDiscoveryPassword(c.auth)
+		if err != nil {
+			return err
+		}
+		req.SetBasicAuth(c.auth.Username, password)
+	}
+	return nil
+}
+
+func readDiscoveryToken(auth config.DBDiscoveryAuth) (string, error) {
+	if auth.Token != "" {
+		return inlineSecret("token", auth.Token)
+	}
+	if command := strings.TrimSpace(auth.TokenCommand); command != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), tokenCommandTimeout)
+		defer cancel()
+		out, err := exec.CommandContext(ctx, "sh", "-c", command).Output()
+		if err != nil {
+			return "", fmt.Errorf("run service discovery token command: %w", err)
+		}
+		token := strings.TrimSpace(string(out))
+		if token == "" {
+			return "", fmt.Errorf("service discovery token command returned an empty token")
+		}
+		return token, nil
+	}
+	token, err := readSecretFile(auth.TokenFile)
+	if err != nil {
+		return "", fmt.Errorf("read service discovery token: %w", err)
+	}
+	return token, nil
+}
+
+func readDiscoveryPassword(auth config.DBDiscoveryAuth) (string, error) {
+	if auth.Password != "" {
+		return inlineSecret("password", auth.Password)
+	}
+	password, err := readSecretFile(auth.PasswordFile)
+	if err != nil {
+		return "", fmt.Errorf("read service discovery password: %w", err)
+	}
+	return password, nil
+}
+
+// inlineSecret returns a token or password from config. Config loading
+// replaces references with their values, so one still left here could not
+// be resolved and must not go out as the credential.
+func inlineSecret(what, val string) (string, error) {
+	if secrets.IsRef(val) {
+		return "", fmt.Errorf("service discovery %s %s could not be resolved", what, val)
+	}
+	return strings.TrimSpace(val), nil
+}
+
+func readSecretFile(path string) (string, error) {
+	b, err := os.ReadFile(strings.TrimSpace(path))
+	if err != nil {
+		return "", err
+	}
+	val := strings.TrimSpace(string(b))
+	if val == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return val, nil
+}
+
+// storeSecret saves value in the secret store under dir and returns the
+// secret:// reference config keeps instead.
+func storeSecret(dir, name, value string) (string, error) {
+	if err := secrets.Open(dir).Set(name, strings.TrimSpace(value)); err != nil {
+		return "", err
+	}
+	return secrets.SchemeSecret + name, nil
+}
+
+func discoveryAuthMethod(auth config.DBDiscoveryAuth) string {
+	method := strings.ToLower(strings.TrimSpace(auth.Method))
+	if method == "" {
+		return DiscoveryAuthNone
+	}
+	return method
+}
+
+func validateDiscoveryAuthMethod(s string) error {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case DiscoveryAuthNone, DiscoveryAuthBearer, DiscoveryAuthBasic, DiscoveryAuthMTLS:
+		return nil
+	default:
+		return fmt.Errorf("must be one of: none, bearer, basic, mtls")
+	}
+}
+
+func validateDiscoveryAuth(auth config.DBDiscoveryAuth) error {
+	method := discoveryAuthMethod(auth)
+	if err := validateDiscoveryAuthMethod(method); err != nil {
+		return fmt.Errorf("service discovery auth method %q: %w", auth.Method, err)
+	}
+	switch method {
+	case DiscoveryAuthBearer:
+		if strings.TrimSpace(auth.Token) == "" && strings.TrimSpace(auth.TokenFile) == "" && strings.TrimSpace(auth.TokenCommand) == "" {
+			return fmt.Errorf("service discovery bearer auth needs token, token_file or token_command")
+		}
+	case DiscoveryAuthBasic:
+		if strings.TrimSpace(auth.Username) == "" || (strings.TrimSpace(auth.Password) == "" && strings.TrimSpace(auth.PasswordFile) == "") {
+			return fmt.Errorf("service discovery basic auth needs username and password or password_file")
+		}
+	case DiscoveryAuthMTLS:
+		if strings.TrimSpace(auth.ClientCert) == "" || strings.TrimSpace(auth.ClientKey) == "" {
+			return fmt.Errorf("service discovery mtls auth needs client_cert and client_key")
+		}
+	}
+	return nil
+}
+
+func discoveryAuthLabel(auth config.DBDiscoveryAuth) string {
+	method := discoveryAuthMethod(auth)
+	var details []string
+	switch method {
+	case DiscoveryAuthBearer:
+		switch {
+		case auth.Token != "":
+			details = append(details, "token")
+		case auth.TokenCommand != "":
+			details = append(details, "token command")
+		default:
+			details = append(details, "token file "+auth.TokenFile)
+		}
+	case DiscoveryAuthBasic:
+		details = append(details, "user "+auth.Username)
+	case DiscoveryAuthMTLS:
+		details = append(details, "cert "+auth.ClientCert)
+	}
+	if len(auth.Headers) > 0 {
+		details = append(details, fmt.Sprintf("%d header(s)", len(auth.Headers)))
+	}
+	if auth.CAFile != "" {
+		details = append(details, "ca "+auth.CAFile)
+	}
+	if len(details) == 0 {
+		return method
+	}
+	return fmt.Sprintf("%s (%s)", method, strings.Join(details, ", "))
+}
@@ -0,0 +1,187 @@
+package db
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"wslbridge/internal/config"
+)
+
+const authTestEndpoints = `[{"InstanceName":"db-a","Address":"10.0.0.1:6432","Role":"master","IsDefaultRoute":true}]`
+
+// TestDiscoveryClient_BearerAndHeaders verifies token and static header injection.
+func TestDiscoveryClient_BearerAndHeaders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" || r.Header.Get("X-Team") != "db" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(authTestEndpoints))
+	}))
+	defer srv.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, auth := range map[string]config.DBDiscoveryAuth{
+		"inline":  {Method: "bearer", Token: "s3cret", Headers: map[string]string{"X-Team": "db"}},
+		"file":    {Method: "bearer", TokenFile: tokenFile, Headers: map[string]string{"X-Team": "db"}},
+		"command": {Method: "bearer", TokenCommand: "echo s3cret", Headers: map[string]string{"X-Team": "db"}},
+	} {
+		client, err := newDiscoveryClient(auth)
+		if err != nil {
+			t.Fatalf("%s: newDiscoveryClient error: %v", name, err)
+		}
+		if _, err := fetchEndpoints(client, srv.URL, config.DBEndpointMapping{}); err != nil {
+			t.Fatalf("%s: fetchEndpoints error: %v", name, err)
+		}
+	}
+
+	if _, err := FetchEndpoints(srv.URL); err == nil {
+		t.Fatalf("FetchEndpoints expected error without credentials")
+	}
+	client, err := newDiscoveryClient(config.DBDiscoveryAuth{Method: "bearer", Token: "secret://" + discoveryTokenSecret})
+	if err != nil {
+		t.Fatalf("newDiscoveryClient error: %v", err)
+	}
+	if _, err := fetchEndpoints(client, srv.URL, config.DBEndpointMapping{}); err == nil {
+		t.Fatalf("fetchEndpoints expected error for an unresolved token reference")
+	}
+}
+
+// TestDiscoveryClient_BasicAuth verifies basic auth with a stored password
+// and a password file.
+func TestDiscoveryClient_BasicAuth(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "svc" || pass != "pa55" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(authTestEndpoints))
+	}))
+	defer srv.Close()
+
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(passwordFile, []byte("pa55\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, auth := range []config.DBDiscoveryAuth{
+		{Method: "basic", Username: "svc", Password: "pa55"},
+		{Method: "basic", Username: "svc", PasswordFile: passwordFile},
+	} {
+		client, err := newDiscoveryClient(auth)
+		if err != nil {
+			t.Fatalf("newDiscoveryClient error: %v", err)
+		}
+		if _, err := fetchEndpoints(client, srv.URL, config.DBEndpointMapping{}); err != nil {
+			t.Fatalf("fetchEndpoints error: %v", err)
+		}
+	}
+}
+
+// TestDiscoveryClient_MTLS verifies client certificates and a custom CA bundle.
+func TestDiscoveryClient_MTLS(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(authTestEndpoints))
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	writePEM(t, caFile, "CERTIFICATE", srv.Certificate().Raw)
+	certFile, keyFile := writeClientCert(t, dir)
+
+	if _, err := newDiscoveryClient(config.DBDiscoveryAuth{Method: "mtls"}); err == nil {
+		t.Fatalf("newDiscoveryClient expected error without certificate")
+	}
+
+	client, err := newDiscoveryClient(config.DBDiscoveryAuth{Method: "mtls", ClientCert: certFile, ClientKey: keyFile, CAFile: caFile})
+	if err != nil {
+		t.Fatalf("newDiscoveryClient error: %v", err)
+	}
+	if _, err := fetchEndpoints(client, srv.URL, config.DBEndpointMapping{}); err != nil {
+		t.Fatalf("fetchEndpoints error: %v", err)
+	}
+
+	caOnly, err := newDiscoveryClient(config.DBDiscoveryAuth{CAFile: caFile})
+	if err != nil {
+		t.Fatalf("newDiscoveryClient error: %v", err)
+	}
+	if _, err := fetchEndpoints(caOnly, srv.URL, config.DBEndpointMapping{}); err == nil {
+		t.Fatalf("fetchEndpoints expected error without client certificate")
+	}
+}
+
+func writeClientCert(t *testing.T, dir string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "wslbridge-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+// TestValidateReadableFile verifies that secret file prompts reject
+// missing files and directories and accept pasted paths with whitespace.
+func TestValidateReadableFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "password")
+	if err := os.WriteFile(path, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatalf("write password file: %v", err)
+	}
+	if err := validateReadableFile(" " + path + " \n"); err != nil {
+		t.Fatalf("validateReadableFile(%q) error: %v", path, err)
+	}
+	for _, bad := range []string{"", filepath.Join(dir, "missing"), dir} {
+		if err := validateReadableFile(bad); err == nil {
+			t.Fatalf("validateReadableFile(%q) expected error", bad)
+		}
+	}
+}
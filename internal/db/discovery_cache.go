@@ -0,0 +1,191 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"wslbridge/internal/config"
+)
+
+// defaultDiscoveryCacheTTL bounds how old cached endpoints may be when they
+// are used as an offline fallback.
+const defaultDiscoveryCacheTTL = 7 * 24 * time.Hour
+
+// discoveryCacheFile stores the last endpoint list received per service.
+type discoveryCacheFile struct {
+	Services map[string]discoveryCacheEntry `json:"services"`
+}
+
+type discoveryCacheEntry struct {
+	Service   string     `json:"service"`
+	Source    string     `json:"source"`
+	Host      string     `json:"host,omitempty"`
+	FetchedAt time.Time  `json:"fetched_at"`
+	Endpoints []Endpoint `json:"endpoints"`
+}
+
+func loadDiscoveryCache(path string) (discoveryCacheFile, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return discoveryCacheFile{Services: map[string]discoveryCacheEntry{}}, nil
+		}
+		return discoveryCacheFile{}, err
+	}
+	var cache discoveryCacheFile
+	if err := json.Unmarshal(b, &cache); err != nil {
+		return discoveryCacheFile{}, fmt.Errorf("decode discovery cache: %w", err)
+	}
+	if cache.Services == nil {
+		cache.Services = map[string]discoveryCacheEntry{}
+	}
+	return cache, nil
+}
+
+func storeDiscoveryCache(path string, entry discoveryCacheEntry) error {
+	cache, err := loadDiscoveryCache(path)
+	if err != nil {
+		// A corrupt cache is rebuilt rather than blocking discovery.
+		cache = discoveryCacheFile{Services: map[string]discoveryCacheEntry{}}
+	}
+	cache.Services[serviceKey(entry.Service)] = entry
+	b, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal discovery cache: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, b)
+}
+
+// discoveryUnreachable reports whether err means service discovery could
+// not be reached: a retryable error (see isRetryableDiscoveryError) or a
+// failed DNS lookup other than an unknown name. Only then are cached
+// endpoints used; 4xx, auth, TLS and decode errors and unknown services are
+// not hidden behind stale data.
+func discoveryUnreachable(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return !dnsErr.IsNotFound
+	}
+	return isRetryableDiscoveryError(err)
+}
+
+// lookupDiscoveryCache returns the cached entry for service when it was
+// fetched from the same source and is younger than ttl.
+func lookupDiscoveryCache(path, service, source string, ttl time.Duration, now time.Time) (discoveryCacheEntry, bool) {
+	if ttl <= 0 {
+		return discoveryCacheEntry{}, false
+	}
+	cache, err := loadDiscoveryCache(path)
+	if err != nil {
+		return discoveryCacheEntry{}, false
+	}
+	entry, ok := cache.Services[serviceKey(service)]
+	if !ok || len(entry.Endpoints) == 0 {
+		return discoveryCacheEntry{}, false
+	}
+	if source != "" && sourceWithoutHost(entry.Source) != sourceWithoutHost(source) {
+		return discoveryCacheEntry{}, false
+	}
+	if now.Sub(entry.FetchedAt) > ttl {
+		return discoveryCacheEntry{}, false
+	}
+	return entry, true
+}
+
+// latestDiscoveryAnswer returns the most recently stored cache entry.
+func latestDiscoveryAnswer(path string) (discoveryCacheEntry, bool) {
+	cache, err := loadDiscoveryCache(path)
+	if err != nil {
+		return discoveryCacheEntry{}, false
+	}
+	var latest discoveryCacheEntry
+	for _, entry := range cache.Services {
+		if entry.FetchedAt.After(latest.FetchedAt) {
+			latest = entry
+		}
+	}
+	return latest, !latest.FetchedAt.IsZero()
+}
+
+// sourceWithoutHost drops the host from URL sources so that endpoints cached
+// from one discovery host remain valid when another host is tried.
+func sourceWithoutHost(source string) string {
+	u, err := url.Parse(source)
+	if err != nil || u.Host == "" {
+		return source
+	}
+	u.Host = ""
+	return u.String()
+}
+
+// chooseReachableEndpoint applies choose to the endpoints that still accept
+// TCP connections.
+func chooseReachableEndpoint(endpoints []Endpoint, choose func([]Endpoint) (Endpoint, error)) (Endpoint, error) {
+	reachable := make([]Endpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		if ep.Address == "" {
+			continue
+		}
+		if CheckTCPConnectivity(ep.Address, defaultConnectivityTimeout) == nil {
+			reachable = append(reachable, ep)
+		}
+	}
+	if len(reachable) == 0 {
+		return Endpoint{}, fmt.Errorf("no cached endpoint is reachable")
+	}
+	return choose(reachable)
+}
+
+// discoveryCacheTTL parses the configured TTL; `0` or `off` disables the
+// offline fallback.
+func discoveryCacheTTL(cfg config.DBConfig) (time.Duration, error) {
+	val := strings.ToLower(strings.TrimSpace(cfg.DiscoveryCacheTTL))
+	switch val {
+	case "":
+		return defaultDiscoveryCacheTTL, nil
+	case "0", "off":
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(val)
+	if err != nil || ttl < 0 {
+		return 0, fmt.Errorf("invalid discovery_cache_ttl %q", cfg.DiscoveryCacheTTL)
+	}
+	return ttl, nil
+}
+
+func discoveryCacheTTLLabel(cfg config.DBConfig) string {
+	ttl, err := discoveryCacheTTL(cfg)
+	if err != nil {
+		return err.Error()
+	}
+	if ttl == 0 {
+		return "off"
+	}
+	return ttl.String()
+}
+
+func writeFileAtomic(path string, b []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
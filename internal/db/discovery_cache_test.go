@@ -0,0 +1,151 @@
+package db
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"wslbridge/internal/config"
+	appruntime "wslbridge/internal/runtime"
+)
+
+// TestLookupDiscoveryCache verifies TTL and source checks of cached entries.
+func TestLookupDiscoveryCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	fetchedAt := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	entry := discoveryCacheEntry{
+		Service:   "Example-DB",
+		Source:    "http://sd/endpoints?service=example-db",
+		FetchedAt: fetchedAt,
+		Endpoints: []Endpoint{{Address: "10.0.0.1:6432"}},
+	}
+	if err := storeDiscoveryCache(path, entry); err != nil {
+		t.Fatalf("storeDiscoveryCache error: %v", err)
+	}
+
+	if _, ok := lookupDiscoveryCache(path, "example-db", entry.Source, time.Hour, fetchedAt.Add(30*time.Minute)); !ok {
+		t.Fatalf("lookupDiscoveryCache expected fresh entry")
+	}
+	if _, ok := lookupDiscoveryCache(path, "example-db", entry.Source, time.Hour, fetchedAt.Add(2*time.Hour)); ok {
+		t.Fatalf("lookupDiscoveryCache expected expired entry to be ignored")
+	}
+	if _, ok := lookupDiscoveryCache(path, "example-db", "http://other/endpoints", time.Hour, fetchedAt); ok {
+		t.Fatalf("lookupDiscoveryCache expected entry from another source to be ignored")
+	}
+	if _, ok := lookupDiscoveryCache(path, "example-db", entry.Source, 0, fetchedAt); ok {
+		t.Fatalf("lookupDiscoveryCache expected disabled cache to be ignored")
+	}
+}
+
+// TestResolveEndpoint_CacheFallback verifies the stale cache fallback when
+// service discovery goes away.
+func TestResolveEndpoint_CacheFallback(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen error: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	discovery, _ := startServiceDiscoveryStub(t, map[string]string{"example-db": ln.Addr().String()})
+	var cfg config.Config
+	cfg.DB.ServiceDiscoveryScheme = "http"
+	cfg.DB.ServiceDiscoveryHost = strings.TrimPrefix(discovery.URL, "http://")
+	cfg.DB.EndpointMask = defaultEndpointMask
+	cfg.DB.PreferRole = "master"
+	cfg.DB.DiscoveryBackoff = "1ms"
+
+	svc := NewService(appruntime.Runtime{Paths: appruntime.Paths{StateDir: t.TempDir()}})
+	if _, ep, err := svc.resolveEndpoint(cfg, "example-db"); err != nil || ep.Address != ln.Addr().String() {
+		t.Fatalf("resolveEndpoint() = %+v, %v", ep, err)
+	}
+
+	discovery.Close()
+	source, ep, err := svc.resolveEndpoint(cfg, "example-db")
+	if err != nil {
+		t.Fatalf("resolveEndpoint() with discovery down error: %v", err)
+	}
+	if ep.Address != ln.Addr().String() || !strings.HasPrefix(source, "cache:") {
+		t.Fatalf("resolveEndpoint() = %q, %+v", source, ep)
+	}
+
+	cfg.DB.DiscoveryCacheTTL = "off"
+	if _, _, err := svc.resolveEndpoint(cfg, "example-db"); err == nil {
+		t.Fatalf("resolveEndpoint() expected error with cache disabled")
+	}
+}
+
+// TestResolveEndpoint_NoCacheFallbackOnNotFound verifies that a service
+// discovery answer such as 404 is returned instead of stale endpoints.
+func TestResolveEndpoint_NoCacheFallbackOnNotFound(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen error: %v", err)
+	}
+	defer ln.Close()
+
+	targets := map[string]string{"example-db": ln.Addr().String()}
+	discovery, _ := startServiceDiscoveryStub(t, targets)
+	defer discovery.Close()
+	var cfg config.Config
+	cfg.DB.ServiceDiscoveryScheme = "http"
+	cfg.DB.ServiceDiscoveryHost = strings.TrimPrefix(discovery.URL, "http://")
+	cfg.DB.EndpointMask = defaultEndpointMask
+	cfg.DB.PreferRole = "master"
+	cfg.DB.DiscoveryBackoff = "1ms"
+
+	svc := NewService(appruntime.Runtime{Paths: appruntime.Paths{StateDir: t.TempDir()}})
+	if _, _, err := svc.resolveEndpoint(cfg, "example-db"); err != nil {
+		t.Fatalf("resolveEndpoint() error: %v", err)
+	}
+
+	delete(targets, "example-db")
+	_, _, err = svc.resolveEndpoint(cfg, "example-db")
+	var statusErr *discoveryStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("resolveEndpoint() error = %v, want status 404 without cache fallback", err)
+	}
+}
+
+// TestResolveEndpoint_NoCacheFallbackOnTLSError verifies that a discovery
+// host whose certificate is no longer trusted is an error, not an outage
+// served from the cache.
+func TestResolveEndpoint_NoCacheFallbackOnTLSError(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(authTestEndpoints))
+	}))
+	defer srv.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	writePEM(t, caFile, "CERTIFICATE", srv.Certificate().Raw)
+
+	var cfg config.Config
+	cfg.DB.ServiceDiscoveryScheme = "https"
+	cfg.DB.ServiceDiscoveryHost = strings.TrimPrefix(srv.URL, "https://")
+	cfg.DB.EndpointMask = defaultEndpointMask
+	cfg.DB.PreferRole = "master"
+	cfg.DB.DiscoveryBackoff = "1ms"
+	cfg.DB.DiscoveryAuth.CAFile = caFile
+
+	svc := NewService(appruntime.Runtime{Paths: appruntime.Paths{StateDir: t.TempDir()}})
+	if _, _, err := svc.resolveEndpoint(cfg, "example-db"); err != nil {
+		t.Fatalf("resolveEndpoint() error: %v", err)
+	}
+
+	cfg.DB.DiscoveryAuth.CAFile = ""
+	if source, ep, err := svc.resolveEndpoint(cfg, "example-db"); err == nil {
+		t.Fatalf("resolveEndpoint() = %q, %+v; want the TLS error without cache fallback", source, ep)
+	}
+}
@@ -0,0 +1,331 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"wslbridge/internal/config"
+)
+
+// maxCatalogChoices limits the catalog entries offered by `db add`.
+const maxCatalogChoices = 50
+
+// errNoCatalog reports that the configured provider cannot list services.
+var errNoCatalog = errors.New("service discovery catalog is not available")
+
+// CatalogService is a service listed by a Service discovery catalog.
+type CatalogService struct {
+	Name      string
+	Roles     []string
+	Instances []string
+}
+
+// DiscoveryCatalog is implemented by providers that can list the services
+// they know about.
+type DiscoveryCatalog interface {
+	// Catalog returns the known services and a description of where they
+	// were listed.
+	Catalog() (string, []CatalogService, error)
+}
+
+// Catalog lists services from the configured catalog mask. The response is
+// either a list of names, a list of objects with a name and optional
+// endpoints, or an object mapping names to []Endpoint.
+func (p httpProvider) Catalog() (string, []CatalogService, error) {
+	if strings.TrimSpace(p.catalogMask) == "" {
+		return "", nil, fmt.Errorf("%w: catalog_mask is not configured for the http provider", errNoCatalog)
+	}
+	mask, err := NormalizeCatalogMask(p.catalogMask)
+	if err != nil {
+		return "", nil, err
+	}
+	scheme := strings.ToLower(strings.TrimSpace(p.scheme))
+	if scheme == "" {
+		scheme = defaultServiceDiscoveryScheme
+	}
+
+	var catalogURL string
+	var doc any
+	err = p.retry.do(func(host string) error {
+		catalogURL = fmt.Sprintf("%s://%s%s", scheme, host, mask)
+		doc = nil
+		return p.client.getJSON(catalogURL, &doc)
+	})
+	if err != nil {
+		return catalogURL, nil, err
+	}
+	services, err := parseCatalogDocument(doc)
+	if err != nil {
+		return catalogURL, nil, err
+	}
+	return catalogURL, services, nil
+}
+
+// Catalog lists services registered in Consul. Names that do not match the
+// service name mask are skipped; roles come from service tags and from the
+// passing instances, which are listed through the health API.
+func (p consulProvider) Catalog() (string, []CatalogService, error) {
+	scheme := strings.ToLower(strings.TrimSpace(p.scheme))
+	if scheme == "" {
+		scheme = defaultServiceDiscoveryScheme
+	}
+
+	var catalogURL string
+	var entries map[string][]string
+	err := p.retry.do(func(host string) error {
+		catalogURL = fmt.Sprintf("%s://%s/v1/catalog/services", scheme, host)
+		entries = nil
+		return p.client.getJSON(catalogURL, &entries)
+	})
+	if err != nil {
+		return catalogURL, nil, err
+	}
+
+	services := make([]CatalogService, 0, len(entries))
+	for name, tags := range entries {
+		service, ok := matchServiceNameMask(p.mask, name)
+		if !ok {
+			continue
+		}
+		var roles []string
+		for _, tag := range tags {
+			t := strings.ToLower(strings.TrimSpace(tag))
+			switch {
+			case strings.HasPrefix(t, "role="):
+				roles = append(roles, strings.TrimPrefix(t, "role="))
+			case t == "master" || t == "sync" || t == "async":
+				roles = append(roles, t)
+			}
+		}
+		_, endpoints, err := p.passingEndpoints(name)
+		if err != nil {
+			return catalogURL, nil, err
+		}
+		svc := catalogServiceFromEndpoints(service, endpoints)
+		svc.Roles = uniqueSorted(append(roles, svc.Roles...))
+		services = append(services, svc)
+	}
+	return catalogURL, services, nil
+}
+
+// Catalog lists every service in the static endpoints file.
+func (p fileProvider) Catalog() (string, []CatalogService, error) {
+	path := strings.TrimSpace(p.path)
+	if path == "" {
+		return "", nil, fmt.Errorf("static endpoints file is not configured")
+	}
+	source := "file://" + path
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return source, nil, fmt.Errorf("read static endpoints file: %w", err)
+	}
+	var entries map[string][]Endpoint
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return source, nil, fmt.Errorf("decode static endpoints file: %w", err)
+	}
+	services := make([]CatalogService, 0, len(entries))
+	for name, endpoints := range entries {
+		services = append(services, catalogServiceFromEndpoints(name, endpoints))
+	}
+	return source, services, nil
+}
+
+// remoteCatalog lists the services of the configured provider whose names
+// contain filter.
+func remoteCatalog(cfg config.DBConfig, filter string) (string, []CatalogService, error) {
+	provider, err := NewDiscoveryProvider(cfg)
+	if err != nil {
+		return "", nil, err
+	}
+	catalog, ok := provider.(DiscoveryCatalog)
+	if !ok {
+		return "", nil, fmt.Errorf("%w: the %s provider has no service listing", errNoCatalog, discoveryProviderName(cfg))
+	}
+	source, services, err := catalog.Catalog()
+	if err != nil {
+		return source, nil, err
+	}
+	return source, filterCatalog(services, filter), nil
+}
+
+func parseCatalogDocument(doc any) ([]CatalogService, error) {
+	var services []CatalogService
+	switch v := doc.(type) {
+	case []any:
+		for _, item := range v {
+			switch entry := item.(type) {
+			case string:
+				services = append(services, CatalogService{Name: entry})
+			case map[string]any:
+				name := firstJSONString(entry, "Name", "name", "Service", "service")
+				if name == "" {
+					return nil, fmt.Errorf("decode Service discovery catalog: entry without a name")
+				}
+				services = append(services, catalogServiceFromEndpoints(name, decodeCatalogEndpoints(firstJSONValue(entry, "Endpoints", "endpoints"))))
+			default:
+				return nil, fmt.Errorf("decode Service discovery catalog: unexpected entry %T", item)
+			}
+		}
+	case map[string]any:
+		for name, val := range v {
+			services = append(services, catalogServiceFromEndpoints(name, decodeCatalogEndpoints(val)))
+		}
+	default:
+		return nil, fmt.Errorf("decode Service discovery catalog: expected a JSON array or object")
+	}
+	return services, nil
+}
+
+// decodeCatalogEndpoints reads val as []Endpoint; anything else, e.g. a tag
+// list, yields no endpoints.
+func decodeCatalogEndpoints(val any) []Endpoint {
+	if val == nil {
+		return nil
+	}
+	b, err := json.Marshal(val)
+	if err != nil {
+		return nil
+	}
+	var endpoints []Endpoint
+	if err := json.Unmarshal(b, &endpoints); err != nil {
+		return nil
+	}
+	return endpoints
+}
+
+func firstJSONValue(obj map[string]any, keys ...string) any {
+	for _, k := range keys {
+		if v, ok := obj[k]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+func firstJSONString(obj map[string]any, keys ...string) string {
+	s, _ := firstJSONValue(obj, keys...).(string)
+	return strings.TrimSpace(s)
+}
+
+func catalogServiceFromEndpoints(name string, endpoints []Endpoint) CatalogService {
+	var roles, instances []string
+	for _, ep := range endpoints {
+		if ep.Role != "" {
+			roles = append(roles, strings.ToLower(ep.Role))
+		}
+		switch {
+		case ep.InstanceName != "":
+			instances = append(instances, ep.InstanceName)
+		case ep.Address != "":
+			instances = append(instances, ep.Address)
+		}
+	}
+	return CatalogService{Name: name, Roles: uniqueSorted(roles), Instances: uniqueSorted(instances)}
+}
+
+// filterCatalog keeps services whose name contains substr (case-insensitive)
+// and sorts them by name.
+func filterCatalog(services []CatalogService, substr string) []CatalogService {
+	needle := strings.ToLower(strings.TrimSpace(substr))
+	out := make([]CatalogService, 0, len(services))
+	for _, svc := range services {
+		if needle == "" || strings.Contains(strings.ToLower(svc.Name), needle) {
+			out = append(out, svc)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return serviceKey(out[i].Name) < serviceKey(out[j].Name) })
+	return out
+}
+
+func catalogServiceLabel(svc CatalogService) string {
+	roles := "-"
+	if len(svc.Roles) > 0 {
+		roles = strings.Join(svc.Roles, ",")
+	}
+	instances := "-"
+	if len(svc.Instances) > 0 {
+		instances = strings.Join(svc.Instances, ",")
+	}
+	return fmt.Sprintf("%s (roles: %s; instances: %s)", svc.Name, roles, instances)
+}
+
+// pickCatalogService resolves a `db add` answer, either a list number or a
+// service name, to a service name.
+func pickCatalogService(services []CatalogService, answer string) (string, error) {
+	val := strings.TrimSpace(answer)
+	if n, err := strconv.Atoi(val); err == nil {
+		if n < 1 || n > len(services) {
+			return "", fmt.Errorf("must be between 1 and %d", len(services))
+		}
+		return services[n-1].Name, nil
+	}
+	if err := validateServiceName(val); err != nil {
+		return "", err
+	}
+	return val, nil
+}
+
+// matchServiceNameMask is the inverse of RenderServiceNameMask: it extracts
+// the service name from a rendered name.
+func matchServiceNameMask(mask, name string) (string, bool) {
+	m := strings.TrimSpace(mask)
+	var prefix, suffix string
+	if i := strings.Index(m, "%s"); i >= 0 {
+		prefix, suffix = m[:i], m[i+2:]
+	} else if loc := maskPlaceholderRE.FindStringIndex(m); loc != nil {
+		prefix, suffix = m[:loc[0]], m[loc[1]:]
+	} else {
+		return "", false
+	}
+	if len(name) <= len(prefix)+len(suffix) || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+		return "", false
+	}
+	return name[len(prefix) : len(name)-len(suffix)], true
+}
+
+// NormalizeCatalogMask validates a catalog mask and normalizes it to request
+// URI form. Unlike endpoint masks, it has no service placeholder.
+func NormalizeCatalogMask(mask string) (string, error) {
+	val := strings.TrimSpace(mask)
+	if val == "" {
+		return "", fmt.Errorf("catalog mask must not be empty")
+	}
+	lower := strings.ToLower(val)
+	if strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") {
+		u, err := url.Parse(val)
+		if err != nil {
+			return "", fmt.Errorf("invalid catalog mask URL: %w", err)
+		}
+		val = u.RequestURI()
+	}
+	if !strings.HasPrefix(val, "/") {
+		val = "/" + val
+	}
+	if strings.ContainsAny(val, " \t\r\n") {
+		return "", fmt.Errorf("catalog mask must not contain spaces")
+	}
+	return val, nil
+}
+
+func uniqueSorted(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	seen := make(map[string]struct{}, len(values))
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		out = append(out, v)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,181 @@
+package db
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"wslbridge/internal/config"
+)
+
+// TestHTTPProviderCatalog verifies the supported catalog response schemas.
+func TestHTTPProviderCatalog(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []CatalogService
+	}{
+		{
+			name: "names",
+			body: `["example-db","analytics-db"]`,
+			want: []CatalogService{{Name: "analytics-db"}, {Name: "example-db"}},
+		},
+		{
+			name: "objects",
+			body: `[{"Name":"example-db","Endpoints":[{"InstanceName":"db-a","Role":"Master"},{"InstanceName":"db-b","Role":"sync"}]}]`,
+			want: []CatalogService{{Name: "example-db", Roles: []string{"master", "sync"}, Instances: []string{"db-a", "db-b"}}},
+		},
+		{
+			name: "map",
+			body: `{"example-db":[{"Address":"10.0.0.1:6432","Role":"async"}],"analytics-db":["tag"]}`,
+			want: []CatalogService{{Name: "analytics-db"}, {Name: "example-db", Roles: []string{"async"}, Instances: []string{"10.0.0.1:6432"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPath string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.URL.Path
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			cfg := config.DBConfig{
+				ServiceDiscoveryScheme: "http",
+				ServiceDiscoveryHost:   strings.TrimPrefix(srv.URL, "http://"),
+				EndpointMask:           defaultEndpointMask,
+				CatalogMask:            "services",
+			}
+			_, services, err := remoteCatalog(cfg, "")
+			if err != nil {
+				t.Fatalf("remoteCatalog error: %v", err)
+			}
+			if gotPath != "/services" {
+				t.Fatalf("catalog request path = %q", gotPath)
+			}
+			if !reflect.DeepEqual(services, tt.want) {
+				t.Fatalf("remoteCatalog = %+v, want %+v", services, tt.want)
+			}
+		})
+	}
+}
+
+// TestRemoteCatalog_NotAvailable verifies providers without a catalog.
+func TestRemoteCatalog_NotAvailable(t *testing.T) {
+	for _, cfg := range []config.DBConfig{
+		{ServiceDiscoveryHost: "sd.local", EndpointMask: defaultEndpointMask},
+		{DiscoveryProvider: DiscoveryProviderDNS, ServiceDiscoveryHost: "127.0.0.1:53", DiscoveryServiceMask: defaultDNSServiceMask},
+	} {
+		if _, _, err := remoteCatalog(cfg, ""); !errors.Is(err, errNoCatalog) {
+			t.Fatalf("remoteCatalog(%s) error = %v, want errNoCatalog", discoveryProviderName(cfg), err)
+		}
+	}
+}
+
+// TestConsulProviderCatalog verifies service listing via the Consul catalog
+// and that instances come from the health API.
+func TestConsulProviderCatalog(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/catalog/services":
+			_, _ = w.Write([]byte(`{"consul":[],"example-db-pg":["role=master","sync","v16"],"analytics-db-pg":[]}`))
+		case "/v1/health/service/example-db-pg":
+			if r.URL.Query().Get("passing") != "true" {
+				t.Errorf("health query = %q, want passing=true", r.URL.RawQuery)
+			}
+			_, _ = w.Write([]byte(`[
+				{"Node":{"Node":"node-a","Address":"10.0.0.1"},"Service":{"ID":"db-a","Port":6432,"Tags":["master"]}},
+				{"Node":{"Node":"node-b","Address":"10.0.0.2"},"Service":{"ID":"db-b","Port":6432,"Meta":{"role":"async"}}}
+			]`))
+		case "/v1/health/service/analytics-db-pg":
+			_, _ = w.Write([]byte(`[]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	p := consulProvider{scheme: "http", retry: discoveryRetry{hosts: []string{strings.TrimPrefix(srv.URL, "http://")}}, mask: "<db>-pg"}
+	_, services, err := p.Catalog()
+	if err != nil {
+		t.Fatalf("Catalog error: %v", err)
+	}
+	want := []CatalogService{
+		{Name: "analytics-db"},
+		{Name: "example-db", Roles: []string{"async", "master", "sync"}, Instances: []string{"db-a", "db-b"}},
+	}
+	if got := filterCatalog(services, ""); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Catalog = %+v, want %+v", got, want)
+	}
+}
+
+// TestFileProviderCatalog verifies service listing from the static endpoints file.
+func TestFileProviderCatalog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "endpoints.json")
+	body := `{"example-db":[{"InstanceName":"db-a","Address":"10.0.0.1:6432","Role":"master"}],"analytics-db":[]}`
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+	_, services, err := remoteCatalog(config.DBConfig{DiscoveryProvider: DiscoveryProviderFile, DiscoveryFile: path}, "EXAMPLE")
+	if err != nil {
+		t.Fatalf("remoteCatalog error: %v", err)
+	}
+	want := []CatalogService{{Name: "example-db", Roles: []string{"master"}, Instances: []string{"db-a"}}}
+	if !reflect.DeepEqual(services, want) {
+		t.Fatalf("remoteCatalog = %+v, want %+v", services, want)
+	}
+}
+
+func TestPickCatalogService(t *testing.T) {
+	services := []CatalogService{{Name: "analytics-db"}, {Name: "example-db"}}
+	tests := []struct {
+		answer  string
+		want    string
+		wantErr bool
+	}{
+		{answer: "2", want: "example-db"},
+		{answer: " 1 ", want: "analytics-db"},
+		{answer: "other-db", want: "other-db"},
+		{answer: "3", wantErr: true},
+		{answer: "0", wantErr: true},
+		{answer: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := pickCatalogService(services, tt.answer)
+		if tt.wantErr {
+			if err == nil {
+				t.Fatalf("pickCatalogService(%q) expected error", tt.answer)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Fatalf("pickCatalogService(%q) = %q, %v; want %q", tt.answer, got, err, tt.want)
+		}
+	}
+}
+
+func TestMatchServiceNameMask(t *testing.T) {
+	tests := []struct {
+		mask   string
+		name   string
+		want   string
+		wantOK bool
+	}{
+		{mask: "<db>", name: "example-db", want: "example-db", wantOK: true},
+		{mask: "<db>-pg", name: "example-db-pg", want: "example-db", wantOK: true},
+		{mask: "pg-%s", name: "pg-example-db", want: "example-db", wantOK: true},
+		{mask: "<db>-pg", name: "consul"},
+		{mask: "<db>-pg", name: "-pg"},
+	}
+	for _, tt := range tests {
+		got, ok := matchServiceNameMask(tt.mask, tt.name)
+		if ok != tt.wantOK || got != tt.want {
+			t.Fatalf("matchServiceNameMask(%q, %q) = %q, %v", tt.mask, tt.name, got, ok)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// consulProvider resolves services through the Consul health API, returning
+// only instances whose checks are passing.
+type consulProvider struct {
+	client discoveryClient
+	retry  discoveryRetry
+	scheme string
+	mask   string
+}
+
+type consulHealthEntry struct {
+	Node struct {
+		Node    string `json:"Node"`
+		Address string `json:"Address"`
+	} `json:"Node"`
+	Service struct {
+		ID      string            `json:"ID"`
+		Service string            `json:"Service"`
+		Tags    []string          `json:"Tags"`
+		Address string            `json:"Address"`
+		Port    int               `json:"Port"`
+		Meta    map[string]string `json:"Meta"`
+		Weights struct {
+			Passing int `json:"Passing"`
+		} `json:"Weights"`
+	} `json:"Service"`
+}
+
+func (p consulProvider) Resolve(service string) (string, []Endpoint, error) {
+	name, err := RenderServiceNameMask(p.mask, service)
+	if err != nil {
+		return "", nil, err
+	}
+	healthURL, endpoints, err := p.passingEndpoints(name)
+	if err != nil {
+		return healthURL, nil, err
+	}
+	if len(endpoints) == 0 {
+		return healthURL, nil, fmt.Errorf("Consul has no passing instances of %q", name)
+	}
+	return healthURL, endpoints, nil
+}
+
+// passingEndpoints lists the instances of the Consul service name whose
+// checks are passing.
+func (p consulProvider) passingEndpoints(name string) (string, []Endpoint, error) {
+	scheme := strings.ToLower(strings.TrimSpace(p.scheme))
+	if scheme == "" {
+		scheme = defaultServiceDiscoveryScheme
+	}
+
+	var healthURL string
+	var entries []consulHealthEntry
+	err := p.retry.do(func(host string) error {
+		healthURL = fmt.Sprintf("%s://%s/v1/health/service/%s?passing=true", scheme, host, url.PathEscape(name))
+		entries = nil
+		return p.client.getJSON(healthURL, &entries)
+	})
+	if err != nil {
+		return healthURL, nil, err
+	}
+	endpoints := make([]Endpoint, 0, len(entries))
+	for _, e := range entries {
+		endpoints = append(endpoints, e.endpoint())
+	}
+	return healthURL, endpoints, nil
+}
+
+// endpoint maps a Consul health entry to an Endpoint. The role comes from the
+// `role` meta key or a `role=<role>` / bare role tag; `default_route` meta or
+// a `default` tag marks the default route.
+func (e consulHealthEntry) endpoint() Endpoint {
+	addr := e.Service.Address
+	if addr == "" {
+		addr = e.Node.Address
+	}
+	instance := e.Service.ID
+	if instance == "" {
+		instance = e.Node.Node
+	}
+	ep := Endpoint{
+		ReleaseName:  e.Service.Meta["release"],
+		InstanceName: instance,
+		Version:      e.Service.Meta["version"],
+		Role:         e.Service.Meta["role"],
+		Weight:       e.Service.Weights.Passing,
+	}
+	if addr != "" && e.Service.Port > 0 {
+		ep.Address = net.JoinHostPort(addr, strconv.Itoa(e.Service.Port))
+	}
+	ep.IsDefaultRoute, _ = strconv.ParseBool(e.Service.Meta["default_route"])
+	for _, tag := range e.Service.Tags {
+		t := strings.ToLower(strings.TrimSpace(tag))
+		switch {
+		case t == "default":
+			ep.IsDefaultRoute = true
+		case ep.Role == "" && strings.HasPrefix(t, "role="):
+			ep.Role = strings.TrimPrefix(t, "role=")
+		case ep.Role == "" && (t == "master" || t == "sync" || t == "async"):
+			ep.Role = t
+		}
+	}
+	return ep
+}
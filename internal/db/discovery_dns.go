@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const dnsLookupTimeout = 5 * time.Second
+
+// dnsProvider resolves services through DNS SRV records. SRV records carry no
+// role, so records with the lowest priority are marked as default routes and
+// ChooseEndpoint falls back to them. An empty server uses the system resolver.
+type dnsProvider struct {
+	server string
+	mask   string
+}
+
+func (p dnsProvider) Resolve(service string) (string, []Endpoint, error) {
+	name, err := RenderServiceNameMask(p.mask, service)
+	if err != nil {
+		return "", nil, err
+	}
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	source := "dns:" + strings.TrimSuffix(name, ".")
+	if server := strings.TrimSpace(p.server); server != "" {
+		source += "@" + server
+	}
+
+	resolver := p.resolver()
+	ctx, cancel := context.WithTimeout(context.Background(), dnsLookupTimeout)
+	defer cancel()
+
+	_, records, err := resolver.LookupSRV(ctx, "", "", name)
+	if err != nil {
+		return source, nil, fmt.Errorf("lookup SRV %s: %w", name, err)
+	}
+	if len(records) == 0 {
+		return source, nil, fmt.Errorf("no SRV records for %s", name)
+	}
+
+	minPriority := records[0].Priority
+	for _, r := range records {
+		if r.Priority < minPriority {
+			minPriority = r.Priority
+		}
+	}
+	endpoints := make([]Endpoint, 0, len(records))
+	for _, r := range records {
+		target := strings.TrimSuffix(r.Target, ".")
+		host := target
+		if addrs, err := resolver.LookupHost(ctx, r.Target); err == nil && len(addrs) > 0 {
+			host = addrs[0]
+		}
+		endpoints = append(endpoints, Endpoint{
+			InstanceName:   target,
+			Address:        net.JoinHostPort(host, strconv.Itoa(int(r.Port))),
+			Weight:         int(r.Weight),
+			IsDefaultRoute: r.Priority == minPriority,
+		})
+	}
+	return source, endpoints, nil
+}
+
+func (p dnsProvider) resolver() *net.Resolver {
+	server := strings.TrimSpace(p.server)
+	if server == "" {
+		return net.DefaultResolver
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}
+}
+
+func validateDNSServer(s string) error {
+	val := strings.TrimSpace(s)
+	if val == "" || val == "system" {
+		return nil
+	}
+	host := val
+	if h, port, err := net.SplitHostPort(val); err == nil {
+		host = h
+		if _, err := strconv.Atoi(port); err != nil {
+			return fmt.Errorf("port must be integer")
+		}
+	}
+	if net.ParseIP(host) == nil && strings.ContainsAny(host, " \t/") {
+		return fmt.Errorf("must be host[:port] or `system`")
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"wslbridge/internal/config"
+)
+
+// Service discovery providers supported by `db init`.
+const (
+	DiscoveryProviderHTTP   = "http"
+	DiscoveryProviderConsul = "consul"
+	DiscoveryProviderDNS    = "dns"
+	DiscoveryProviderFile   = "file"
+)
+
+const (
+	defaultConsulServiceMask = "<db>"
+	defaultDNSServiceMask    = "_postgresql._tcp.<db>.service.consul"
+)
+
+// DiscoveryProvider resolves a database service name to its endpoints.
+type DiscoveryProvider interface {
+	// Resolve returns the endpoints of service and a description of where
+	// they were looked up.
+	Resolve(service string) (string, []Endpoint, error)
+}
+
+// NewDiscoveryProvider builds the provider selected in cfg.
+func NewDiscoveryProvider(cfg config.DBConfig) (DiscoveryProvider, error) {
+	switch discoveryProviderName(cfg) {
+	case DiscoveryProviderHTTP:
+		if cfg.EndpointMapping != (config.DBEndpointMapping{}) {
+			if err := validateEndpointMapping(cfg.EndpointMapping); err != nil {
+				return nil, err
+			}
+		}
+		client, retry, err := newDiscoveryHTTP(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return httpProvider{client: client, retry: retry, scheme: cfg.ServiceDiscoveryScheme, mask: cfg.EndpointMask, catalogMask: cfg.CatalogMask, mapping: cfg.EndpointMapping}, nil
+	case DiscoveryProviderConsul:
+		client, retry, err := newDiscoveryHTTP(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return consulProvider{client: client, retry: retry, scheme: cfg.ServiceDiscoveryScheme, mask: cfg.DiscoveryServiceMask}, nil
+	case DiscoveryProviderDNS:
+		return dnsProvider{server: cfg.ServiceDiscoveryHost, mask: cfg.DiscoveryServiceMask}, nil
+	case DiscoveryProviderFile:
+		return fileProvider{path: cfg.DiscoveryFile}, nil
+	default:
+		return nil, fmt.Errorf("unknown service discovery provider %q", cfg.DiscoveryProvider)
+	}
+}
+
+func newDiscoveryHTTP(cfg config.DBConfig) (discoveryClient, discoveryRetry, error) {
+	client, err := newDiscoveryClient(cfg.DiscoveryAuth)
+	if err != nil {
+		return discoveryClient{}, discoveryRetry{}, err
+	}
+	retry, err := newDiscoveryRetry(cfg)
+	if err != nil {
+		return discoveryClient{}, discoveryRetry{}, err
+	}
+	return client, retry, nil
+}
+
+func discoveryProviderName(cfg config.DBConfig) string {
+	name := strings.ToLower(strings.TrimSpace(cfg.DiscoveryProvider))
+	if name == "" {
+		return DiscoveryProviderHTTP
+	}
+	return name
+}
+
+func validateDiscoveryProvider(s string) error {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case DiscoveryProviderHTTP, DiscoveryProviderConsul, DiscoveryProviderDNS, DiscoveryProviderFile:
+		return nil
+	default:
+		return fmt.Errorf("must be one of: http, consul, dns, file")
+	}
+}
+
+// httpProvider queries an HTTP endpoint returning the []Endpoint JSON schema,
+// or any other schema described by mapping.
+type httpProvider struct {
+	client      discoveryClient
+	retry       discoveryRetry
+	scheme      string
+	mask        string
+	catalogMask string
+	mapping     config.DBEndpointMapping
+}
+
+func (p httpProvider) Resolve(service string) (string, []Endpoint, error) {
+	var endpointURL string
+	var endpoints []Endpoint
+	err := p.retry.do(func(host string) error {
+		u, err := BuildEndpointURL(p.scheme, host, p.mask, service)
+		if err != nil {
+			return err
+		}
+		endpointURL = u
+		endpoints, err = fetchEndpoints(p.client, u, p.mapping)
+		return err
+	})
+	if err != nil {
+		return endpointURL, nil, err
+	}
+	return endpointURL, endpoints, nil
+}
+
+// fileProvider reads endpoints from a local JSON file mapping service names
+// to the []Endpoint schema, e.g. {"example-db": [{"Address": "10.0.0.1:6432"}]}.
+type fileProvider struct {
+	path string
+}
+
+func (p fileProvider) Resolve(service string) (string, []Endpoint, error) {
+	path := strings.TrimSpace(p.path)
+	if path == "" {
+		return "", nil, fmt.Errorf("static endpoints file is not configured")
+	}
+	source := "file://" + path
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return source, nil, fmt.Errorf("read static endpoints file: %w", err)
+	}
+	var services map[string][]Endpoint
+	if err := json.Unmarshal(b, &services); err != nil {
+		return source, nil, fmt.Errorf("decode static endpoints file: %w", err)
+	}
+	for name, endpoints := range services {
+		if serviceKey(name) != serviceKey(service) {
+			continue
+		}
+		if len(endpoints) == 0 {
+			break
+		}
+		return source, endpoints, nil
+	}
+	return source, nil, fmt.Errorf("static endpoints file has no endpoints for %q", service)
+}
+
+// RenderServiceNameMask injects service name into a Consul service or DNS
+// name mask. Unlike endpoint masks, the value is not URL-escaped.
+func RenderServiceNameMask(mask, serviceName string) (string, error) {
+	m := strings.TrimSpace(mask)
+	service := strings.TrimSpace(serviceName)
+	if m == "" {
+		return "", fmt.Errorf("service name mask must not be empty")
+	}
+	if service == "" {
+		return "", fmt.Errorf("service name must not be empty")
+	}
+	if strings.Contains(m, "%s") {
+		return strings.ReplaceAll(m, "%s", service), nil
+	}
+	loc := maskPlaceholderRE.FindStringIndex(m)
+	if loc == nil {
+		return "", fmt.Errorf("service name mask must contain a placeholder (`%%s` or `<...>`)")
+	}
+	return m[:loc[0]] + service + m[loc[1]:], nil
+}
+
+func validateServiceNameMask(s string) error {
+	val := strings.TrimSpace(s)
+	if val == "" {
+		return fmt.Errorf("must not be empty")
+	}
+	if strings.ContainsAny(val, " \t\r\n/") {
+		return fmt.Errorf("must not contain spaces or slashes")
+	}
+	if !hasMaskPlaceholder(val) {
+		return fmt.Errorf("must contain a placeholder (`%%s` or `<...>`)")
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package db
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"wslbridge/internal/config"
+)
+
+// TestNewDiscoveryProvider verifies provider selection from config.
+func TestNewDiscoveryProvider(t *testing.T) {
+	cases := map[string]any{
+		"":       httpProvider{},
+		"http":   httpProvider{},
+		"Consul": consulProvider{},
+		"dns":    dnsProvider{},
+		"file":   fileProvider{},
+	}
+	for name, want := range cases {
+		got, err := NewDiscoveryProvider(config.DBConfig{DiscoveryProvider: name})
+		if err != nil {
+			t.Fatalf("NewDiscoveryProvider(%q) error: %v", name, err)
+		}
+		if fmt.Sprintf("%T", got) != fmt.Sprintf("%T", want) {
+			t.Fatalf("NewDiscoveryProvider(%q) = %T, want %T", name, got, want)
+		}
+	}
+	if _, err := NewDiscoveryProvider(config.DBConfig{DiscoveryProvider: "etcd"}); err == nil {
+		t.Fatalf("NewDiscoveryProvider expected error for unknown provider")
+	}
+}
+
+// TestConsulProvider verifies mapping of Consul health entries to endpoints.
+func TestConsulProvider(t *testing.T) {
+	var gotPath, gotQuery string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotQuery = r.URL.Path, r.URL.RawQuery
+		_, _ = w.Write([]byte(`[
+			{"Node":{"Node":"node-a","Address":"10.0.0.1"},"Service":{"ID":"db-a","Tags":["role=sync"],"Port":6432,"Weights":{"Passing":2}}},
+			{"Node":{"Node":"node-b","Address":"10.0.0.2"},"Service":{"ID":"db-b","Address":"10.0.1.2","Tags":["default"],"Port":6432,"Meta":{"role":"master","version":"16"}}}
+		]`))
+	}))
+	defer srv.Close()
+
+	p := consulProvider{scheme: "http", retry: discoveryRetry{hosts: []string{strings.TrimPrefix(srv.URL, "http://")}}, mask: "<db>-pg"}
+	source, endpoints, err := p.Resolve("example-db")
+	if err != nil {
+		t.Fatalf("Resolve error: %v", err)
+	}
+	if gotPath != "/v1/health/service/example-db-pg" || gotQuery != "passing=true" {
+		t.Fatalf("Consul request = %s?%s", gotPath, gotQuery)
+	}
+	if !strings.HasSuffix(source, "/v1/health/service/example-db-pg?passing=true") {
+		t.Fatalf("Resolve source = %q", source)
+	}
+	if len(endpoints) != 2 {
+		t.Fatalf("Resolve len=%d, want 2", len(endpoints))
+	}
+	if ep := endpoints[0]; ep.Address != "10.0.0.1:6432" || ep.Role != "sync" || ep.Weight != 2 || ep.IsDefaultRoute {
+		t.Fatalf("endpoint[0] = %+v", ep)
+	}
+	if ep := endpoints[1]; ep.Address != "10.0.1.2:6432" || ep.Role != "master" || !ep.IsDefaultRoute || ep.Version != "16" {
+		t.Fatalf("endpoint[1] = %+v", ep)
+	}
+}
+
+// TestDNSProvider verifies SRV resolution against a local DNS stub.
+func TestDNSProvider(t *testing.T) {
+	server := startDNSStub(t, map[string][]dnsStubRecord{
+		"_postgresql._tcp.example-db.service.consul.": {
+			{srv: &net.SRV{Target: "db-a.node.consul.", Port: 6432, Priority: 1, Weight: 10}},
+			{srv: &net.SRV{Target: "db-b.node.consul.", Port: 6433, Priority: 2, Weight: 5}},
+		},
+		"db-a.node.consul.": {{a: net.IPv4(10, 0, 0, 1)}},
+		"db-b.node.consul.": {{a: net.IPv4(10, 0, 0, 2)}},
+	})
+
+	p := dnsProvider{server: server, mask: defaultDNSServiceMask}
+	_, endpoints, err := p.Resolve("example-db")
+	if err != nil {
+		t.Fatalf("Resolve error: %v", err)
+	}
+	if len(endpoints) != 2 {
+		t.Fatalf("Resolve len=%d, want 2", len(endpoints))
+	}
+	got, err := ChooseEndpoint(endpoints, "master")
+	if err != nil {
+		t.Fatalf("ChooseEndpoint error: %v", err)
+	}
+	if got.Address != "10.0.0.1:6432" || got.InstanceName != "db-a.node.consul" || got.Weight != 10 {
+		t.Fatalf("ChooseEndpoint = %+v", got)
+	}
+}
+
+// TestFileProvider verifies lookup in a static endpoints file.
+func TestFileProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "endpoints.json")
+	content := `{"Example-DB":[{"InstanceName":"db-a","Address":"10.0.0.1:6432","Role":"master","IsDefaultRoute":true}]}`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write endpoints file: %v", err)
+	}
+
+	p := fileProvider{path: path}
+	_, endpoints, err := p.Resolve("example-db")
+	if err != nil {
+		t.Fatalf("Resolve error: %v", err)
+	}
+	if len(endpoints) != 1 || endpoints[0].Address != "10.0.0.1:6432" {
+		t.Fatalf("Resolve = %+v", endpoints)
+	}
+	if _, _, err := p.Resolve("missing-db"); err == nil {
+		t.Fatalf("Resolve expected error for unknown service")
+	}
+}
+
+// TestRenderServiceNameMask verifies placeholder substitution without escaping.
+func TestRenderServiceNameMask(t *testing.T) {
+	got, err := RenderServiceNameMask("_postgresql._tcp.<db>.service.consul", "example-db")
+	if err != nil {
+		t.Fatalf("RenderServiceNameMask error: %v", err)
+	}
+	if got != "_postgresql._tcp.example-db.service.consul" {
+		t.Fatalf("RenderServiceNameMask got %q", got)
+	}
+	if _, err := RenderServiceNameMask("example-db", "example-db"); err == nil {
+		t.Fatalf("RenderServiceNameMask expected error for missing placeholder")
+	}
+}
+
+type dnsStubRecord struct {
+	srv *net.SRV
+	a   net.IP
+}
+
+// startDNSStub serves SRV and A records over UDP and returns its address.
+func startDNSStub(t *testing.T, records map[string][]dnsStubRecord) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen udp: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if resp := dnsStubAnswer(buf[:n], records); resp != nil {
+				_, _ = conn.WriteTo(resp, addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func dnsStubAnswer(query []byte, records map[string][]dnsStubRecord) []byte {
+	if len(query) < 12 {
+		return nil
+	}
+	var labels []string
+	off := 12
+	for off < len(query) && query[off] != 0 {
+		l := int(query[off])
+		if off+1+l > len(query) {
+			return nil
+		}
+		labels = append(labels, string(query[off+1:off+1+l]))
+		off += 1 + l
+	}
+	off++
+	if off+4 > len(query) {
+		return nil
+	}
+	qtype := binary.BigEndian.Uint16(query[off : off+2])
+	question := query[12 : off+4]
+	name := strings.ToLower(strings.Join(labels, ".")) + "."
+
+	var answers [][]byte
+	for _, rec := range records[name] {
+		switch {
+		case qtype == 33 && rec.srv != nil:
+			rdata := make([]byte, 6)
+			binary.BigEndian.PutUint16(rdata[0:2], rec.srv.Priority)
+			binary.BigEndian.PutUint16(rdata[2:4], rec.srv.Weight)
+			binary.BigEndian.PutUint16(rdata[4:6], rec.srv.Port)
+			rdata = append(rdata, dnsStubName(rec.srv.Target)...)
+			answers = append(answers, dnsStubRR(33, rdata))
+		case qtype == 1 && rec.a != nil:
+			answers = append(answers, dnsStubRR(1, rec.a.To4()))
+		}
+	}
+
+	resp := make([]byte, 12, 512)
+	copy(resp[0:2], query[0:2])
+	binary.BigEndian.PutUint16(resp[2:4], 0x8180)
+	binary.BigEndian.PutUint16(resp[4:6], 1)
+	binary.BigEndian.PutUint16(resp[6:8], uint16(len(answers)))
+	resp = append(resp, question...)
+	for _, a := range answers {
+		resp = append(resp, a...)
+	}
+	return resp
+}
+
+func dnsStubRR(rrType uint16, rdata []byte) []byte {
+	rr := []byte{0xC0, 0x0C, 0, 0, 0, 1, 0, 0, 0, 60, 0, 0}
+	binary.BigEndian.PutUint16(rr[2:4], rrType)
+	binary.BigEndian.PutUint16(rr[10:12], uint16(len(rdata)))
+	return append(rr, rdata...)
+}
+
+func dnsStubName(name string) []byte {
+	var out []byte
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		out = append(out, byte(len(label)))
+		out = append(out, label...)
+	}
+	return append(out, 0)
+}
@@ -0,0 +1,195 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"wslbridge/internal/config"
+)
+
+// Discovery host orders supported in config.
+const (
+	DiscoveryHostOrderOrdered = "ordered"
+	DiscoveryHostOrderRandom  = "random"
+)
+
+const (
+	defaultDiscoveryAttempts = 3
+	defaultDiscoveryBackoff  = 500 * time.Millisecond
+	maxDiscoveryBackoff      = 5 * time.Second
+)
+
+// discoveryStatusError is a non-2xx Service discovery response.
+type discoveryStatusError struct {
+	StatusCode int
+}
+
+func (e *discoveryStatusError) Error() string {
+	return fmt.Sprintf("Service discovery returned status %d", e.StatusCode)
+}
+
+// isRetryableDiscoveryError reports whether err is a timeout, a failed or
+// refused connection or a 5xx response, which are worth retrying or sending
+// to another host. TLS verification failures and malformed URLs are not:
+// another attempt gives the same answer, and they must not look like an
+// outage that stale cached endpoints could paper over.
+func isRetryableDiscoveryError(err error) bool {
+	var statusErr *discoveryStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// discoveryRetry runs a request against several discovery hosts with
+// per-host retries and exponential backoff.
+type discoveryRetry struct {
+	hosts    []string
+	order    string
+	attempts int
+	backoff  time.Duration
+	sleep    func(time.Duration)
+}
+
+func newDiscoveryRetry(cfg config.DBConfig) (discoveryRetry, error) {
+	r := discoveryRetry{
+		hosts:    discoveryHosts(cfg),
+		order:    strings.ToLower(strings.TrimSpace(cfg.DiscoveryHostOrder)),
+		attempts: cfg.DiscoveryAttempts,
+		backoff:  defaultDiscoveryBackoff,
+		sleep:    time.Sleep,
+	}
+	if err := validateDiscoveryHostOrder(r.order); err != nil {
+		return discoveryRetry{}, fmt.Errorf("discovery_host_order: %w", err)
+	}
+	if r.attempts <= 0 {
+		r.attempts = defaultDiscoveryAttempts
+	}
+	if val := strings.TrimSpace(cfg.DiscoveryBackoff); val != "" {
+		d, err := time.ParseDuration(val)
+		if err != nil || d < 0 {
+			return discoveryRetry{}, fmt.Errorf("invalid discovery_backoff %q", cfg.DiscoveryBackoff)
+		}
+		r.backoff = d
+	}
+	return r, nil
+}
+
+// do calls fn for each host until one succeeds. Retryable errors are retried
+// on the same host with a doubling delay, then the next host is tried; other
+// errors are returned immediately.
+func (r discoveryRetry) do(fn func(host string) error) error {
+	hosts := append([]string(nil), r.hosts...)
+	if len(hosts) == 0 {
+		return fmt.Errorf("Service discovery host must not be empty")
+	}
+	if r.order == DiscoveryHostOrderRandom {
+		rand.Shuffle(len(hosts), func(i, j int) { hosts[i], hosts[j] = hosts[j], hosts[i] })
+	}
+	attempts := r.attempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+	sleep := r.sleep
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+
+	var lastErr error
+	for _, host := range hosts {
+		delay := r.backoff
+		for attempt := 1; attempt <= attempts; attempt++ {
+			err := fn(host)
+			if err == nil {
+				return nil
+			}
+			if !isRetryableDiscoveryError(err) {
+				return err
+			}
+			lastErr = err
+			if attempt < attempts && delay > 0 {
+				sleep(delay)
+				delay = min(delay*2, maxDiscoveryBackoff)
+			}
+		}
+	}
+	if len(hosts) > 1 {
+		return fmt.Errorf("all %d Service discovery hosts failed: %w", len(hosts), lastErr)
+	}
+	return lastErr
+}
+
+// discoveryHosts returns the primary host followed by fallback hosts.
+func discoveryHosts(cfg config.DBConfig) []string {
+	out := make([]string, 0, 1+len(cfg.ServiceDiscoveryHosts))
+	seen := make(map[string]struct{}, cap(out))
+	for _, h := range append([]string{cfg.ServiceDiscoveryHost}, cfg.ServiceDiscoveryHosts...) {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h == "" {
+			continue
+		}
+		if _, ok := seen[h]; ok {
+			continue
+		}
+		seen[h] = struct{}{}
+		out = append(out, h)
+	}
+	return out
+}
+
+// parseDiscoveryHosts splits a comma-separated host list; `none` clears it.
+func parseDiscoveryHosts(raw string) ([]string, error) {
+	val := strings.TrimSpace(raw)
+	if val == "" || strings.EqualFold(val, "none") {
+		return nil, nil
+	}
+	var hosts []string
+	for _, part := range strings.Split(val, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		_, host, err := NormalizeServiceDiscoveryInput(part)
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
+
+func validateDiscoveryHosts(s string) error {
+	_, err := parseDiscoveryHosts(s)
+	return err
+}
+
+func validateDiscoveryHostOrder(s string) error {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", DiscoveryHostOrderOrdered, DiscoveryHostOrderRandom:
+		return nil
+	default:
+		return fmt.Errorf("must be one of: ordered, random")
+	}
+}
+
+// sourceHost extracts the host that answered from a provider source URL.
+func sourceHost(source string) string {
+	u, err := url.Parse(strings.TrimPrefix(source, "cache:"))
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return u.Host
+}
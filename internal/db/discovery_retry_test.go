@@ -0,0 +1,131 @@
+package db
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"wslbridge/internal/config"
+)
+
+// TestDiscoveryRetry_FailoverWithBackoff verifies retries on 5xx with a
+// doubling delay before moving on to the next host.
+func TestDiscoveryRetry_FailoverWithBackoff(t *testing.T) {
+	var failing atomic.Int32
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failing.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(authTestEndpoints))
+	}))
+	defer up.Close()
+
+	var delays []time.Duration
+	p := httpProvider{
+		scheme: "http",
+		mask:   defaultEndpointMask,
+		retry: discoveryRetry{
+			hosts:    []string{strings.TrimPrefix(down.URL, "http://"), strings.TrimPrefix(up.URL, "http://")},
+			attempts: 3,
+			backoff:  100 * time.Millisecond,
+			sleep:    func(d time.Duration) { delays = append(delays, d) },
+		},
+	}
+	source, endpoints, err := p.Resolve("example-db")
+	if err != nil {
+		t.Fatalf("Resolve error: %v", err)
+	}
+	if len(endpoints) != 1 || sourceHost(source) != strings.TrimPrefix(up.URL, "http://") {
+		t.Fatalf("Resolve = %q, %+v", source, endpoints)
+	}
+	if failing.Load() != 3 {
+		t.Fatalf("failing host hits = %d, want 3", failing.Load())
+	}
+	if want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond}; !reflect.DeepEqual(delays, want) {
+		t.Fatalf("backoff delays = %v, want %v", delays, want)
+	}
+}
+
+// TestDiscoveryRetry_ClientErrorNotRetried verifies that 4xx answers stop the loop.
+func TestDiscoveryRetry_ClientErrorNotRetried(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	r := discoveryRetry{hosts: []string{"a", "b"}, attempts: 3, sleep: func(time.Duration) {}}
+	err := r.do(func(string) error {
+		return (discoveryClient{}).getJSON(srv.URL, new([]Endpoint))
+	})
+	if err == nil || hits.Load() != 1 {
+		t.Fatalf("do() = %v with %d hits, want error after 1 hit", err, hits.Load())
+	}
+}
+
+// TestDiscoveryRetry_NetworkError verifies retries when a host is unreachable.
+func TestDiscoveryRetry_NetworkError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	addr := srv.URL
+	srv.Close()
+
+	calls := 0
+	r := discoveryRetry{hosts: []string{"a"}, attempts: 2, sleep: func(time.Duration) {}}
+	err := r.do(func(string) error {
+		calls++
+		return (discoveryClient{}).getJSON(addr, new([]Endpoint))
+	})
+	if err == nil || calls != 2 {
+		t.Fatalf("do() = %v with %d calls, want error after 2 calls", err, calls)
+	}
+}
+
+// TestNewDiscoveryRetry verifies host list assembly and defaults.
+func TestNewDiscoveryRetry(t *testing.T) {
+	r, err := newDiscoveryRetry(config.DBConfig{
+		ServiceDiscoveryHost:  "sd-1.example.internal",
+		ServiceDiscoveryHosts: []string{"SD-2.example.internal", "sd-1.example.internal"},
+		DiscoveryHostOrder:    "random",
+	})
+	if err != nil {
+		t.Fatalf("newDiscoveryRetry error: %v", err)
+	}
+	if want := []string{"sd-1.example.internal", "sd-2.example.internal"}; !reflect.DeepEqual(r.hosts, want) {
+		t.Fatalf("hosts = %v, want %v", r.hosts, want)
+	}
+	if r.attempts != defaultDiscoveryAttempts || r.backoff != defaultDiscoveryBackoff {
+		t.Fatalf("attempts=%d backoff=%v", r.attempts, r.backoff)
+	}
+	if _, err := newDiscoveryRetry(config.DBConfig{DiscoveryHostOrder: "round-robin"}); err == nil {
+		t.Fatalf("newDiscoveryRetry expected error for unknown order")
+	}
+}
+
+// TestDiscoveryRetry_TLSErrorNotRetried verifies that a certificate the
+// client does not trust fails at once instead of being retried.
+func TestDiscoveryRetry_TLSErrorNotRetried(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(authTestEndpoints))
+	}))
+	defer srv.Close()
+
+	calls := 0
+	r := discoveryRetry{hosts: []string{"a", "b"}, attempts: 3, sleep: func(time.Duration) {}}
+	err := r.do(func(string) error {
+		calls++
+		return (discoveryClient{}).getJSON(srv.URL, new([]Endpoint))
+	})
+	if err == nil || calls != 1 {
+		t.Fatalf("do() = %v with %d calls, want error after 1 call", err, calls)
+	}
+	if discoveryUnreachable(err) {
+		t.Fatalf("TLS error %v must not count as unreachable", err)
+	}
+}
@@ -0,0 +1,134 @@
+package db
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"wslbridge/internal/config"
+	"wslbridge/internal/doctor"
+)
+
+// DoctorChecks diagnoses the db side of the bridge: service discovery
+// reachability, the TCP connectivity of every route target of the active
+// environment and conflicts on the proxy listen port.
+func (s Service) DoctorChecks() []doctor.Result {
+	cfg, _, err := s.loadConfig()
+	if err != nil {
+		return []doctor.Result{doctor.Problem("db config", doctor.Fail, err.Error(),
+			"fix the file or run `wslbridge config edit`")}
+	}
+	s.applyDefaults(&cfg)
+
+	out := []doctor.Result{checkDiscoveryReachable(cfg)}
+	out = append(out, checkRoutes(cfg)...)
+	return append(out, s.checkProxyPort(cfg))
+}
+
+func checkDiscoveryReachable(cfg config.Config) doctor.Result {
+	const name = "service discovery"
+	switch provider := discoveryProviderName(cfg.DB); provider {
+	case DiscoveryProviderFile:
+		if _, err := os.Stat(cfg.DB.DiscoveryFile); err != nil {
+			return doctor.Problem(name, doctor.Fail, err.Error(),
+				"point db.discovery_file at an existing endpoints file")
+		}
+		return doctor.Pass(name, "endpoints file "+cfg.DB.DiscoveryFile)
+	case DiscoveryProviderDNS:
+		server := strings.TrimSpace(cfg.DB.ServiceDiscoveryHost)
+		if server == "" {
+			return doctor.Pass(name, "dns via the system resolver")
+		}
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		if err := CheckTCPConnectivity(server, defaultConnectivityTimeout); err != nil {
+			return doctor.Problem(name, doctor.Fail, err.Error(),
+				"check the DNS server with `wslbridge db init --provider=dns --dns-server=<ip>`")
+		}
+		return doctor.Pass(name, "dns server "+server+" is reachable")
+	default:
+		hosts := discoveryHosts(cfg.DB)
+		if len(hosts) == 0 {
+			return doctor.Problem(name, doctor.Fail, provider+" host is not configured", "run `wslbridge db init`")
+		}
+		port := "80"
+		if cfg.DB.ServiceDiscoveryScheme == "https" {
+			port = "443"
+		}
+		var ok, failed []string
+		for _, host := range hosts {
+			addr := host
+			if _, _, err := net.SplitHostPort(host); err != nil {
+				addr = net.JoinHostPort(host, port)
+			}
+			if err := CheckTCPConnectivity(addr, defaultConnectivityTimeout); err != nil {
+				failed = append(failed, fmt.Sprintf("%s (%v)", host, err))
+				continue
+			}
+			ok = append(ok, host)
+		}
+		hint := "check the " + provider + " host and the SOCKS proxy route to it; fallback hosts are set with `wslbridge db init --fallback-hosts=<h1,h2>`"
+		switch {
+		case len(ok) == 0:
+			return doctor.Problem(name, doctor.Fail, strings.Join(failed, "; "), hint)
+		case len(failed) > 0:
+			return doctor.Problem(name, doctor.Warn, "unreachable: "+strings.Join(failed, "; "), hint)
+		}
+		return doctor.Pass(name, strings.Join(ok, ", ")+" reachable")
+	}
+}
+
+// checkRoutes checks the route targets of the active environment in
+// parallel.
+func checkRoutes(cfg config.Config) []doctor.Result {
+	services := statusServices(cfg, true)
+	if len(services) == 0 {
+		return []doctor.Result{doctor.Problem("db routes", doctor.Skip, "no db services configured", "")}
+	}
+	out := make([]doctor.Result, len(services))
+	var wg sync.WaitGroup
+	for i, svc := range services {
+		name := "db route " + svc.Name
+		if svc.Target == "" {
+			out[i] = doctor.Problem(name, doctor.Warn, "target is not resolved yet", "run `wslbridge db start`")
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := CheckTCPConnectivity(svc.Target, defaultConnectivityTimeout); err != nil {
+				hint := fmt.Sprintf("list live endpoints with `wslbridge db endpoints %s` and re-add it with `wslbridge db add %s`", svc.Name, svc.Name)
+				if pin := servicePinLabel(cfg, svc.Name); pin != "" {
+					hint = fmt.Sprintf("the route is pinned to %s; `wslbridge db unpin %s` lets discovery pick another endpoint", pin, svc.Name)
+				}
+				out[i] = doctor.Problem(name, doctor.Fail, err.Error(), hint)
+				return
+			}
+			out[i] = doctor.Pass(name, svc.Target+" reachable")
+		}()
+	}
+	wg.Wait()
+	return out
+}
+
+func (s Service) checkProxyPort(cfg config.Config) doctor.Result {
+	const name = "db proxy port"
+	addr := net.JoinHostPort(cfg.DB.LocalHost, fmt.Sprint(cfg.DB.LocalPort))
+	if IsProxyRunning(s.rt.Paths.DBProxyPIDFile) {
+		if meta, ok := readProxyMeta(s.rt.Paths.DBProxyMetaFile); ok && meta.ListenAddr != addr {
+			return doctor.Problem(name, doctor.Warn, fmt.Sprintf("proxy listens on %s, config says %s", meta.ListenAddr, addr),
+				"restart it: `wslbridge db stop` and `wslbridge db start`")
+		}
+		return doctor.Pass(name, addr+" is used by the wslbridge db proxy")
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return doctor.Problem(name, doctor.Fail, err.Error(),
+			"stop the process using it (`ss -ltnp`) or pick another port: `wslbridge db start --force --port=<port>`")
+	}
+	ln.Close()
+	return doctor.Pass(name, addr+" is free")
+}
@@ -0,0 +1,50 @@
+package db
+
+import (
+	"net"
+	"testing"
+
+	"wslbridge/internal/config"
+	"wslbridge/internal/doctor"
+)
+
+// TestCheckRoutes verifies a result per service of the active environment.
+func TestCheckRoutes(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	cfg := config.Config{DB: config.DBConfig{
+		ServiceNames: []string{"orders-db", "billing-db", "new-db"},
+		ServiceTargets: map[string]string{
+			"orders-db":  ln.Addr().String(),
+			"billing-db": closedAddr,
+		},
+		PinnedAddresses: map[string]string{"billing-db": closedAddr},
+	}}
+	got := checkRoutes(cfg)
+	want := []doctor.Status{doctor.OK, doctor.Fail, doctor.Warn}
+	if len(got) != len(want) {
+		t.Fatalf("checkRoutes = %+v", got)
+	}
+	for i, r := range got {
+		if r.Status != want[i] {
+			t.Fatalf("%s status = %s, want %s (%+v)", r.Name, r.Status, want[i], r)
+		}
+	}
+	if got[1].Hint == "" || got[1].Name != "db route billing-db" {
+		t.Fatalf("failed route = %+v", got[1])
+	}
+
+	if got := checkRoutes(config.Config{}); len(got) != 1 || got[0].Status != doctor.Skip {
+		t.Fatalf("no services = %+v", got)
+	}
+}
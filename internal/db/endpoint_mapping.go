@@ -0,0 +1,264 @@
+package db
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"wslbridge/internal/config"
+)
+
+// FetchMappedEndpoints gets endpoint data from a Service discovery API with a
+// custom response schema. A zero mapping expects the []Endpoint schema.
+func FetchMappedEndpoints(ServiceDiscoveryURL string, mapping config.DBEndpointMapping) ([]Endpoint, error) {
+	return fetchEndpoints(discoveryClient{}, ServiceDiscoveryURL, mapping)
+}
+
+func fetchEndpoints(client discoveryClient, ServiceDiscoveryURL string, mapping config.DBEndpointMapping) ([]Endpoint, error) {
+	if mapping == (config.DBEndpointMapping{}) {
+		var endpoints []Endpoint
+		if err := client.getJSON(ServiceDiscoveryURL, &endpoints); err != nil {
+			return nil, err
+		}
+		if len(endpoints) == 0 {
+			return nil, fmt.Errorf("Service discovery returned no endpoints")
+		}
+		return endpoints, nil
+	}
+	var doc any
+	if err := client.getJSON(ServiceDiscoveryURL, &doc); err != nil {
+		return nil, err
+	}
+	endpoints, err := MapEndpoints(doc, mapping)
+	if err != nil {
+		return nil, err
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("Service discovery returned no endpoints")
+	}
+	return endpoints, nil
+}
+
+// MapEndpoints converts a decoded JSON document to endpoints using mapping.
+func MapEndpoints(doc any, mapping config.DBEndpointMapping) ([]Endpoint, error) {
+	if err := validateEndpointMapping(mapping); err != nil {
+		return nil, err
+	}
+	list, ok := selectJSON(doc, mapping.List)
+	if !ok {
+		return nil, fmt.Errorf("Service discovery response has no %q", mapping.List)
+	}
+	items, ok := list.([]any)
+	if !ok {
+		return nil, fmt.Errorf("Service discovery response %q is not a list", emptyIf(mapping.List))
+	}
+
+	endpoints := make([]Endpoint, 0, len(items))
+	for _, item := range items {
+		ep := Endpoint{
+			ReleaseName:    jsonString(selectOptional(item, mapping.Release)),
+			InstanceName:   jsonString(selectOptional(item, mapping.Instance)),
+			Version:        jsonString(selectOptional(item, mapping.Version)),
+			Role:           jsonRole(selectOptional(item, mapping.Role)),
+			Weight:         jsonInt(selectOptional(item, mapping.Weight)),
+			IsDefaultRoute: jsonBool(selectOptional(item, mapping.Default)),
+		}
+		if mapping.Address != "" {
+			ep.Address = jsonString(selectOptional(item, mapping.Address))
+		} else {
+			host := jsonString(selectOptional(item, mapping.Host))
+			port := jsonString(selectOptional(item, mapping.Port))
+			if host != "" && port != "" {
+				ep.Address = net.JoinHostPort(host, port)
+			} else {
+				ep.Address = host
+			}
+		}
+		endpoints = append(endpoints, ep)
+	}
+	return endpoints, nil
+}
+
+func validateEndpointMapping(mapping config.DBEndpointMapping) error {
+	if mapping.Address == "" && mapping.Host == "" {
+		return fmt.Errorf("endpoint mapping must set address or host")
+	}
+	for _, sel := range []string{mapping.List, mapping.Address, mapping.Host, mapping.Port, mapping.Instance, mapping.Role, mapping.Weight, mapping.Default, mapping.Release, mapping.Version} {
+		if _, err := parseJSONSelector(sel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// endpointMappingLabel renders the configured selectors for status output.
+func endpointMappingLabel(mapping config.DBEndpointMapping) string {
+	if mapping == (config.DBEndpointMapping{}) {
+		return "(default)"
+	}
+	var parts []string
+	add := func(name, sel string) {
+		if sel != "" {
+			parts = append(parts, name+"="+sel)
+		}
+	}
+	add("list", mapping.List)
+	add("address", mapping.Address)
+	add("host", mapping.Host)
+	add("port", mapping.Port)
+	add("instance", mapping.Instance)
+	add("role", mapping.Role)
+	add("weight", mapping.Weight)
+	add("default_route", mapping.Default)
+	add("release", mapping.Release)
+	add("version", mapping.Version)
+	return strings.Join(parts, " ")
+}
+
+// jsonStep is one selector step: an object key or an array index.
+type jsonStep struct {
+	key   string
+	index int
+}
+
+// parseJSONSelector parses selectors like `$.hosts[*]`, `data.items` or
+// `tags[0]`. `[*]` selects the whole array and is accepted for readability.
+func parseJSONSelector(selector string) ([]jsonStep, error) {
+	sel := strings.TrimSpace(selector)
+	sel = strings.TrimPrefix(sel, "$")
+	sel = strings.TrimPrefix(sel, ".")
+	if sel == "" {
+		return nil, nil
+	}
+
+	var steps []jsonStep
+	for _, segment := range strings.Split(sel, ".") {
+		name := segment
+		rest := ""
+		if i := strings.IndexByte(segment, '['); i >= 0 {
+			name, rest = segment[:i], segment[i:]
+		}
+		if name == "" && rest == "" {
+			return nil, fmt.Errorf("invalid selector %q: empty segment", selector)
+		}
+		if name != "" {
+			steps = append(steps, jsonStep{key: name, index: -1})
+		}
+		for rest != "" {
+			end := strings.IndexByte(rest, ']')
+			if rest[0] != '[' || end < 0 {
+				return nil, fmt.Errorf("invalid selector %q", selector)
+			}
+			idx := rest[1:end]
+			rest = rest[end+1:]
+			if idx == "*" {
+				continue
+			}
+			n, err := strconv.Atoi(idx)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid selector %q: bad index %q", selector, idx)
+			}
+			steps = append(steps, jsonStep{index: n})
+		}
+	}
+	return steps, nil
+}
+
+func selectJSON(v any, selector string) (any, bool) {
+	steps, err := parseJSONSelector(selector)
+	if err != nil {
+		return nil, false
+	}
+	cur := v
+	for _, step := range steps {
+		if step.index >= 0 {
+			arr, ok := cur.([]any)
+			if !ok || step.index >= len(arr) {
+				return nil, false
+			}
+			cur = arr[step.index]
+			continue
+		}
+		obj, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = obj[step.key]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+func selectOptional(v any, selector string) any {
+	if strings.TrimSpace(selector) == "" {
+		return nil
+	}
+	out, _ := selectJSON(v, selector)
+	return out
+}
+
+func jsonString(v any) string {
+	switch t := v.(type) {
+	case string:
+		return strings.TrimSpace(t)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(t)
+	}
+	return ""
+}
+
+func jsonInt(v any) int {
+	switch t := v.(type) {
+	case float64:
+		return int(t)
+	case string:
+		n, _ := strconv.Atoi(strings.TrimSpace(t))
+		return n
+	}
+	return 0
+}
+
+// jsonBool treats true, non-zero numbers, truthy strings and lists holding a
+// `default` tag as set.
+func jsonBool(v any) bool {
+	switch t := v.(type) {
+	case bool:
+		return t
+	case float64:
+		return t != 0
+	case string:
+		b, _ := strconv.ParseBool(strings.TrimSpace(t))
+		return b
+	case []any:
+		for _, item := range t {
+			if strings.EqualFold(jsonString(item), "default") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jsonRole reads a role from a string or from a tag list, where the first
+// `role=<role>` or bare master/sync/async tag wins.
+func jsonRole(v any) string {
+	list, ok := v.([]any)
+	if !ok {
+		return jsonString(v)
+	}
+	for _, item := range list {
+		tag := strings.ToLower(jsonString(item))
+		if strings.HasPrefix(tag, "role=") {
+			return strings.TrimPrefix(tag, "role=")
+		}
+		switch tag {
+		case "master", "sync", "async":
+			return tag
+		}
+	}
+	return ""
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"wslbridge/internal/config"
+)
+
+// TestFetchMappedEndpoints verifies mapping of a custom discovery schema.
+func TestFetchMappedEndpoints(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"hosts":[
+			{"id":"db-a","host":"10.0.0.1","port":6432,"tags":["replica","role=sync"],"weight":"5"},
+			{"id":"db-b","host":"10.0.0.2","port":6432,"tags":["master","default"],"weight":10}
+		]}`))
+	}))
+	defer srv.Close()
+
+	mapping := config.DBEndpointMapping{
+		List:     "$.hosts[*]",
+		Host:     "host",
+		Port:     "port",
+		Instance: "id",
+		Role:     "tags",
+		Weight:   "weight",
+		Default:  "tags",
+	}
+	endpoints, err := FetchMappedEndpoints(srv.URL, mapping)
+	if err != nil {
+		t.Fatalf("FetchMappedEndpoints error: %v", err)
+	}
+	if len(endpoints) != 2 {
+		t.Fatalf("FetchMappedEndpoints len=%d, want 2", len(endpoints))
+	}
+	if ep := endpoints[0]; ep.Address != "10.0.0.1:6432" || ep.Role != "sync" || ep.Weight != 5 || ep.IsDefaultRoute || ep.InstanceName != "db-a" {
+		t.Fatalf("endpoint[0] = %+v", ep)
+	}
+	if ep := endpoints[1]; ep.Address != "10.0.0.2:6432" || ep.Role != "master" || ep.Weight != 10 || !ep.IsDefaultRoute {
+		t.Fatalf("endpoint[1] = %+v", ep)
+	}
+}
+
+// TestMapEndpoints_NestedSelectors verifies nested keys and array indexes.
+func TestMapEndpoints_NestedSelectors(t *testing.T) {
+	doc := map[string]any{
+		"data": map[string]any{
+			"items": []any{
+				map[string]any{
+					"addrs": []any{"10.0.0.7:5432"},
+					"meta":  map[string]any{"role": "async", "primary": true},
+				},
+			},
+		},
+	}
+	mapping := config.DBEndpointMapping{List: "data.items", Address: "addrs[0]", Role: "meta.role", Default: "meta.primary"}
+	endpoints, err := MapEndpoints(doc, mapping)
+	if err != nil {
+		t.Fatalf("MapEndpoints error: %v", err)
+	}
+	if len(endpoints) != 1 || endpoints[0].Address != "10.0.0.7:5432" || endpoints[0].Role != "async" || !endpoints[0].IsDefaultRoute {
+		t.Fatalf("MapEndpoints = %+v", endpoints)
+	}
+
+	if _, err := MapEndpoints(doc, config.DBEndpointMapping{List: "data", Address: "addr"}); err == nil {
+		t.Fatalf("MapEndpoints expected error for non-list selector")
+	}
+}
+
+// TestParseJSONSelector verifies selector validation.
+func TestParseJSONSelector(t *testing.T) {
+	for _, sel := range []string{"", "$", "$.hosts", "hosts[*]", "a.b[0][1]"} {
+		if _, err := parseJSONSelector(sel); err != nil {
+			t.Fatalf("parseJSONSelector(%q) error: %v", sel, err)
+		}
+	}
+	for _, sel := range []string{"a..b", "a[x]", "a[0", "a[-1]"} {
+		if _, err := parseJSONSelector(sel); err == nil {
+			t.Fatalf("parseJSONSelector(%q) expected error", sel)
+		}
+	}
+}
+
+// TestMapEndpoints_ReleaseVersionSelector verifies mapped releases and
+// versions can be used by the --release and --version selectors.
+func TestMapEndpoints_ReleaseVersionSelector(t *testing.T) {
+	doc := map[string]any{
+		"hosts": []any{
+			map[string]any{"addr": "10.0.0.1:5432", "meta": map[string]any{"release": "blue", "pg": "14.9"}},
+			map[string]any{"addr": "10.0.0.2:5432", "meta": map[string]any{"release": "green", "pg": "16.2"}},
+			map[string]any{"addr": "10.0.0.3:5432", "meta": map[string]any{"release": "green", "pg": float64(15)}},
+		},
+	}
+	mapping := config.DBEndpointMapping{List: "hosts", Address: "addr", Release: "meta.release", Version: "meta.pg"}
+	endpoints, err := MapEndpoints(doc, mapping)
+	if err != nil {
+		t.Fatalf("MapEndpoints error: %v", err)
+	}
+	if ep := endpoints[2]; ep.ReleaseName != "green" || ep.Version != "15" {
+		t.Fatalf("endpoint[2] = %+v", ep)
+	}
+
+	got, err := selectEndpoints(endpoints, EndpointSelector{Release: "green", Version: ">=16"})
+	if err != nil {
+		t.Fatalf("selectEndpoints error: %v", err)
+	}
+	if len(got) != 1 || got[0].Address != "10.0.0.2:5432" {
+		t.Fatalf("selectEndpoints = %+v", got)
+	}
+	if _, err := selectEndpoints(endpoints, EndpointSelector{Release: "blue", Version: "15"}); err == nil {
+		t.Fatalf("selectEndpoints expected no match for blue 15")
+	}
+}
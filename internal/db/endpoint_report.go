@@ -0,0 +1,93 @@
+package db
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// endpointReportRoles are the roles shown in the PICKED FOR column of
+// `db endpoints`.
+var endpointReportRoles = []string{"master", "sync", "async", "any"}
+
+// endpointPicks returns, per endpoint index, the roles for which
+// ChooseEndpoint selects that endpoint from candidates, the subset of
+// endpoints left by the service selector.
+func endpointPicks(endpoints, candidates []Endpoint, roles []string) [][]string {
+	picks := make([][]string, len(endpoints))
+	for _, role := range roles {
+		ep, err := ChooseEndpoint(candidates, role)
+		if err != nil {
+			continue
+		}
+		if i := endpointIndex(endpoints, ep); i >= 0 {
+			picks[i] = append(picks[i], role)
+		}
+	}
+	return picks
+}
+
+func endpointIndex(endpoints []Endpoint, ep Endpoint) int {
+	for i, e := range endpoints {
+		if e == ep {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkEndpointsConnectivity runs CheckTCPConnectivity for every endpoint in
+// parallel and returns the results in endpoint order.
+func checkEndpointsConnectivity(endpoints []Endpoint, timeout time.Duration) []error {
+	results := make([]error, len(endpoints))
+	var wg sync.WaitGroup
+	for i, ep := range endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = CheckTCPConnectivity(ep.Address, timeout)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// writeEndpointReport prints endpoints as a table with the ChooseEndpoint
+// pick per role and the TCP check result of each endpoint.
+func writeEndpointReport(w io.Writer, endpoints []Endpoint, picks [][]string, checks []error) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tINSTANCE\tADDRESS\tVERSION\tROLE\tWEIGHT\tDEFAULT\tTCP\tPICKED FOR")
+	for i, ep := range endpoints {
+		tcp := "ok"
+		if checks[i] != nil {
+			tcp = "fail"
+		}
+		picked := "-"
+		if len(picks[i]) > 0 {
+			picked = strings.Join(picks[i], ",")
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			i+1, reportValue(ep.InstanceName), reportValue(ep.Address), reportValue(ep.Version), reportValue(ep.Role),
+			strconv.Itoa(ep.Weight), boolLabel(ep.IsDefaultRoute), tcp, picked)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for i, err := range checks {
+		if err != nil {
+			fmt.Fprintf(w, "%d. %v\n", i+1, err)
+		}
+	}
+	return nil
+}
+
+func reportValue(v string) string {
+	if strings.TrimSpace(v) == "" {
+		return "-"
+	}
+	return v
+}
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEndpointPicks(t *testing.T) {
+	endpoints := []Endpoint{
+		{InstanceName: "db-a", Address: "10.0.0.1:6432", Role: "sync"},
+		{InstanceName: "db-b", Address: "10.0.0.2:6432", Role: "master", IsDefaultRoute: true},
+		{InstanceName: "db-c", Address: "10.0.0.3:6432", Role: "async"},
+	}
+	got := endpointPicks(endpoints, endpoints, endpointReportRoles)
+	want := [][]string{{"sync"}, {"master", "any"}, {"async"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("endpointPicks = %v, want %v", got, want)
+	}
+
+	got = endpointPicks(endpoints, endpoints[:1], endpointReportRoles)
+	want = [][]string{{"master", "sync", "async", "any"}, nil, nil}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("endpointPicks with candidates = %v, want %v", got, want)
+	}
+}
+
+// TestEndpointReport verifies TCP checks and the printed endpoint table.
+func TestEndpointReport(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen error: %v", err)
+	}
+	defer ln.Close()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen error: %v", err)
+	}
+	closedAddr := closed.Addr().String()
+	_ = closed.Close()
+
+	endpoints := []Endpoint{
+		{InstanceName: "db-a", Address: ln.Addr().String(), Role: "master", Version: "16", Weight: 3, IsDefaultRoute: true},
+		{InstanceName: "db-b", Address: closedAddr, Role: "sync"},
+	}
+	checks := checkEndpointsConnectivity(endpoints, time.Second)
+	if checks[0] != nil || checks[1] == nil {
+		t.Fatalf("checkEndpointsConnectivity = %v", checks)
+	}
+
+	var buf bytes.Buffer
+	if err := writeEndpointReport(&buf, endpoints, endpointPicks(endpoints, endpoints, endpointReportRoles), checks); err != nil {
+		t.Fatalf("writeEndpointReport error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("report lines = %d, want 4:\n%s", len(lines), buf.String())
+	}
+	if f := strings.Fields(lines[1]); !reflect.DeepEqual(f, []string{"1", "db-a", ln.Addr().String(), "16", "master", "3", "yes", "ok", "master,async,any"}) {
+		t.Fatalf("report row 1 = %q", lines[1])
+	}
+	if f := strings.Fields(lines[2]); !reflect.DeepEqual(f, []string{"2", "db-b", closedAddr, "-", "sync", "0", "no", "fail", "sync"}) {
+		t.Fatalf("report row 2 = %q", lines[2])
+	}
+	if !strings.HasPrefix(lines[3], "2. tcp connectivity check failed") {
+		t.Fatalf("report error line = %q", lines[3])
+	}
+}
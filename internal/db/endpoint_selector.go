@@ -0,0 +1,209 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"wslbridge/internal/config"
+)
+
+// selectorAny clears a selector in `db add --release=any --version=any`.
+const selectorAny = "any"
+
+// EndpointSelector narrows the endpoints of a service before role selection.
+// Version is a comma-separated list of constraints such as `>=15,<17`.
+type EndpointSelector struct {
+	Release string
+	Version string
+}
+
+// IsZero reports whether the selector matches every endpoint.
+func (sel EndpointSelector) IsZero() bool {
+	return strings.TrimSpace(sel.Release) == "" && strings.TrimSpace(sel.Version) == ""
+}
+
+func (sel EndpointSelector) String() string {
+	var parts []string
+	if sel.Release != "" {
+		parts = append(parts, "release "+sel.Release)
+	}
+	if sel.Version != "" {
+		parts = append(parts, "version "+sel.Version)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// serviceSelector returns the selector persisted for service.
+func serviceSelector(cfg config.Config, service string) EndpointSelector {
+	return EndpointSelector{
+		Release: getServiceValue(cfg.DB.ServiceReleases, service),
+		Version: getServiceValue(cfg.DB.ServiceVersions, service),
+	}
+}
+
+// applyServiceSelector updates the selector of service. Empty fields keep the
+// current value and `any` clears it.
+func applyServiceSelector(cfg *config.Config, service string, sel EndpointSelector) {
+	set := func(values *map[string]string, v string) {
+		v = strings.TrimSpace(v)
+		switch {
+		case v == "":
+		case strings.EqualFold(v, selectorAny):
+			setServiceValue(values, service, "")
+		default:
+			setServiceValue(values, service, v)
+		}
+	}
+	set(&cfg.DB.ServiceReleases, sel.Release)
+	set(&cfg.DB.ServiceVersions, sel.Version)
+}
+
+// selectEndpoints returns the endpoints that match sel.
+func selectEndpoints(endpoints []Endpoint, sel EndpointSelector) ([]Endpoint, error) {
+	if sel.IsZero() {
+		return endpoints, nil
+	}
+	constraints, err := parseVersionConstraints(sel.Version)
+	if err != nil {
+		return nil, err
+	}
+	release := strings.TrimSpace(sel.Release)
+
+	out := make([]Endpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		if release != "" && !strings.EqualFold(strings.TrimSpace(ep.ReleaseName), release) {
+			continue
+		}
+		if !constraints.match(ep.Version) {
+			continue
+		}
+		out = append(out, ep)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no endpoints match selector (%s)", sel)
+	}
+	return out, nil
+}
+
+type versionConstraint struct {
+	op      string
+	version []string
+}
+
+type versionConstraints []versionConstraint
+
+// parseVersionConstraints parses constraints like `>=15`, `<17.2` or `15`.
+// A bare version also matches its more specific versions, e.g. `15` matches
+// `15.4`.
+func parseVersionConstraints(s string) (versionConstraints, error) {
+	var out versionConstraints
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		op := ""
+		for _, candidate := range []string{">=", "<=", "!=", ">", "<", "="} {
+			if strings.HasPrefix(part, candidate) {
+				op = candidate
+				break
+			}
+		}
+		val := strings.TrimSpace(strings.TrimPrefix(part, op))
+		if val == "" || strings.ContainsAny(val, " <>=!") {
+			return nil, fmt.Errorf("invalid version constraint %q", part)
+		}
+		out = append(out, versionConstraint{op: op, version: splitVersion(val)})
+	}
+	return out, nil
+}
+
+func validateVersionConstraints(s string) error {
+	_, err := parseVersionConstraints(s)
+	return err
+}
+
+func (cs versionConstraints) match(version string) bool {
+	if len(cs) == 0 {
+		return true
+	}
+	v := splitVersion(version)
+	if len(v) == 0 {
+		return false
+	}
+	for _, c := range cs {
+		if !c.match(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c versionConstraint) match(v []string) bool {
+	if c.op == "" {
+		return len(v) >= len(c.version) && compareVersions(v[:len(c.version)], c.version) == 0
+	}
+	cmp := compareVersions(v, c.version)
+	switch c.op {
+	case ">=":
+		return cmp >= 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case "<":
+		return cmp < 0
+	case "!=":
+		return cmp != 0
+	default:
+		return cmp == 0
+	}
+}
+
+// splitVersion splits a version such as `v15.4-1` into dot-separated
+// segments, ignoring a leading `v`.
+func splitVersion(s string) []string {
+	s = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "v")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ".")
+}
+
+// compareVersions compares segment by segment, numerically by the leading
+// digits of each segment and then by the rest; missing segments count as 0.
+func compareVersions(a, b []string) int {
+	for i := 0; i < max(len(a), len(b)); i++ {
+		x, y := "0", "0"
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		xn, xRest := splitVersionSegment(x)
+		yn, yRest := splitVersionSegment(y)
+		if xn != yn {
+			if xn < yn {
+				return -1
+			}
+			return 1
+		}
+		if c := strings.Compare(xRest, yRest); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// splitVersionSegment splits a segment such as `2-1` into its leading number
+// and the rest.
+func splitVersionSegment(s string) (int, string) {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	n, _ := strconv.Atoi(s[:i])
+	return n, s[i:]
+}
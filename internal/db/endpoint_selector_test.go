@@ -0,0 +1,107 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"wslbridge/internal/config"
+	appruntime "wslbridge/internal/runtime"
+)
+
+func TestVersionConstraints(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{constraint: ">=15", version: "15", want: true},
+		{constraint: ">=15", version: "16.2", want: true},
+		{constraint: ">=15", version: "14.9", want: false},
+		{constraint: ">=15,<17", version: "16.4", want: true},
+		{constraint: ">=15,<17", version: "17.0", want: false},
+		{constraint: "15", version: "15.4", want: true},
+		{constraint: "15.4", version: "15", want: false},
+		{constraint: "=15.4", version: "v15.4", want: true},
+		{constraint: "!=16", version: "16.0", want: false},
+		{constraint: ">16.2", version: "16.10", want: true},
+		{constraint: ">16.2-1", version: "16.2-2", want: true},
+		{constraint: ">=15", version: "", want: false},
+		{constraint: "", version: "", want: true},
+	}
+	for _, tt := range tests {
+		cs, err := parseVersionConstraints(tt.constraint)
+		if err != nil {
+			t.Fatalf("parseVersionConstraints(%q) error: %v", tt.constraint, err)
+		}
+		if got := cs.match(tt.version); got != tt.want {
+			t.Fatalf("%q match %q = %v, want %v", tt.constraint, tt.version, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{">=", ">=>15", "< 15 16"} {
+		if err := validateVersionConstraints(bad); err == nil {
+			t.Fatalf("validateVersionConstraints(%q) expected error", bad)
+		}
+	}
+}
+
+func TestApplyServiceSelector(t *testing.T) {
+	var cfg config.Config
+	applyServiceSelector(&cfg, "Example-DB", EndpointSelector{Release: "blue", Version: ">=15"})
+	if sel := serviceSelector(cfg, "example-db"); sel != (EndpointSelector{Release: "blue", Version: ">=15"}) {
+		t.Fatalf("serviceSelector() = %+v", sel)
+	}
+	applyServiceSelector(&cfg, "example-db", EndpointSelector{Release: "green"})
+	if sel := serviceSelector(cfg, "example-db"); sel != (EndpointSelector{Release: "green", Version: ">=15"}) {
+		t.Fatalf("serviceSelector() after release update = %+v", sel)
+	}
+	applyServiceSelector(&cfg, "example-db", EndpointSelector{Release: "any", Version: "ANY"})
+	if sel := serviceSelector(cfg, "example-db"); !sel.IsZero() {
+		t.Fatalf("serviceSelector() after clear = %+v", sel)
+	}
+}
+
+// TestResolveTargetEndpoint_Selector verifies that selectors filter the
+// endpoint list before role selection.
+func TestResolveTargetEndpoint_Selector(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "endpoints.json")
+	body := `{"example-db":[
+		{"ReleaseName":"blue","InstanceName":"blue-a","Address":"10.0.0.1:6432","Version":"15.6","Role":"master","IsDefaultRoute":true},
+		{"ReleaseName":"green","InstanceName":"green-a","Address":"10.0.1.1:6432","Version":"16.2","Role":"master"},
+		{"ReleaseName":"green","InstanceName":"green-b","Address":"10.0.1.2:6432","Version":"16.2","Role":"sync"}
+	]}`
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+
+	var cfg config.Config
+	cfg.DB.DiscoveryProvider = DiscoveryProviderFile
+	cfg.DB.DiscoveryFile = path
+	cfg.DB.PreferRole = "master"
+	svc := NewService(appruntime.Runtime{Paths: appruntime.Paths{StateDir: t.TempDir()}})
+
+	tests := []struct {
+		selector EndpointSelector
+		role     string
+		want     string
+	}{
+		{want: "blue-a"},
+		{selector: EndpointSelector{Release: "green"}, want: "green-a"},
+		{selector: EndpointSelector{Version: ">=16"}, want: "green-a"},
+		{selector: EndpointSelector{Release: "GREEN"}, role: "sync", want: "green-b"},
+	}
+	for _, tt := range tests {
+		cfg.DB.ServiceReleases, cfg.DB.ServiceVersions = nil, nil
+		applyServiceSelector(&cfg, "example-db", tt.selector)
+		_, ep, err := svc.resolveTargetEndpoint(cfg, routeTarget{Service: "example-db", Role: tt.role})
+		if err != nil || ep.InstanceName != tt.want {
+			t.Fatalf("resolveTargetEndpoint(%+v, role=%q) = %+v, %v; want %s", tt.selector, tt.role, ep, err, tt.want)
+		}
+	}
+
+	applyServiceSelector(&cfg, "example-db", EndpointSelector{Release: "red"})
+	if _, _, err := svc.resolveTargetEndpoint(cfg, routeTarget{Service: "example-db"}); err == nil {
+		t.Fatalf("resolveTargetEndpoint() expected error when no endpoint matches the selector")
+	}
+}
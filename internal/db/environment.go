@@ -0,0 +1,232 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"wslbridge/internal/config"
+)
+
+// defaultEnvironment names the active environment of configs that never ran
+// `db env use`.
+const defaultEnvironment = "default"
+
+// environmentSeparator joins an environment and a service in database names
+// such as `stage/example-db`.
+const environmentSeparator = "/"
+
+var environmentNameRE = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// UseEnvironment makes name the active environment. The current environment
+// is stored under its name and name is created empty when it does not exist.
+func (s Service) UseEnvironment(nameArg string) error {
+	if err := s.checkSupported(); err != nil {
+		return err
+	}
+
+	name := strings.ToLower(strings.TrimSpace(nameArg))
+	if err := validateEnvironmentName(name); err != nil {
+		return fmt.Errorf("environment name: %w", err)
+	}
+
+	cfg, _, err := s.loadConfig()
+	if err != nil {
+		return err
+	}
+	s.applyDefaults(&cfg)
+
+	current := activeEnvironment(cfg)
+	if name == current {
+		fmt.Println("db environment already active:", name)
+		return nil
+	}
+	next, exists := cfg.DB.Environments[name]
+	if cfg.DB.Environments == nil {
+		cfg.DB.Environments = make(map[string]config.DBEnvironment)
+	}
+	cfg.DB.Environments[current] = cfg.DB.ActiveEnvironment()
+	delete(cfg.DB.Environments, name)
+	cfg.DB.ApplyEnvironment(next)
+	cfg.DB.Environment = name
+	s.applyDefaults(&cfg)
+
+	if err := s.saveConfig(cfg); err != nil {
+		return err
+	}
+	if hasProxyRoutes(cfg) {
+		if err := s.writeProxyRoutesFile(cfg); err != nil {
+			return err
+		}
+		if IsProxyRunning(s.rt.Paths.DBProxyPIDFile) {
+			if err := s.ensureProxyRunning(cfg); err != nil {
+				return err
+			}
+		}
+	}
+
+	if exists {
+		fmt.Println("db environment:", name)
+	} else {
+		fmt.Println("db environment created:", name)
+	}
+	fmt.Println("db services:", servicesLabel(cfg.DB.ServiceNames))
+	if err := ensureServiceDiscoveryConfigured(cfg); err != nil {
+		fmt.Printf("service discovery is not configured for %s (run `db init`)\n", name)
+	}
+	return nil
+}
+
+// ListEnvironments prints the configured environments and their services.
+func (s Service) ListEnvironments() error {
+	if err := s.checkSupported(); err != nil {
+		return err
+	}
+
+	cfg, _, err := s.loadConfig()
+	if err != nil {
+		return err
+	}
+	s.applyDefaults(&cfg)
+
+	active := activeEnvironment(cfg)
+	fmt.Printf("* %s: %s (%s)\n", active, serviceDiscoveryLabel(cfg), servicesLabel(cfg.DB.ServiceNames))
+	for _, name := range environmentNames(cfg) {
+		envCfg := environmentConfig(cfg, name)
+		fmt.Printf("  %s: %s (%s)\n", name, serviceDiscoveryLabel(envCfg), servicesLabel(envCfg.DB.ServiceNames))
+	}
+	return nil
+}
+
+// RemoveEnvironment deletes an inactive environment.
+func (s Service) RemoveEnvironment(nameArg string) error {
+	if err := s.checkSupported(); err != nil {
+		return err
+	}
+
+	name := strings.ToLower(strings.TrimSpace(nameArg))
+	cfg, _, err := s.loadConfig()
+	if err != nil {
+		return err
+	}
+	s.applyDefaults(&cfg)
+
+	if name == activeEnvironment(cfg) {
+		return fmt.Errorf("environment %q is active (switch with `db env use <name>` first)", name)
+	}
+	if _, ok := cfg.DB.Environments[name]; !ok {
+		fmt.Println("db environment not found:", name)
+		return nil
+	}
+	delete(cfg.DB.Environments, name)
+	if len(cfg.DB.Environments) == 0 {
+		cfg.DB.Environments = nil
+	}
+
+	if err := s.saveConfig(cfg); err != nil {
+		return err
+	}
+	if !hasProxyRoutes(cfg) {
+		if err := StopProxyDaemon(DefaultProxyFiles(s.rt)); err != nil {
+			return err
+		}
+		_ = os.Remove(s.proxyRoutesPath())
+	} else if err := s.writeProxyRoutesFile(cfg); err != nil {
+		return err
+	}
+	fmt.Println("db environment removed:", name)
+	return nil
+}
+
+// refreshEnvironments re-resolves the services of inactive environments.
+// Failures keep the previous endpoint so one unreachable environment does
+// not block the others.
+func (s Service) refreshEnvironments(cfg *config.Config) {
+	for _, name := range environmentNames(*cfg) {
+		envCfg := environmentConfig(*cfg, name)
+		if len(envCfg.DB.ServiceNames) == 0 {
+			continue
+		}
+		if err := ensureServiceDiscoveryConfigured(envCfg); err != nil {
+			fmt.Printf("environment %s: %v\n", name, err)
+			continue
+		}
+		for _, service := range envCfg.DB.ServiceNames {
+			target := routeTarget{Service: service}
+			source, ep, err := s.resolveTargetEndpoint(envCfg, target)
+			if err == nil {
+				err = CheckTCPConnectivity(ep.Address, defaultConnectivityTimeout)
+			}
+			if err != nil {
+				fmt.Printf("service %s%s%s: keeping previous endpoint: %v\n", name, environmentSeparator, service, err)
+				continue
+			}
+			s.setTargetEndpoint(&envCfg, target, ep)
+			fmt.Printf("service %s%s%s -> %s (%s)\n", name, environmentSeparator, service, ep.Address, source)
+		}
+		cfg.DB.Environments[name] = envCfg.DB.ActiveEnvironment()
+	}
+}
+
+// environmentConfig returns cfg with the inactive environment name applied,
+// so the usual resolve helpers work on it.
+func environmentConfig(cfg config.Config, name string) config.Config {
+	cfg.DB.ApplyEnvironment(cfg.DB.Environments[name])
+	cfg.DB.Environment = name
+	cfg.DB.ServiceNames = normalizeServiceNames(cfg.DB.ServiceNames)
+	if strings.TrimSpace(cfg.DB.ServiceDiscoveryScheme) == "" {
+		cfg.DB.ServiceDiscoveryScheme = defaultServiceDiscoveryScheme
+	}
+	if strings.TrimSpace(cfg.DB.EndpointMask) == "" {
+		cfg.DB.EndpointMask = defaultEndpointMask
+	}
+	if strings.TrimSpace(cfg.DB.PreferRole) == "" {
+		cfg.DB.PreferRole = defaultPreferRole
+	}
+	return cfg
+}
+
+// environmentNames returns the inactive environments in name order.
+func environmentNames(cfg config.Config) []string {
+	active := activeEnvironment(cfg)
+	names := make([]string, 0, len(cfg.DB.Environments))
+	for name := range cfg.DB.Environments {
+		if name != active {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func activeEnvironment(cfg config.Config) string {
+	name := strings.ToLower(strings.TrimSpace(cfg.DB.Environment))
+	if name == "" {
+		return defaultEnvironment
+	}
+	return name
+}
+
+// environmentServiceKey is the routes file key of service in environment env.
+func environmentServiceKey(env, service string) string {
+	return env + environmentSeparator + serviceKey(service)
+}
+
+// hasEnvironmentServices reports whether an inactive environment has services.
+func hasEnvironmentServices(cfg config.Config) bool {
+	for _, name := range environmentNames(cfg) {
+		if len(cfg.DB.Environments[name].ServiceNames) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func validateEnvironmentName(s string) error {
+	if !environmentNameRE.MatchString(s) {
+		return fmt.Errorf("must start with a letter or digit and contain only letters, digits, `-` and `_`")
+	}
+	return nil
+}
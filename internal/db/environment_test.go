@@ -0,0 +1,48 @@
+package db
+
+import (
+	"testing"
+
+	"wslbridge/internal/config"
+)
+
+func TestEnvironmentDatabase(t *testing.T) {
+	routes := proxyRoutesFile{Environments: []string{"stage", "default"}}
+	tests := map[string]bool{
+		"stage/example-db":   true,
+		"Default/example-db": true,
+		"prod/example-db":    false,
+		"example-db":         false,
+	}
+	for database, want := range tests {
+		if got := routes.environmentDatabase(database); got != want {
+			t.Fatalf("environmentDatabase(%q) = %v, want %v", database, got, want)
+		}
+	}
+}
+
+// TestEnvironmentConfig verifies that inactive environments are resolved with
+// their own settings and defaults.
+func TestEnvironmentConfig(t *testing.T) {
+	var cfg config.Config
+	cfg.DB.Environment = "stage"
+	cfg.DB.ServiceDiscoveryHost = "sd.stage.example.internal"
+	cfg.DB.LocalPort = 15432
+	cfg.DB.Environments = map[string]config.DBEnvironment{
+		"prod": {ServiceDiscoveryHost: "sd.prod.example.internal", ServiceNames: []string{"example-db"}},
+	}
+
+	if got := environmentNames(cfg); len(got) != 1 || got[0] != "prod" {
+		t.Fatalf("environmentNames() = %v", got)
+	}
+	envCfg := environmentConfig(cfg, "prod")
+	if envCfg.DB.ServiceDiscoveryHost != "sd.prod.example.internal" || envCfg.DB.EndpointMask != defaultEndpointMask || envCfg.DB.LocalPort != 15432 {
+		t.Fatalf("environmentConfig() = %+v", envCfg.DB)
+	}
+	if activeEnvironment(envCfg) != "prod" || !hasEnvironmentServices(cfg) {
+		t.Fatalf("activeEnvironment()=%q hasEnvironmentServices()=%v", activeEnvironment(envCfg), hasEnvironmentServices(cfg))
+	}
+	if err := validateEnvironmentName("stage/eu"); err == nil {
+		t.Fatalf("validateEnvironmentName() expected error for a name with a separator")
+	}
+}
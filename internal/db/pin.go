@@ -0,0 +1,201 @@
+package db
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"wslbridge/internal/config"
+)
+
+// pinnedAddressSource is reported instead of a discovery URL for services
+// pinned to a fixed address.
+const pinnedAddressSource = "pinned address"
+
+// PinService fixes service to one discovered instance or to a fixed address.
+// Pinned services skip ChooseEndpoint whenever they are resolved.
+func (s Service) PinService(serviceArg, instance, address string) error {
+	if err := s.checkSupported(); err != nil {
+		return err
+	}
+
+	service := strings.TrimSpace(serviceArg)
+	if err := validateServiceName(service); err != nil {
+		return fmt.Errorf("service name: %w", err)
+	}
+	instance = strings.TrimSpace(instance)
+	address = strings.TrimSpace(address)
+	if (instance == "") == (address == "") {
+		return fmt.Errorf("exactly one of --instance or --address is required")
+	}
+	if address != "" {
+		if err := validatePinAddress(address); err != nil {
+			return fmt.Errorf("--address: %w", err)
+		}
+	}
+
+	cfg, _, err := s.loadConfig()
+	if err != nil {
+		return err
+	}
+	s.applyDefaults(&cfg)
+	if !containsServiceName(cfg.DB.ServiceNames, service) {
+		return fmt.Errorf("service %q is not added (use `db add %s` first)", service, service)
+	}
+	if instance != "" {
+		if err := ensureServiceDiscoveryConfigured(cfg); err != nil {
+			return fmt.Errorf("%w (run `db init` first)", err)
+		}
+	}
+
+	setServiceValue(&cfg.DB.PinnedInstances, service, instance)
+	setServiceValue(&cfg.DB.PinnedAddresses, service, address)
+
+	target := routeTarget{Service: service}
+	source, ep, err := s.resolveTargetEndpoint(cfg, target)
+	if err != nil {
+		return fmt.Errorf("service %q pin failed: %w", service, err)
+	}
+	if err := CheckTCPConnectivity(ep.Address, defaultConnectivityTimeout); err != nil {
+		return fmt.Errorf("service %q pinned endpoint is unreachable (%s): %w", service, ep.Address, err)
+	}
+	s.setTargetEndpoint(&cfg, target, ep)
+
+	if err := s.saveAndRefreshRoutes(cfg); err != nil {
+		return err
+	}
+	fmt.Printf("db service pinned: %s -> %s (%s)\n", service, ep.Address, source)
+	if ep.InstanceName != "" {
+		fmt.Println("db pinned instance:", ep.InstanceName)
+	}
+	return nil
+}
+
+// UnpinService removes a pin and selects the endpoint via ChooseEndpoint again.
+func (s Service) UnpinService(serviceArg string) error {
+	if err := s.checkSupported(); err != nil {
+		return err
+	}
+
+	service := strings.TrimSpace(serviceArg)
+	if err := validateServiceName(service); err != nil {
+		return fmt.Errorf("service name: %w", err)
+	}
+
+	cfg, _, err := s.loadConfig()
+	if err != nil {
+		return err
+	}
+	s.applyDefaults(&cfg)
+	if servicePinLabel(cfg, service) == "" {
+		fmt.Println("db service not pinned:", service)
+		return nil
+	}
+	setServiceValue(&cfg.DB.PinnedInstances, service, "")
+	setServiceValue(&cfg.DB.PinnedAddresses, service, "")
+
+	target := routeTarget{Service: service}
+	source, ep, resolveErr := s.resolveTargetEndpoint(cfg, target)
+	if resolveErr == nil {
+		resolveErr = CheckTCPConnectivity(ep.Address, defaultConnectivityTimeout)
+	}
+	if resolveErr != nil {
+		fmt.Printf("service %s: keeping the current endpoint until the next `db start`: %v\n", service, resolveErr)
+	} else {
+		s.setTargetEndpoint(&cfg, target, ep)
+	}
+
+	if err := s.saveAndRefreshRoutes(cfg); err != nil {
+		return err
+	}
+	fmt.Println("db service unpinned:", service)
+	if resolveErr == nil {
+		fmt.Printf("db selected endpoint: %s (%s)\n", ep.Address, source)
+	}
+	return nil
+}
+
+// resolveTargetEndpoint resolves target, honouring a pin or the endpoint
+// selector of the service. Address pins do not query service discovery at all.
+func (s Service) resolveTargetEndpoint(cfg config.Config, target routeTarget) (string, Endpoint, error) {
+	if target.Role == "" {
+		if address := getServiceValue(cfg.DB.PinnedAddresses, target.Service); address != "" {
+			return pinnedAddressSource, Endpoint{Address: address}, nil
+		}
+		if instance := getServiceValue(cfg.DB.PinnedInstances, target.Service); instance != "" {
+			return s.resolveEndpointWith(cfg, target.Service, func(endpoints []Endpoint) (Endpoint, error) {
+				return pickInstance(endpoints, instance)
+			})
+		}
+	}
+	targetCfg := target.config(cfg)
+	sel := serviceSelector(cfg, target.Service)
+	return s.resolveEndpointWith(targetCfg, target.Service, func(endpoints []Endpoint) (Endpoint, error) {
+		selected, err := selectEndpoints(endpoints, sel)
+		if err != nil {
+			return Endpoint{}, err
+		}
+		return ChooseEndpoint(selected, targetCfg.DB.PreferRole)
+	})
+}
+
+func (s Service) setTargetEndpoint(cfg *config.Config, target routeTarget, ep Endpoint) {
+	setServiceValue(&cfg.DB.ServiceTargets, target.key(), ep.Address)
+	setServiceValue(&cfg.DB.ServiceInstances, target.key(), ep.InstanceName)
+	if strings.EqualFold(cfg.DB.ServiceName, target.key()) {
+		cfg.DB.TargetAddress = ep.Address
+		cfg.DB.TargetInstance = ep.InstanceName
+	}
+}
+
+// saveAndRefreshRoutes persists cfg and rewrites the routes file of a
+// running proxy.
+func (s Service) saveAndRefreshRoutes(cfg config.Config) error {
+	if err := s.saveConfig(cfg); err != nil {
+		return err
+	}
+	if err := s.writeProxyRoutesFile(cfg); err != nil {
+		return err
+	}
+	if IsProxyRunning(s.rt.Paths.DBProxyPIDFile) {
+		return s.ensureProxyRunning(cfg)
+	}
+	return nil
+}
+
+// pickInstance returns the endpoint of the named instance.
+func pickInstance(endpoints []Endpoint, instance string) (Endpoint, error) {
+	if ep, ok := pick(endpoints, func(e Endpoint) bool {
+		return strings.EqualFold(e.InstanceName, instance) && e.Address != ""
+	}); ok {
+		return ep, nil
+	}
+	return Endpoint{}, fmt.Errorf("pinned instance %q is not in the service discovery answer", instance)
+}
+
+// servicePinLabel describes the pin on service, or returns "" if it has none.
+func servicePinLabel(cfg config.Config, service string) string {
+	if address := getServiceValue(cfg.DB.PinnedAddresses, service); address != "" {
+		return "address " + address
+	}
+	if instance := getServiceValue(cfg.DB.PinnedInstances, service); instance != "" {
+		return "instance " + instance
+	}
+	return ""
+}
+
+func validatePinAddress(s string) error {
+	host, port, err := net.SplitHostPort(strings.TrimSpace(s))
+	if err != nil {
+		return fmt.Errorf("must be host:port")
+	}
+	if host == "" {
+		return fmt.Errorf("host must not be empty")
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("port must be between 1 and 65535")
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"wslbridge/internal/config"
+	appruntime "wslbridge/internal/runtime"
+)
+
+// TestResolveTargetEndpoint_Pins verifies that pinned services skip
+// ChooseEndpoint and that address pins skip service discovery.
+func TestResolveTargetEndpoint_Pins(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "endpoints.json")
+	body := `{"example-db":[
+		{"InstanceName":"db-a","Address":"10.0.0.1:6432","Role":"master","IsDefaultRoute":true},
+		{"InstanceName":"db-b","Address":"10.0.0.2:6432","Role":"sync"}
+	]}`
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+
+	var cfg config.Config
+	cfg.DB.DiscoveryProvider = DiscoveryProviderFile
+	cfg.DB.DiscoveryFile = path
+	cfg.DB.PreferRole = "master"
+	svc := NewService(appruntime.Runtime{Paths: appruntime.Paths{StateDir: t.TempDir()}})
+	target := routeTarget{Service: "example-db"}
+
+	if _, ep, err := svc.resolveTargetEndpoint(cfg, target); err != nil || ep.InstanceName != "db-a" {
+		t.Fatalf("resolveTargetEndpoint() unpinned = %+v, %v", ep, err)
+	}
+
+	cfg.DB.PinnedInstances = map[string]string{"example-db": "DB-B"}
+	if _, ep, err := svc.resolveTargetEndpoint(cfg, target); err != nil || ep.Address != "10.0.0.2:6432" {
+		t.Fatalf("resolveTargetEndpoint() instance pin = %+v, %v", ep, err)
+	}
+	if _, ep, err := svc.resolveTargetEndpoint(cfg, routeTarget{Service: "example-db", Role: "master"}); err != nil || ep.InstanceName != "db-a" {
+		t.Fatalf("resolveTargetEndpoint() role target = %+v, %v", ep, err)
+	}
+
+	cfg.DB.PinnedInstances = map[string]string{"example-db": "db-z"}
+	if _, _, err := svc.resolveTargetEndpoint(cfg, target); err == nil {
+		t.Fatalf("resolveTargetEndpoint() expected error for a missing pinned instance")
+	}
+
+	cfg.DB.PinnedInstances = nil
+	cfg.DB.PinnedAddresses = map[string]string{"example-db": "10.9.9.9:5432"}
+	cfg.DB.DiscoveryFile = filepath.Join(t.TempDir(), "missing.json")
+	source, ep, err := svc.resolveTargetEndpoint(cfg, target)
+	if err != nil || ep.Address != "10.9.9.9:5432" || source != pinnedAddressSource {
+		t.Fatalf("resolveTargetEndpoint() address pin = %q, %+v, %v", source, ep, err)
+	}
+}
+
+func TestValidatePinAddress(t *testing.T) {
+	for _, addr := range []string{"10.0.0.1:6432", "db.example.internal:5432", "[::1]:6432"} {
+		if err := validatePinAddress(addr); err != nil {
+			t.Fatalf("validatePinAddress(%q) error: %v", addr, err)
+		}
+	}
+	for _, addr := range []string{"", "10.0.0.1", ":6432", "10.0.0.1:0", "10.0.0.1:pg"} {
+		if err := validatePinAddress(addr); err == nil {
+			t.Fatalf("validatePinAddress(%q) expected error", addr)
+		}
+	}
+}
@@ -52,16 +52,38 @@ type proxyRoute struct {
 	Service    string `json:"service"`
 	TargetAddr string `json:"target_addr"`
 	Instance   string `json:"instance,omitempty"`
+	Auto       bool   `json:"auto,omitempty"`
+	Pinned     bool   `json:"pinned,omitempty"`
 }
 
 type proxyRoutesFile struct {
-	Services map[string]proxyRoute `json:"services"`
+	Services     map[string]proxyRoute `json:"services"`
+	Rules        []proxyRouteRule      `json:"rules,omitempty"`
+	Discovery    *proxyDiscovery       `json:"discovery,omitempty"`
+	Auto         *proxyAutoResolve     `json:"auto,omitempty"`
+	Environments []string              `json:"environments,omitempty"`
+}
+
+// environmentDatabase reports whether database carries the prefix of a known
+// environment, e.g. `stage/example-db`. Such names only match static routes.
+func (routes proxyRoutesFile) environmentDatabase(database string) bool {
+	env, _, ok := strings.Cut(strings.ToLower(strings.TrimSpace(database)), environmentSeparator)
+	if !ok {
+		return false
+	}
+	for _, name := range routes.Environments {
+		if name == env {
+			return true
+		}
+	}
+	return false
 }
 
 type startupRequest struct {
-	Packet   []byte
-	Database string
-	User     string
+	Packet          []byte
+	Database        string
+	User            string
+	ApplicationName string
 }
 
 type cancelRequest struct {
@@ -215,6 +237,7 @@ func runTCPProxy(listenAddr, routesFile string) error {
 	}
 	defer ln.Close()
 
+	router := newProxyRouter(routesFile)
 	for {
 		clientConn, err := ln.Accept()
 		if err != nil {
@@ -224,20 +247,14 @@ func runTCPProxy(listenAddr, routesFile string) error {
 			}
 			return err
 		}
-		go proxyConn(clientConn, routesFile)
+		go proxyConn(clientConn, router)
 	}
 }
 
-func proxyConn(clientConn net.Conn, routesFile string) {
+func proxyConn(clientConn net.Conn, router *proxyRouter) {
 	defer clientConn.Close()
 
-	routes, err := loadProxyRoutes(routesFile)
-	if err != nil {
-		writeErrorResponse(clientConn, "wslbridge proxy routes are not available")
-		return
-	}
-
-	req, route, cancelReq, isCancel, err := readClientRequest(clientConn, routes)
+	req, route, cancelReq, isCancel, err := readClientRequest(clientConn, router)
 	if err != nil {
 		if isClientDisconnectError(err) {
 			return
@@ -252,6 +269,9 @@ func proxyConn(clientConn net.Conn, routesFile string) {
 
 	serverConn, err := net.Dial("tcp", route.TargetAddr)
 	if err != nil {
+		if route.Auto {
+			router.evict(route.TargetAddr)
+		}
 		writeErrorResponse(clientConn, fmt.Sprintf("upstream %s is unreachable", route.TargetAddr))
 		return
 	}
@@ -296,13 +316,13 @@ func loadProxyRoutes(path string) (proxyRoutesFile, error) {
 	if err := json.Unmarshal(b, &routes); err != nil {
 		return proxyRoutesFile{}, err
 	}
-	if len(routes.Services) == 0 {
+	if len(routes.Services) == 0 && len(routes.Rules) == 0 && routes.Auto == nil {
 		return proxyRoutesFile{}, fmt.Errorf("proxy route map is empty")
 	}
 	return routes, nil
 }
 
-func readClientRequest(conn net.Conn, routes proxyRoutesFile) (startupRequest, proxyRoute, cancelRequest, bool, error) {
+func readClientRequest(conn net.Conn, router *proxyRouter) (startupRequest, proxyRoute, cancelRequest, bool, error) {
 	if err := conn.SetReadDeadline(time.Now().Add(10 * time.Second)); err != nil {
 		return startupRequest{}, proxyRoute{}, cancelRequest{}, false, err
 	}
@@ -332,7 +352,7 @@ func readClientRequest(conn net.Conn, routes proxyRoutesFile) (startupRequest, p
 			return startupRequest{}, proxyRoute{}, cancelRequest{}, false, err
 		}
 
-		route, err := findProxyRoute(routes, req.Database, req.User)
+		route, err := router.route(req)
 		if err != nil {
 			return startupRequest{}, proxyRoute{}, cancelRequest{}, false, err
 		}
@@ -383,9 +403,10 @@ func parseStartupRequest(packet []byte, code uint32) (startupRequest, error) {
 	}
 
 	return startupRequest{
-		Packet:   packet,
-		Database: database,
-		User:     user,
+		Packet:          packet,
+		Database:        database,
+		User:            user,
+		ApplicationName: strings.TrimSpace(params["application_name"]),
 	}, nil
 }
 
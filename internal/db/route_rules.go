@@ -0,0 +1,290 @@
+package db
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"wslbridge/internal/config"
+)
+
+// proxyRouteRule is a route rule as seen by the proxy daemon. Rules with a
+// Service carry its resolved target; rules without one resolve on demand.
+type proxyRouteRule struct {
+	Database        string `json:"database,omitempty"`
+	User            string `json:"user,omitempty"`
+	ApplicationName string `json:"application_name,omitempty"`
+	Service         string `json:"service,omitempty"`
+	Role            string `json:"role,omitempty"`
+	TargetAddr      string `json:"target_addr,omitempty"`
+	Instance        string `json:"instance,omitempty"`
+}
+
+func (r proxyRouteRule) route(database string) (proxyRoute, error) {
+	if strings.TrimSpace(r.TargetAddr) == "" {
+		return proxyRoute{}, fmt.Errorf("database %q matches rule %q but service %q has no resolved target", database, r.label(), r.Service)
+	}
+	return proxyRoute{
+		Service:    r.Service,
+		TargetAddr: r.TargetAddr,
+		Instance:   r.Instance,
+	}, nil
+}
+
+func (r proxyRouteRule) matches(req startupRequest) bool {
+	if r.Database == "" && r.User == "" && r.ApplicationName == "" {
+		return false
+	}
+	return matchOptionalPattern(r.Database, req.Database) &&
+		matchOptionalPattern(r.User, req.User) &&
+		matchOptionalPattern(r.ApplicationName, req.ApplicationName)
+}
+
+// clientScoped reports whether the rule looks at who connects (user or
+// application_name) rather than only at the requested database.
+func (r proxyRouteRule) clientScoped() bool {
+	return r.User != "" || r.ApplicationName != ""
+}
+
+// persistable reports whether routes resolved through the rule are valid for
+// every client asking for the same database, so they may be cached by name.
+func (r proxyRouteRule) persistable() bool {
+	return !r.clientScoped() && r.Role == ""
+}
+
+func (r proxyRouteRule) label() string {
+	return routeRuleLabel(config.DBRouteRule{
+		Database:        r.Database,
+		User:            r.User,
+		ApplicationName: r.ApplicationName,
+		Service:         r.Service,
+		Role:            r.Role,
+	})
+}
+
+// matchProxyRouteRule returns the first rule, in precedence order, that
+// matches req among client-scoped rules or among database-only rules.
+func matchProxyRouteRule(rules []proxyRouteRule, req startupRequest, clientScoped bool) (proxyRouteRule, bool) {
+	for _, rule := range rules {
+		if rule.clientScoped() != clientScoped {
+			continue
+		}
+		if rule.matches(req) {
+			return rule, true
+		}
+	}
+	return proxyRouteRule{}, false
+}
+
+func matchOptionalPattern(pattern, value string) bool {
+	if strings.TrimSpace(pattern) == "" {
+		return true
+	}
+	return matchRoutePattern(pattern, value)
+}
+
+// matchRoutePattern matches name against a case-insensitive glob.
+func matchRoutePattern(pattern, name string) bool {
+	p := serviceKey(pattern)
+	if p == "" {
+		return false
+	}
+	ok, err := path.Match(p, serviceKey(name))
+	return err == nil && ok
+}
+
+func validateRoutePattern(pattern string) error {
+	p := strings.TrimSpace(pattern)
+	if p == "" {
+		return fmt.Errorf("pattern must not be empty")
+	}
+	if strings.ContainsAny(p, " \t\r\n") {
+		return fmt.Errorf("pattern must not contain spaces")
+	}
+	if _, err := path.Match(p, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", p, err)
+	}
+	return nil
+}
+
+func validateRouteRule(rule config.DBRouteRule) error {
+	if rule.Database == "" && rule.User == "" && rule.ApplicationName == "" {
+		return fmt.Errorf("route rule must match on database, user or application_name")
+	}
+	for _, p := range []string{rule.Database, rule.User, rule.ApplicationName} {
+		if p == "" {
+			continue
+		}
+		if err := validateRoutePattern(p); err != nil {
+			return err
+		}
+	}
+	if rule.Service != "" {
+		if err := validateServiceName(rule.Service); err != nil {
+			return err
+		}
+	}
+	if rule.Role != "" {
+		if err := validateRole(rule.Role); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func routeRuleKey(rule config.DBRouteRule) string {
+	return serviceKey(rule.Database) + "\x00" + serviceKey(rule.User) + "\x00" + serviceKey(rule.ApplicationName)
+}
+
+func normalizeRouteRules(rules []config.DBRouteRule) []config.DBRouteRule {
+	if len(rules) == 0 {
+		return nil
+	}
+	out := make([]config.DBRouteRule, 0, len(rules))
+	seen := make(map[string]struct{}, len(rules))
+	for _, rule := range rules {
+		rule.Database = strings.TrimSpace(rule.Database)
+		rule.User = strings.TrimSpace(rule.User)
+		rule.ApplicationName = strings.TrimSpace(rule.ApplicationName)
+		rule.Service = strings.TrimSpace(rule.Service)
+		rule.Role = strings.ToLower(strings.TrimSpace(rule.Role))
+		if rule.Database == "" && rule.User == "" && rule.ApplicationName == "" {
+			continue
+		}
+		key := routeRuleKey(rule)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		out = append(out, rule)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// insertRouteRule places rule at index (0-based, clamped) or appends when
+// index is negative. An existing rule with the same match criteria is replaced.
+func insertRouteRule(rules []config.DBRouteRule, rule config.DBRouteRule, index int) []config.DBRouteRule {
+	list := normalizeRouteRules(rules)
+	key := routeRuleKey(rule)
+	out := make([]config.DBRouteRule, 0, len(list)+1)
+	for _, existing := range list {
+		if routeRuleKey(existing) != key {
+			out = append(out, existing)
+		}
+	}
+	if index < 0 || index >= len(out) {
+		return append(out, rule)
+	}
+	out = append(out[:index], append([]config.DBRouteRule{rule}, out[index:]...)...)
+	return out
+}
+
+// removeRouteRule removes the rule at a 1-based position, or the
+// database-only rule with the given pattern.
+func removeRouteRule(rules []config.DBRouteRule, ref string) ([]config.DBRouteRule, bool) {
+	list := normalizeRouteRules(rules)
+	target := routeRuleKey(config.DBRouteRule{Database: ref})
+	position := -1
+	if n, err := strconv.Atoi(strings.TrimSpace(ref)); err == nil && n >= 1 {
+		position = n - 1
+	}
+
+	out := make([]config.DBRouteRule, 0, len(list))
+	removed := false
+	for i, rule := range list {
+		if i == position || (position < 0 && routeRuleKey(rule) == target) {
+			removed = true
+			continue
+		}
+		out = append(out, rule)
+	}
+	return out, removed
+}
+
+// routeTarget is a service resolved by `db start`. Role, when set, overrides
+// the preferred role so the same service can be routed to several roles.
+type routeTarget struct {
+	Service string
+	Role    string
+}
+
+func (t routeTarget) key() string {
+	if t.Role == "" {
+		return serviceKey(t.Service)
+	}
+	return serviceKey(t.Service) + "@" + t.Role
+}
+
+func (t routeTarget) config(cfg config.Config) config.Config {
+	if t.Role != "" {
+		cfg.DB.PreferRole = t.Role
+	}
+	return cfg
+}
+
+func ruleTarget(rule config.DBRouteRule) routeTarget {
+	return routeTarget{Service: rule.Service, Role: rule.Role}
+}
+
+// routeTargets returns explicitly added services followed by the fixed
+// services referenced from route rules; all of them are resolved on start.
+func routeTargets(cfg config.Config) []routeTarget {
+	names := normalizeServiceNames(cfg.DB.ServiceNames)
+	out := make([]routeTarget, 0, len(names)+len(cfg.DB.RouteRules))
+	seen := make(map[string]struct{}, cap(out))
+	for _, name := range names {
+		out = append(out, routeTarget{Service: name})
+		seen[serviceKey(name)] = struct{}{}
+	}
+	for _, rule := range cfg.DB.RouteRules {
+		if strings.TrimSpace(rule.Service) == "" {
+			continue
+		}
+		t := ruleTarget(rule)
+		if _, ok := seen[t.key()]; ok {
+			continue
+		}
+		seen[t.key()] = struct{}{}
+		out = append(out, t)
+	}
+	return out
+}
+
+func routeTargetKeys(cfg config.Config) []string {
+	targets := routeTargets(cfg)
+	out := make([]string, 0, len(targets))
+	for _, t := range targets {
+		out = append(out, t.key())
+	}
+	return out
+}
+
+// hasProxyRoutes reports whether the proxy has anything to serve.
+func hasProxyRoutes(cfg config.Config) bool {
+	return len(cfg.DB.ServiceNames) > 0 || len(cfg.DB.RouteRules) > 0 || cfg.DB.AutoResolve || hasEnvironmentServices(cfg)
+}
+
+func routeRuleLabel(rule config.DBRouteRule) string {
+	var match []string
+	if rule.Database != "" {
+		match = append(match, "database="+rule.Database)
+	}
+	if rule.User != "" {
+		match = append(match, "user="+rule.User)
+	}
+	if rule.ApplicationName != "" {
+		match = append(match, "application_name="+rule.ApplicationName)
+	}
+	target := rule.Service
+	if target == "" {
+		target = "(resolve database via mask)"
+	}
+	if rule.Role != "" {
+		target += " role=" + rule.Role
+	}
+	return fmt.Sprintf("%s -> %s", strings.Join(match, " "), target)
+}
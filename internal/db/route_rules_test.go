@@ -0,0 +1,105 @@
+package db
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"wslbridge/internal/config"
+)
+
+// TestMatchProxyRouteRule verifies first-match precedence and case-insensitive globs.
+func TestMatchProxyRouteRule(t *testing.T) {
+	rules := []proxyRouteRule{
+		{Database: "tenant_*", Service: "tenants-shard", TargetAddr: "10.0.0.5:6432"},
+		{Database: "*-db"},
+		{Database: "tenant_*-db", Service: "never"},
+	}
+
+	rule, ok := matchProxyRouteRule(rules, startupRequest{Database: "TENANT_42-db"}, false)
+	if !ok || rule.Service != "tenants-shard" {
+		t.Fatalf("matchProxyRouteRule(TENANT_42-db) = %+v, %v", rule, ok)
+	}
+	rule, ok = matchProxyRouteRule(rules, startupRequest{Database: "reporting-db"}, false)
+	if !ok || rule.Service != "" {
+		t.Fatalf("matchProxyRouteRule(reporting-db) = %+v, %v", rule, ok)
+	}
+	if _, ok := matchProxyRouteRule(rules, startupRequest{Database: "postgres"}, false); ok {
+		t.Fatalf("matchProxyRouteRule(postgres) expected no match")
+	}
+}
+
+// TestMatchProxyRouteRule_StartupParams verifies matching on user and application_name.
+func TestMatchProxyRouteRule_StartupParams(t *testing.T) {
+	rules := []proxyRouteRule{
+		{User: "reporting", Service: "analytics-db", Role: "async"},
+		{Database: "example-db", ApplicationName: "sandbox-*", Service: "sandbox-db"},
+	}
+
+	rule, ok := matchProxyRouteRule(rules, startupRequest{Database: "example-db", User: "Reporting"}, true)
+	if !ok || rule.Service != "analytics-db" || rule.Role != "async" {
+		t.Fatalf("matchProxyRouteRule(user=reporting) = %+v, %v", rule, ok)
+	}
+	rule, ok = matchProxyRouteRule(rules, startupRequest{Database: "example-db", User: "app", ApplicationName: "sandbox-cli"}, true)
+	if !ok || rule.Service != "sandbox-db" {
+		t.Fatalf("matchProxyRouteRule(application_name=sandbox-cli) = %+v, %v", rule, ok)
+	}
+	if _, ok := matchProxyRouteRule(rules, startupRequest{Database: "other-db", User: "app", ApplicationName: "sandbox-cli"}, true); ok {
+		t.Fatalf("matchProxyRouteRule expected all criteria to be required")
+	}
+	if _, ok := matchProxyRouteRule(rules, startupRequest{Database: "example-db", User: "reporting"}, false); ok {
+		t.Fatalf("matchProxyRouteRule expected client-scoped rules to be skipped for database-only lookup")
+	}
+}
+
+// TestInsertRouteRule verifies positional insertion and replacement by pattern.
+func TestInsertRouteRule(t *testing.T) {
+	rules := []config.DBRouteRule{{Database: "a_*"}, {Database: "b_*"}}
+
+	got := insertRouteRule(rules, config.DBRouteRule{Database: "c_*"}, 0)
+	want := []config.DBRouteRule{{Database: "c_*"}, {Database: "a_*"}, {Database: "b_*"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("insertRouteRule got %v, want %v", got, want)
+	}
+
+	got = insertRouteRule(got, config.DBRouteRule{Database: "A_*", Service: "shard-a"}, -1)
+	want = []config.DBRouteRule{{Database: "c_*"}, {Database: "b_*"}, {Database: "A_*", Service: "shard-a"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("insertRouteRule got %v, want %v", got, want)
+	}
+}
+
+// TestProxyRouter_RulePrecedence verifies that exact services win over database
+// rules and that user/application_name rules win over exact services.
+func TestProxyRouter_RulePrecedence(t *testing.T) {
+	routesPath := filepath.Join(t.TempDir(), "db-routes.json")
+	if err := writeProxyRoutes(routesPath, proxyRoutesFile{
+		Services: map[string]proxyRoute{
+			"tenant_admin": {Service: "tenant_admin", TargetAddr: "10.0.0.1:6432"},
+		},
+		Rules: []proxyRouteRule{
+			{Database: "tenant_*", Service: "tenants-shard", TargetAddr: "10.0.0.5:6432"},
+			{User: "reporting", Service: "tenants-shard", Role: "async", TargetAddr: "10.0.0.6:6432"},
+		},
+	}); err != nil {
+		t.Fatalf("writeProxyRoutes error: %v", err)
+	}
+
+	router := newProxyRouter(routesPath)
+	route, err := router.route(startupRequest{Database: "tenant_admin"})
+	if err != nil || route.TargetAddr != "10.0.0.1:6432" {
+		t.Fatalf("route(tenant_admin) = %+v, %v", route, err)
+	}
+	route, err = router.route(startupRequest{Database: "tenant_17"})
+	if err != nil || route.TargetAddr != "10.0.0.5:6432" || route.Service != "tenants-shard" {
+		t.Fatalf("route(tenant_17) = %+v, %v", route, err)
+	}
+	route, err = router.route(startupRequest{Database: "tenant_admin", User: "reporting"})
+	if err != nil || route.TargetAddr != "10.0.0.6:6432" {
+		t.Fatalf("route(tenant_admin, user=reporting) = %+v, %v", route, err)
+	}
+	if _, err := router.route(startupRequest{Database: "other"}); err == nil || !strings.Contains(err.Error(), "is not configured") {
+		t.Fatalf("route(other) error=%v, want not configured", err)
+	}
+}
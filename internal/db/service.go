@@ -1,7 +1,6 @@
 package db
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -25,9 +24,22 @@ const (
 	defaultPreferRole             = "master"
 )
 
+// DefaultConfig returns the db values applyDefaults fills in when a config
+// leaves them empty.
+func DefaultConfig() config.Config {
+	var cfg config.Config
+	cfg.DB.ServiceDiscoveryScheme = defaultServiceDiscoveryScheme
+	cfg.DB.EndpointMask = defaultEndpointMask
+	cfg.DB.LocalHost = defaultLocalHost
+	cfg.DB.LocalPort = defaultLocalPort
+	cfg.DB.PreferRole = defaultPreferRole
+	return cfg
+}
+
 // Service manages service-discovery-driven local DB proxy flow.
 type Service struct {
-	rt appruntime.Runtime
+	rt      appruntime.Runtime
+	answers map[string]string
 }
 
 // NewService builds a Service.
@@ -35,6 +47,20 @@ func NewService(rt appruntime.Runtime) Service {
 	return Service{rt: rt}
 }
 
+// WithAnswers returns s with prompts answered by command flags, keyed by
+// flag name: provider, service-discovery, dns-server, discovery-file, mask,
+// fallback-hosts, host-order, auth, token, token-file, token-command,
+// auth-user, password, password-file, client-cert, client-key, ca-file,
+// port and role.
+func (s Service) WithAnswers(answers map[string]string) Service {
+	s.answers = answers
+	return s
+}
+
+func (s Service) prompter() *cli.Prompter {
+	return s.rt.Prompter(s.answers)
+}
+
 // Init configures Service discovery base URL/mask and local proxy settings.
 func (s Service) Init(force bool) error {
 	if err := s.checkSupported(); err != nil {
@@ -47,50 +73,32 @@ func (s Service) Init(force bool) error {
 	}
 	s.applyDefaults(&cfg)
 
-	if !force && hasCfg && strings.TrimSpace(cfg.DB.ServiceDiscoveryHost) != "" {
-		fmt.Println("db service discovery is already configured:", serviceDiscoveryCurrent(&cfg))
-		fmt.Println("db endpoint mask:", cfg.DB.EndpointMask)
+	if !force && len(s.answers) == 0 && hasCfg && ensureServiceDiscoveryConfigured(cfg) == nil {
+		fmt.Println("db service discovery is already configured:", serviceDiscoveryLabel(cfg))
+		printDiscoveryMask(cfg)
 		fmt.Printf("db local address: %s:%d\n", cfg.DB.LocalHost, cfg.DB.LocalPort)
 		fmt.Println("use `db init --force` to reconfigure")
 		return nil
 	}
 
-	pr := cli.NewPrompter(os.Stdin, os.Stdout)
-	serviceDiscoveryInput, err := pr.AskString("Service discovery URL (host or full endpoint URL)", "", serviceDiscoveryCurrent(&cfg), validateServiceDiscoveryInput)
+	pr := s.prompter()
+	provider, err := pr.Ask(
+		"provider",
+		"Service discovery provider (http/consul/dns/file)",
+		DiscoveryProviderHTTP,
+		cfg.DB.DiscoveryProvider,
+		validateDiscoveryProvider,
+	)
 	if err != nil {
 		return err
 	}
-
-	scheme, host, err := NormalizeServiceDiscoveryInput(serviceDiscoveryInput)
-	if err != nil {
+	cfg.DB.DiscoveryProvider = strings.ToLower(strings.TrimSpace(provider))
+	if err := s.promptDiscovery(pr, &cfg); err != nil {
 		return err
 	}
-	cfg.DB.ServiceDiscoveryScheme = scheme
-	cfg.DB.ServiceDiscoveryHost = host
 
-	if mask, ok, err := ExtractEndpointMaskFromServiceDiscoveryInput(serviceDiscoveryInput); err != nil {
-		return err
-	} else if ok {
-		cfg.DB.EndpointMask = mask
-		fmt.Println("db endpoint mask derived:", mask)
-	} else {
-		maskInput, err := pr.AskString(
-			"Service discovery endpoint mask",
-			defaultEndpointMask,
-			cfg.DB.EndpointMask,
-			validateEndpointMask,
-		)
-		if err != nil {
-			return err
-		}
-		normalizedMask, err := NormalizeEndpointMask(maskInput)
-		if err != nil {
-			return err
-		}
-		cfg.DB.EndpointMask = normalizedMask
-	}
-
-	portStr, err := pr.AskString(
+	portStr, err := pr.Ask(
+		"port",
 		"Local proxy port",
 		strconv.Itoa(defaultLocalPort),
 		strconv.Itoa(cfg.DB.LocalPort),
@@ -102,7 +110,8 @@ func (s Service) Init(force bool) error {
 	port, _ := strconv.Atoi(strings.TrimSpace(portStr))
 	cfg.DB.LocalPort = port
 
-	role, err := pr.AskString(
+	role, err := pr.Ask(
+		"role",
 		"Preferred endpoint role (master/sync/async/any)",
 		defaultPreferRole,
 		cfg.DB.PreferRole,
@@ -113,17 +122,249 @@ func (s Service) Init(force bool) error {
 	}
 	cfg.DB.PreferRole = strings.ToLower(strings.TrimSpace(role))
 
-	cfg.DB.ServiceDiscoveryURL = ""
-	if err := config.Save(s.rt.Paths.ConfigPath, cfg); err != nil {
+	if err := s.saveConfig(cfg); err != nil {
 		return err
 	}
 
-	fmt.Println("db service discovery configured:", serviceDiscoveryCurrent(&cfg))
-	fmt.Println("db endpoint mask:", cfg.DB.EndpointMask)
+	fmt.Println("db service discovery configured:", serviceDiscoveryLabel(cfg))
+	printDiscoveryMask(cfg)
 	fmt.Printf("db local address: %s:%d\n", cfg.DB.LocalHost, cfg.DB.LocalPort)
 	return nil
 }
 
+// promptDiscovery asks for the settings of the selected discovery provider.
+func (s Service) promptDiscovery(pr *cli.Prompter, cfg *config.Config) error {
+	switch cfg.DB.DiscoveryProvider {
+	case DiscoveryProviderConsul:
+		input, err := pr.Ask("service-discovery", "Consul address (host[:port] or URL)", "127.0.0.1:8500", serviceDiscoveryCurrent(cfg), validateServiceDiscoveryInput)
+		if err != nil {
+			return err
+		}
+		scheme, host, err := NormalizeServiceDiscoveryInput(input)
+		if err != nil {
+			return err
+		}
+		cfg.DB.ServiceDiscoveryScheme = scheme
+		cfg.DB.ServiceDiscoveryHost = host
+		if err := s.promptServiceNameMask(pr, cfg, "Consul service name mask", defaultConsulServiceMask); err != nil {
+			return err
+		}
+		return s.promptDiscoveryHTTPOptions(pr, cfg)
+	case DiscoveryProviderDNS:
+		server, err := pr.Ask("dns-server", "DNS server (host[:port] or `system`)", "system", cfg.DB.ServiceDiscoveryHost, validateDNSServer)
+		if err != nil {
+			return err
+		}
+		server = strings.TrimSpace(server)
+		if server == "system" {
+			server = ""
+		}
+		cfg.DB.ServiceDiscoveryHost = server
+		return s.promptServiceNameMask(pr, cfg, "DNS SRV name mask", defaultDNSServiceMask)
+	case DiscoveryProviderFile:
+		path, err := pr.Ask("discovery-file", "Static endpoints file (JSON)", "", cfg.DB.DiscoveryFile, validateReadableFile)
+		if err != nil {
+			return err
+		}
+		abs, err := filepath.Abs(strings.TrimSpace(path))
+		if err != nil {
+			return err
+		}
+		cfg.DB.DiscoveryFile = abs
+		return nil
+	}
+
+	serviceDiscoveryInput, err := pr.Ask("service-discovery", "Service discovery URL (host or full endpoint URL)", "", serviceDiscoveryCurrent(cfg), validateServiceDiscoveryInput)
+	if err != nil {
+		return err
+	}
+
+	scheme, host, err := NormalizeServiceDiscoveryInput(serviceDiscoveryInput)
+	if err != nil {
+		return err
+	}
+	cfg.DB.ServiceDiscoveryScheme = scheme
+	cfg.DB.ServiceDiscoveryHost = host
+
+	if mask, ok, err := ExtractEndpointMaskFromServiceDiscoveryInput(serviceDiscoveryInput); err != nil {
+		return err
+	} else if ok {
+		cfg.DB.EndpointMask = mask
+		fmt.Println("db endpoint mask derived:", mask)
+		return s.promptDiscoveryHTTPOptions(pr, cfg)
+	}
+
+	maskInput, err := pr.Ask(
+		"mask",
+		"Service discovery endpoint mask",
+		defaultEndpointMask,
+		cfg.DB.EndpointMask,
+		validateEndpointMask,
+	)
+	if err != nil {
+		return err
+	}
+	normalizedMask, err := NormalizeEndpointMask(maskInput)
+	if err != nil {
+		return err
+	}
+	cfg.DB.EndpointMask = normalizedMask
+	return s.promptDiscoveryHTTPOptions(pr, cfg)
+}
+
+// promptDiscoveryHTTPOptions asks for fallback hosts and auth shared by the
+// HTTP-based providers.
+func (s Service) promptDiscoveryHTTPOptions(pr *cli.Prompter, cfg *config.Config) error {
+	current := strings.Join(cfg.DB.ServiceDiscoveryHosts, ",")
+	hostsInput, err := pr.Ask("fallback-hosts", "Fallback service discovery hosts (comma-separated or `none`)", "none", current, validateDiscoveryHosts)
+	if err != nil {
+		return err
+	}
+	hosts, err := parseDiscoveryHosts(hostsInput)
+	if err != nil {
+		return err
+	}
+	cfg.DB.ServiceDiscoveryHosts = hosts
+	if len(hosts) > 0 {
+		order, err := pr.Ask("host-order", "Discovery host order (ordered/random)", DiscoveryHostOrderOrdered, cfg.DB.DiscoveryHostOrder, validateDiscoveryHostOrder)
+		if err != nil {
+			return err
+		}
+		cfg.DB.DiscoveryHostOrder = strings.ToLower(strings.TrimSpace(order))
+	}
+	return s.promptDiscoveryAuth(pr, cfg)
+}
+
+// promptDiscoveryAuth asks how HTTP discovery requests authenticate. Entered
+// secrets are saved in the secret store; config keeps secret:// references.
+func (s Service) promptDiscoveryAuth(pr *cli.Prompter, cfg *config.Config) error {
+	auth := &cfg.DB.DiscoveryAuth
+	method, err := pr.Ask(
+		"auth",
+		"Service discovery auth (none/bearer/basic/mtls)",
+		DiscoveryAuthNone,
+		auth.Method,
+		validateDiscoveryAuthMethod,
+	)
+	if err != nil {
+		return err
+	}
+	method = strings.ToLower(strings.TrimSpace(method))
+	auth.Method = method
+
+	switch method {
+	case DiscoveryAuthBearer:
+		var source string
+		switch {
+		case pr.Has("token-file"):
+			source = "file"
+		case pr.Has("token-command"):
+			source = "command"
+		case pr.Has("token"):
+			source = "paste"
+		default:
+			var err error
+			if source, err = pr.AskString("Bearer token source (paste/file/command)", "paste", "", validateTokenSource); err != nil {
+				return err
+			}
+		}
+		auth.Token, auth.TokenFile, auth.TokenCommand = "", "", ""
+		switch strings.ToLower(strings.TrimSpace(source)) {
+		case "file":
+			path, err := pr.Ask("token-file", "Bearer token file", "", "", validateReadableFile)
+			if err != nil {
+				return err
+			}
+			if auth.TokenFile, err = filepath.Abs(strings.TrimSpace(path)); err != nil {
+				return err
+			}
+		case "command":
+			command, err := pr.Ask("token-command", "Bearer token command", "", "", validateNotEmpty)
+			if err != nil {
+				return err
+			}
+			auth.TokenCommand = strings.TrimSpace(command)
+		default:
+			token, err := pr.Ask("token", "Bearer token", "", "", validateNotEmpty)
+			if err != nil {
+				return err
+			}
+			if auth.Token, err = storeSecret(s.rt.Paths.SecretStoreDir, discoveryTokenSecret, token); err != nil {
+				return err
+			}
+		}
+	case DiscoveryAuthBasic:
+		user, err := pr.Ask("auth-user", "Basic auth user", "", auth.Username, validateNotEmpty)
+		if err != nil {
+			return err
+		}
+		auth.Username = strings.TrimSpace(user)
+		auth.Password, auth.PasswordFile = "", ""
+		if pr.Has("password-file") {
+			path, err := pr.Ask("password-file", "Basic auth password file", "", "", validateReadableFile)
+			if err != nil {
+				return err
+			}
+			if auth.PasswordFile, err = filepath.Abs(strings.TrimSpace(path)); err != nil {
+				return err
+			}
+			break
+		}
+		password, err := pr.Ask("password", "Basic auth password", "", "", validateNotEmpty)
+		if err != nil {
+			return err
+		}
+		if auth.Password, err = storeSecret(s.rt.Paths.SecretStoreDir, discoveryPasswordSecret, password); err != nil {
+			return err
+		}
+	case DiscoveryAuthMTLS:
+		certPath, err := pr.Ask("client-cert", "Client certificate file (PEM)", "", auth.ClientCert, validateReadableFile)
+		if err != nil {
+			return err
+		}
+		keyPath, err := pr.Ask("client-key", "Client key file (PEM)", "", auth.ClientKey, validateReadableFile)
+		if err != nil {
+			return err
+		}
+		if auth.ClientCert, err = filepath.Abs(strings.TrimSpace(certPath)); err != nil {
+			return err
+		}
+		if auth.ClientKey, err = filepath.Abs(strings.TrimSpace(keyPath)); err != nil {
+			return err
+		}
+	}
+
+	if cfg.DB.ServiceDiscoveryScheme == "https" {
+		ca, err := pr.Ask("ca-file", "CA bundle file (PEM or `system`)", "system", auth.CAFile, validateCAFile)
+		if err != nil {
+			return err
+		}
+		auth.CAFile = ""
+		if ca = strings.TrimSpace(ca); ca != "system" {
+			if auth.CAFile, err = filepath.Abs(ca); err != nil {
+				return err
+			}
+		}
+	}
+	if auth.Method == DiscoveryAuthNone {
+		*auth = config.DBDiscoveryAuth{Headers: auth.Headers, CAFile: auth.CAFile}
+	}
+	return validateDiscoveryAuth(*auth)
+}
+
+func (s Service) promptServiceNameMask(pr *cli.Prompter, cfg *config.Config, label, def string) error {
+	current := cfg.DB.DiscoveryServiceMask
+	if validateServiceNameMask(current) != nil {
+		current = ""
+	}
+	mask, err := pr.Ask("mask", label, def, current, validateServiceNameMask)
+	if err != nil {
+		return err
+	}
+	cfg.DB.DiscoveryServiceMask = strings.TrimSpace(mask)
+	return nil
+}
+
 // Start resolves all configured services and starts local proxy for them.
 func (s Service) Start(force bool) error {
 	if err := s.checkSupported(); err != nil {
@@ -138,8 +379,8 @@ func (s Service) Start(force bool) error {
 	if err := ensureServiceDiscoveryConfigured(cfg); err != nil {
 		return fmt.Errorf("%w (run `db init` first)", err)
 	}
-	if len(cfg.DB.ServiceNames) == 0 {
-		return fmt.Errorf("no database services configured (use `db add <service>`)")
+	if !hasProxyRoutes(cfg) {
+		return fmt.Errorf("no database services configured (use `db add <service>`, `db route add` or `db auto on`)")
 	}
 
 	if force {
@@ -148,8 +389,9 @@ func (s Service) Start(force bool) error {
 		}
 	}
 
-	for _, service := range cfg.DB.ServiceNames {
-		endpointURL, ep, err := s.resolveEndpoint(cfg, service)
+	for _, target := range routeTargets(cfg) {
+		service := target.Service
+		endpointURL, ep, err := s.resolveTargetEndpoint(cfg, target)
 		if err != nil {
 			return fmt.Errorf("service %q validation via service discovery failed: %w", service, err)
 		}
@@ -157,19 +399,20 @@ func (s Service) Start(force bool) error {
 			return fmt.Errorf("service %q endpoint is unreachable (%s): %w", service, ep.Address, err)
 		}
 
-		setServiceValue(&cfg.DB.ServiceTargets, service, ep.Address)
-		setServiceValue(&cfg.DB.ServiceInstances, service, ep.InstanceName)
-		fmt.Printf("service %s -> %s (%s)\n", service, ep.Address, endpointURL)
+		setServiceValue(&cfg.DB.ServiceTargets, target.key(), ep.Address)
+		setServiceValue(&cfg.DB.ServiceInstances, target.key(), ep.InstanceName)
+		fmt.Printf("service %s -> %s (%s)\n", target.key(), ep.Address, endpointURL)
 	}
 
-	if strings.TrimSpace(cfg.DB.ServiceName) == "" {
+	s.refreshEnvironments(&cfg)
+
+	if strings.TrimSpace(cfg.DB.ServiceName) == "" && len(cfg.DB.ServiceNames) > 0 {
 		cfg.DB.ServiceName = cfg.DB.ServiceNames[0]
 	}
 	cfg.DB.TargetAddress = getServiceValue(cfg.DB.ServiceTargets, cfg.DB.ServiceName)
 	cfg.DB.TargetInstance = getServiceValue(cfg.DB.ServiceInstances, cfg.DB.ServiceName)
-	cfg.DB.ServiceDiscoveryURL = ""
 
-	if err := config.Save(s.rt.Paths.ConfigPath, cfg); err != nil {
+	if err := s.saveConfig(cfg); err != nil {
 		return err
 	}
 	if err := s.writeProxyRoutesFile(cfg); err != nil {
@@ -189,9 +432,20 @@ func (s Service) Start(force bool) error {
 
 // AddService appends a database service name, validates endpoint, and makes it available immediately.
 func (s Service) AddService(serviceArg string) error {
+	return s.AddServiceWithSelector(serviceArg, EndpointSelector{})
+}
+
+// AddServiceWithSelector is AddService with a release/version selector that
+// is persisted for the service and filters its endpoints before role selection.
+func (s Service) AddServiceWithSelector(serviceArg string, selector EndpointSelector) error {
 	if err := s.checkSupported(); err != nil {
 		return err
 	}
+	if v := strings.TrimSpace(selector.Version); v != "" && !strings.EqualFold(v, selectorAny) {
+		if err := validateVersionConstraints(v); err != nil {
+			return fmt.Errorf("--version: %w", err)
+		}
+	}
 
 	cfg, _, err := s.loadConfig()
 	if err != nil {
@@ -203,19 +457,22 @@ func (s Service) AddService(serviceArg string) error {
 	}
 
 	service := strings.TrimSpace(serviceArg)
+	if service == "" && s.rt.NonInteractive {
+		return fmt.Errorf("missing <service> argument: %w", cli.ErrNonInteractive)
+	}
 	if service == "" {
-		pr := cli.NewPrompter(os.Stdin, os.Stdout)
-		prompted, err := pr.AskString("Database service name", "", cfg.DB.ServiceName, validateServiceName)
+		prompted, err := s.promptService(cfg)
 		if err != nil {
 			return err
 		}
-		service = strings.TrimSpace(prompted)
+		service = prompted
 	}
 	if err := validateServiceName(service); err != nil {
 		return err
 	}
+	applyServiceSelector(&cfg, service, selector)
 
-	endpointURL, ep, err := s.resolveEndpoint(cfg, service)
+	endpointURL, ep, err := s.resolveTargetEndpoint(cfg, routeTarget{Service: service})
 	if err != nil {
 		return fmt.Errorf("service %q validation via service discovery failed: %w", service, err)
 	}
@@ -229,9 +486,8 @@ func (s Service) AddService(serviceArg string) error {
 	setServiceValue(&cfg.DB.ServiceInstances, service, ep.InstanceName)
 	cfg.DB.TargetAddress = ep.Address
 	cfg.DB.TargetInstance = ep.InstanceName
-	cfg.DB.ServiceDiscoveryURL = ""
 
-	if err := config.Save(s.rt.Paths.ConfigPath, cfg); err != nil {
+	if err := s.saveConfig(cfg); err != nil {
 		return err
 	}
 	if err := s.writeProxyRoutesFile(cfg); err != nil {
@@ -248,6 +504,9 @@ func (s Service) AddService(serviceArg string) error {
 	if ep.InstanceName != "" {
 		fmt.Println("db selected instance:", ep.InstanceName)
 	}
+	if sel := serviceSelector(cfg, service); !sel.IsZero() {
+		fmt.Println("db endpoint selector:", sel)
+	}
 	fmt.Println("db endpoint connectivity: ok")
 	fmt.Println("db local address:", listenAddr)
 	fmt.Printf("jdbc url: jdbc:postgresql://%s/%s\n", listenAddr, service)
@@ -255,6 +514,132 @@ func (s Service) AddService(serviceArg string) error {
 	return nil
 }
 
+// promptService asks for a database service name. When the provider has a
+// catalog, its services are offered as a numbered list.
+func (s Service) promptService(cfg config.Config) (string, error) {
+	pr := s.prompter()
+	_, services, err := remoteCatalog(cfg.DB, "")
+	if err != nil && !errors.Is(err, errNoCatalog) {
+		fmt.Println("db remote catalog unavailable:", err)
+	}
+	if err != nil || len(services) == 0 {
+		prompted, err := pr.AskString("Database service name", "", cfg.DB.ServiceName, validateServiceName)
+		return strings.TrimSpace(prompted), err
+	}
+
+	shown := services[:min(len(services), maxCatalogChoices)]
+	fmt.Println("Remote services:")
+	for i, svc := range shown {
+		fmt.Printf("%d. %s\n", i+1, catalogServiceLabel(svc))
+	}
+	if rest := len(services) - len(shown); rest > 0 {
+		fmt.Printf("... %d more (use `db search <substring>`)\n", rest)
+	}
+	validate := func(v string) error {
+		_, err := pickCatalogService(shown, v)
+		return err
+	}
+	answer, err := pr.AskString("Database service (number or name)", "", cfg.DB.ServiceName, validate)
+	if err != nil {
+		return "", err
+	}
+	return pickCatalogService(shown, answer)
+}
+
+// ListRemote prints the services listed by the Service discovery catalog
+// whose names contain filter.
+func (s Service) ListRemote(filter string) error {
+	if err := s.checkSupported(); err != nil {
+		return err
+	}
+
+	cfg, _, err := s.loadConfig()
+	if err != nil {
+		return err
+	}
+	s.applyDefaults(&cfg)
+	if err := ensureServiceDiscoveryConfigured(cfg); err != nil {
+		return fmt.Errorf("%w (run `db init` first)", err)
+	}
+
+	source, services, err := remoteCatalog(cfg.DB, filter)
+	if err != nil {
+		return fmt.Errorf("list remote services: %w", err)
+	}
+	fmt.Println("Catalog:", source)
+	if len(services) == 0 {
+		if strings.TrimSpace(filter) != "" {
+			fmt.Printf("no remote services match %q\n", filter)
+		} else {
+			fmt.Println("no remote services found")
+		}
+		return nil
+	}
+	for _, svc := range services {
+		line := catalogServiceLabel(svc)
+		if containsServiceName(cfg.DB.ServiceNames, svc.Name) {
+			line += " [added]"
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// Endpoints prints every endpoint Service discovery returns for service with
+// the pick of ChooseEndpoint per role and TCP reachability.
+func (s Service) Endpoints(serviceArg string) error {
+	if err := s.checkSupported(); err != nil {
+		return err
+	}
+
+	service := strings.TrimSpace(serviceArg)
+	if err := validateServiceName(service); err != nil {
+		return fmt.Errorf("service name: %w", err)
+	}
+
+	cfg, _, err := s.loadConfig()
+	if err != nil {
+		return err
+	}
+	s.applyDefaults(&cfg)
+	if err := ensureServiceDiscoveryConfigured(cfg); err != nil {
+		return fmt.Errorf("%w (run `db init` first)", err)
+	}
+
+	provider, err := NewDiscoveryProvider(cfg.DB)
+	if err != nil {
+		return err
+	}
+	source, endpoints, err := provider.Resolve(service)
+	if err != nil {
+		return fmt.Errorf("service %q lookup via service discovery failed: %w", service, err)
+	}
+
+	fmt.Println("Service:", service)
+	fmt.Println("Source:", source)
+	fmt.Println("Preferred role:", emptyIf(cfg.DB.PreferRole))
+	if len(endpoints) == 0 {
+		fmt.Println("no endpoints returned")
+		return nil
+	}
+	candidates := endpoints
+	if sel := serviceSelector(cfg, service); !sel.IsZero() {
+		fmt.Println("Selector:", sel)
+		if candidates, err = selectEndpoints(endpoints, sel); err != nil {
+			fmt.Println("Selector matches no endpoint:", err)
+		}
+	}
+	picks := endpointPicks(endpoints, candidates, endpointReportRoles)
+	checks := checkEndpointsConnectivity(endpoints, defaultConnectivityTimeout)
+	if err := writeEndpointReport(os.Stdout, endpoints, picks, checks); err != nil {
+		return err
+	}
+	if ep, err := ChooseEndpoint(candidates, cfg.DB.PreferRole); err == nil {
+		fmt.Printf("Selected for preferred role: %s (%s)\n", ep.Address, emptyIf(ep.InstanceName))
+	}
+	return nil
+}
+
 // RemoveService removes a database service and refreshes/stops local proxy.
 func (s Service) RemoveService(serviceArg string) error {
 	if err := s.checkSupported(); err != nil {
@@ -281,8 +666,14 @@ func (s Service) RemoveService(serviceArg string) error {
 		return nil
 	}
 	cfg.DB.ServiceNames = updated
-	deleteServiceValue(cfg.DB.ServiceTargets, service)
-	deleteServiceValue(cfg.DB.ServiceInstances, service)
+	deleteServiceValue(cfg.DB.PinnedInstances, service)
+	deleteServiceValue(cfg.DB.PinnedAddresses, service)
+	deleteServiceValue(cfg.DB.ServiceReleases, service)
+	deleteServiceValue(cfg.DB.ServiceVersions, service)
+	if !containsServiceName(routeTargetKeys(cfg), service) {
+		deleteServiceValue(cfg.DB.ServiceTargets, service)
+		deleteServiceValue(cfg.DB.ServiceInstances, service)
+	}
 
 	if strings.EqualFold(cfg.DB.ServiceName, service) {
 		cfg.DB.ServiceName = ""
@@ -295,11 +686,11 @@ func (s Service) RemoveService(serviceArg string) error {
 		}
 	}
 
-	if err := config.Save(s.rt.Paths.ConfigPath, cfg); err != nil {
+	if err := s.saveConfig(cfg); err != nil {
 		return err
 	}
 
-	if len(cfg.DB.ServiceNames) == 0 {
+	if !hasProxyRoutes(cfg) {
 		if err := StopProxyDaemon(DefaultProxyFiles(s.rt)); err != nil {
 			return err
 		}
@@ -320,6 +711,176 @@ func (s Service) RemoveService(serviceArg string) error {
 	return nil
 }
 
+// SetAutoResolve toggles on-demand resolution of unknown database names by the proxy daemon.
+// An empty pattern keeps the current one; `*` clears it so every name matches.
+func (s Service) SetAutoResolve(enabled bool, pattern string) error {
+	if err := s.checkSupported(); err != nil {
+		return err
+	}
+	if err := validateAutoResolvePattern(pattern); err != nil {
+		return err
+	}
+
+	cfg, _, err := s.loadConfig()
+	if err != nil {
+		return err
+	}
+	s.applyDefaults(&cfg)
+	if enabled {
+		if err := ensureServiceDiscoveryConfigured(cfg); err != nil {
+			return fmt.Errorf("%w (run `db init` first)", err)
+		}
+	}
+
+	cfg.DB.AutoResolve = enabled
+	cfg.DB.AutoResolvePattern = nextAutoResolvePattern(cfg.DB.AutoResolvePattern, pattern)
+	if err := s.saveConfig(cfg); err != nil {
+		return err
+	}
+
+	if IsProxyRunning(s.rt.Paths.DBProxyPIDFile) {
+		if !hasProxyRoutes(cfg) {
+			if err := StopProxyDaemon(DefaultProxyFiles(s.rt)); err != nil {
+				return err
+			}
+			_ = os.Remove(s.proxyRoutesPath())
+		} else if err := s.writeProxyRoutesFile(cfg); err != nil {
+			return err
+		}
+	} else if enabled {
+		fmt.Println("db proxy is not running (use `db start`)")
+	}
+
+	fmt.Println("db auto resolve:", autoResolveLabel(cfg))
+	return nil
+}
+
+// AddRouteRule inserts a route rule at index (0-based; negative appends).
+// With a service the target is resolved now; without one the proxy resolves
+// each matching database via the endpoint mask on first use.
+func (s Service) AddRouteRule(rule config.DBRouteRule, index int) error {
+	if err := s.checkSupported(); err != nil {
+		return err
+	}
+	normalized := normalizeRouteRules([]config.DBRouteRule{rule})
+	if len(normalized) == 0 {
+		return validateRouteRule(rule)
+	}
+	rule = normalized[0]
+	if err := validateRouteRule(rule); err != nil {
+		return err
+	}
+
+	cfg, _, err := s.loadConfig()
+	if err != nil {
+		return err
+	}
+	s.applyDefaults(&cfg)
+	if err := ensureServiceDiscoveryConfigured(cfg); err != nil {
+		return fmt.Errorf("%w (run `db init` first)", err)
+	}
+
+	if service := rule.Service; service != "" {
+		target := ruleTarget(rule)
+		endpointURL, ep, err := s.resolveTargetEndpoint(cfg, target)
+		if err != nil {
+			return fmt.Errorf("service %q validation via service discovery failed: %w", service, err)
+		}
+		if err := CheckTCPConnectivity(ep.Address, defaultConnectivityTimeout); err != nil {
+			return fmt.Errorf("service %q endpoint is unreachable (%s): %w", service, ep.Address, err)
+		}
+		setServiceValue(&cfg.DB.ServiceTargets, target.key(), ep.Address)
+		setServiceValue(&cfg.DB.ServiceInstances, target.key(), ep.InstanceName)
+		fmt.Printf("service %s -> %s (%s)\n", target.key(), ep.Address, endpointURL)
+	}
+	cfg.DB.RouteRules = insertRouteRule(cfg.DB.RouteRules, rule, index)
+
+	if err := s.saveConfig(cfg); err != nil {
+		return err
+	}
+	if err := s.writeProxyRoutesFile(cfg); err != nil {
+		return err
+	}
+	if err := s.ensureProxyRunning(cfg); err != nil {
+		return err
+	}
+
+	fmt.Println("db route rule added:", routeRuleLabel(rule))
+	s.printRouteRules(cfg)
+	return nil
+}
+
+// RemoveRouteRule removes a route rule, given by its 1-based position or its
+// database pattern, and refreshes/stops local proxy.
+func (s Service) RemoveRouteRule(ref string) error {
+	if err := s.checkSupported(); err != nil {
+		return err
+	}
+
+	cfg, _, err := s.loadConfig()
+	if err != nil {
+		return err
+	}
+	s.applyDefaults(&cfg)
+
+	updated, removed := removeRouteRule(cfg.DB.RouteRules, ref)
+	if !removed {
+		fmt.Println("db route rule not found:", strings.TrimSpace(ref))
+		return nil
+	}
+	cfg.DB.RouteRules = updated
+	s.applyDefaults(&cfg)
+
+	if err := s.saveConfig(cfg); err != nil {
+		return err
+	}
+	if !hasProxyRoutes(cfg) {
+		if err := StopProxyDaemon(DefaultProxyFiles(s.rt)); err != nil {
+			return err
+		}
+		_ = os.Remove(s.proxyRoutesPath())
+	} else {
+		if err := s.writeProxyRoutesFile(cfg); err != nil {
+			return err
+		}
+		if IsProxyRunning(s.rt.Paths.DBProxyPIDFile) {
+			if err := s.ensureProxyRunning(cfg); err != nil {
+				return err
+			}
+		}
+	}
+
+	fmt.Println("db route rule removed:", strings.TrimSpace(ref))
+	s.printRouteRules(cfg)
+	return nil
+}
+
+// ListRouteRules prints pattern routes in precedence order.
+func (s Service) ListRouteRules() error {
+	if err := s.checkSupported(); err != nil {
+		return err
+	}
+
+	cfg, _, err := s.loadConfig()
+	if err != nil {
+		return err
+	}
+	s.applyDefaults(&cfg)
+	s.printRouteRules(cfg)
+	return nil
+}
+
+func (s Service) printRouteRules(cfg config.Config) {
+	if len(cfg.DB.RouteRules) == 0 {
+		fmt.Println("db route rules: (none)")
+		return
+	}
+	fmt.Println("db route rules (user/application_name rules, then exact services, then database rules; first match wins):")
+	for i, rule := range cfg.DB.RouteRules {
+		fmt.Printf("%d. %s\n", i+1, routeRuleLabel(rule))
+	}
+}
+
 // Stop stops local proxy daemon.
 func (s Service) Stop() error {
 	if err := s.checkSupported(); err != nil {
@@ -351,24 +912,58 @@ func (s Service) Status() error {
 	s.applyDefaults(&cfg)
 
 	fmt.Println("Config:", s.rt.Paths.ConfigPath)
-	fmt.Println("Service discovery scheme:", emptyIf(cfg.DB.ServiceDiscoveryScheme))
-	fmt.Println("Service discovery host:", emptyIf(cfg.DB.ServiceDiscoveryHost))
-	fmt.Println("Endpoint mask:", emptyIf(cfg.DB.EndpointMask))
+	fmt.Println("Environment:", activeEnvironment(cfg))
+	fmt.Println("Service discovery provider:", cfg.DB.DiscoveryProvider)
+	switch cfg.DB.DiscoveryProvider {
+	case DiscoveryProviderHTTP:
+		fmt.Println("Service discovery scheme:", emptyIf(cfg.DB.ServiceDiscoveryScheme))
+		fmt.Println("Service discovery host:", emptyIf(cfg.DB.ServiceDiscoveryHost))
+		printDiscoveryFallbackHosts(cfg)
+		fmt.Println("Endpoint mask:", emptyIf(cfg.DB.EndpointMask))
+		fmt.Println("Catalog mask:", emptyIf(cfg.DB.CatalogMask))
+		fmt.Println("Response mapping:", endpointMappingLabel(cfg.DB.EndpointMapping))
+		fmt.Println("Service discovery auth:", discoveryAuthLabel(cfg.DB.DiscoveryAuth))
+	case DiscoveryProviderConsul:
+		fmt.Println("Consul address:", emptyIf(serviceDiscoveryCurrent(&cfg)))
+		printDiscoveryFallbackHosts(cfg)
+		fmt.Println("Service name mask:", emptyIf(cfg.DB.DiscoveryServiceMask))
+		fmt.Println("Service discovery auth:", discoveryAuthLabel(cfg.DB.DiscoveryAuth))
+	case DiscoveryProviderDNS:
+		fmt.Println("DNS server:", serviceDiscoveryLabel(cfg))
+		fmt.Println("Service name mask:", emptyIf(cfg.DB.DiscoveryServiceMask))
+	case DiscoveryProviderFile:
+		fmt.Println("Endpoints file:", emptyIf(cfg.DB.DiscoveryFile))
+	}
+	fmt.Println("Discovery cache:", s.discoveryCachePath(cfg), "(ttl "+discoveryCacheTTLLabel(cfg.DB)+")")
+	if last, ok := latestDiscoveryAnswer(s.discoveryCachePath(cfg)); ok && last.Host != "" {
+		fmt.Printf("Service discovery last answer: %s (%s at %s)\n", last.Host, last.Service, last.FetchedAt.Local().Format(time.RFC3339))
+	}
 	fmt.Println("Preferred role:", emptyIf(cfg.DB.PreferRole))
 	fmt.Printf("Local address: %s:%d\n", cfg.DB.LocalHost, cfg.DB.LocalPort)
+	fmt.Println("Auto resolve:", autoResolveLabel(cfg))
 	fmt.Println("Active service:", emptyIf(cfg.DB.ServiceName))
 	fmt.Println("Services:", servicesLabel(cfg.DB.ServiceNames))
+	if len(cfg.DB.RouteRules) > 0 {
+		fmt.Println("Route rules:")
+		for i, rule := range cfg.DB.RouteRules {
+			fmt.Printf("%d. %s", i+1, routeRuleLabel(rule))
+			if rule.Service != "" {
+				fmt.Printf(" [%s]", emptyIf(getServiceValue(cfg.DB.ServiceTargets, ruleTarget(rule).key())))
+			}
+			fmt.Println()
+		}
+	}
 
 	if len(cfg.DB.ServiceNames) > 0 {
 		fmt.Println("Service endpoints:")
-		for _, service := range cfg.DB.ServiceNames {
-			target := getServiceValue(cfg.DB.ServiceTargets, service)
-			instance := getServiceValue(cfg.DB.ServiceInstances, service)
-			fmt.Printf("- %s -> %s", service, emptyIf(target))
-			if instance != "" {
-				fmt.Printf(" (%s)", instance)
-			}
-			fmt.Println()
+		printServiceEndpoints(cfg, "")
+	}
+	if names := environmentNames(cfg); len(names) > 0 {
+		fmt.Println("Other environments:")
+		for _, name := range names {
+			envCfg := environmentConfig(cfg, name)
+			fmt.Printf("- %s: %s\n", name, serviceDiscoveryLabel(envCfg))
+			printServiceEndpoints(envCfg, "  ")
 		}
 	}
 
@@ -409,47 +1004,52 @@ func (s Service) checkSupported() error {
 	return nil
 }
 
+// loadConfig resolves the layered config (files, WSLBRIDGE_* env vars and
+// --set flags).
 func (s Service) loadConfig() (config.Config, bool, error) {
-	c, err := config.Load(s.rt.Paths.ConfigPath)
-	if err == nil {
-		return c, true, nil
+	res, err := s.rt.ResolveConfig(config.Config{})
+	if err != nil {
+		return config.Config{}, false, err
 	}
-	if errors.Is(err, os.ErrNotExist) {
-		return config.Config{}, false, nil
+	return res.Config, res.Exists, nil
+}
+
+// saveConfig writes cfg without persisting values that only came from env
+// vars, flags or the user config underneath a project config.
+func (s Service) saveConfig(cfg config.Config) error {
+	res, err := s.rt.ResolveConfig(config.Config{})
+	if err != nil {
+		return err
 	}
-	return config.Config{}, false, err
+	return res.Save(cfg)
 }
 
 func (s Service) applyDefaults(cfg *config.Config) {
-	s.backfillFromLegacy(cfg)
-
 	cfg.DB.ServiceName = strings.TrimSpace(cfg.DB.ServiceName)
 	cfg.DB.ServiceNames = normalizeServiceNames(cfg.DB.ServiceNames)
-	if cfg.DB.ServiceName != "" {
-		cfg.DB.ServiceNames = upsertServiceName(cfg.DB.ServiceNames, cfg.DB.ServiceName)
-	}
 	if cfg.DB.ServiceName == "" && len(cfg.DB.ServiceNames) > 0 {
 		cfg.DB.ServiceName = cfg.DB.ServiceNames[0]
 	}
 
-	cfg.DB.ServiceTargets = normalizeServiceValues(cfg.DB.ServiceNames, cfg.DB.ServiceTargets)
-	cfg.DB.ServiceInstances = normalizeServiceValues(cfg.DB.ServiceNames, cfg.DB.ServiceInstances)
+	cfg.DB.RouteRules = normalizeRouteRules(cfg.DB.RouteRules)
 
-	if cfg.DB.ServiceName != "" {
-		if strings.TrimSpace(cfg.DB.TargetAddress) != "" && getServiceValue(cfg.DB.ServiceTargets, cfg.DB.ServiceName) == "" {
-			setServiceValue(&cfg.DB.ServiceTargets, cfg.DB.ServiceName, cfg.DB.TargetAddress)
-		}
-		if strings.TrimSpace(cfg.DB.TargetInstance) != "" && getServiceValue(cfg.DB.ServiceInstances, cfg.DB.ServiceName) == "" {
-			setServiceValue(&cfg.DB.ServiceInstances, cfg.DB.ServiceName, cfg.DB.TargetInstance)
-		}
-		if cfg.DB.TargetAddress == "" {
-			cfg.DB.TargetAddress = getServiceValue(cfg.DB.ServiceTargets, cfg.DB.ServiceName)
-		}
-		if cfg.DB.TargetInstance == "" {
-			cfg.DB.TargetInstance = getServiceValue(cfg.DB.ServiceInstances, cfg.DB.ServiceName)
+	targetKeys := routeTargetKeys(*cfg)
+	cfg.DB.ServiceTargets = normalizeServiceValues(targetKeys, cfg.DB.ServiceTargets)
+	cfg.DB.ServiceInstances = normalizeServiceValues(targetKeys, cfg.DB.ServiceInstances)
+	cfg.DB.PinnedInstances = normalizeServiceValues(cfg.DB.ServiceNames, cfg.DB.PinnedInstances)
+	cfg.DB.PinnedAddresses = normalizeServiceValues(cfg.DB.ServiceNames, cfg.DB.PinnedAddresses)
+	cfg.DB.ServiceReleases = normalizeServiceValues(cfg.DB.ServiceNames, cfg.DB.ServiceReleases)
+	cfg.DB.ServiceVersions = normalizeServiceValues(cfg.DB.ServiceNames, cfg.DB.ServiceVersions)
+
+	cfg.DB.DiscoveryProvider = discoveryProviderName(cfg.DB)
+	if strings.TrimSpace(cfg.DB.DiscoveryServiceMask) == "" {
+		switch cfg.DB.DiscoveryProvider {
+		case DiscoveryProviderConsul:
+			cfg.DB.DiscoveryServiceMask = defaultConsulServiceMask
+		case DiscoveryProviderDNS:
+			cfg.DB.DiscoveryServiceMask = defaultDNSServiceMask
 		}
 	}
-
 	if strings.TrimSpace(cfg.DB.ServiceDiscoveryScheme) == "" {
 		cfg.DB.ServiceDiscoveryScheme = defaultServiceDiscoveryScheme
 	}
@@ -468,20 +1068,21 @@ func (s Service) applyDefaults(cfg *config.Config) {
 }
 
 func (s Service) promptRuntimeConfig(cfg *config.Config, hasCfg bool) error {
-	pr := cli.NewPrompter(os.Stdin, os.Stdout)
+	pr := s.prompter()
 
 	curPort := ""
 	if cfg.DB.LocalPort > 0 {
 		curPort = strconv.Itoa(cfg.DB.LocalPort)
 	}
-	portStr, err := pr.AskString("Local proxy port", strconv.Itoa(defaultLocalPort), curPort, cli.ValidatePort)
+	portStr, err := pr.Ask("port", "Local proxy port", strconv.Itoa(defaultLocalPort), curPort, cli.ValidatePort)
 	if err != nil {
 		return err
 	}
 	port, _ := strconv.Atoi(strings.TrimSpace(portStr))
 	cfg.DB.LocalPort = port
 
-	role, err := pr.AskString(
+	role, err := pr.Ask(
+		"role",
 		"Preferred endpoint role (master/sync/async/any)",
 		defaultPreferRole,
 		cfg.DB.PreferRole,
@@ -497,26 +1098,70 @@ func (s Service) promptRuntimeConfig(cfg *config.Config, hasCfg bool) error {
 }
 
 func (s Service) resolveEndpoint(cfg config.Config, serviceName string) (string, Endpoint, error) {
-	endpointURL, err := BuildEndpointURL(
-		cfg.DB.ServiceDiscoveryScheme,
-		cfg.DB.ServiceDiscoveryHost,
-		cfg.DB.EndpointMask,
-		serviceName,
-	)
+	return s.resolveEndpointWith(cfg, serviceName, func(endpoints []Endpoint) (Endpoint, error) {
+		return ChooseEndpoint(endpoints, cfg.DB.PreferRole)
+	})
+}
+
+// resolveEndpointWith looks up serviceName and selects an endpoint with
+// choose, falling back to cached endpoints when discovery is unreachable.
+func (s Service) resolveEndpointWith(cfg config.Config, serviceName string, choose func([]Endpoint) (Endpoint, error)) (string, Endpoint, error) {
+	provider, err := NewDiscoveryProvider(cfg.DB)
 	if err != nil {
 		return "", Endpoint{}, err
 	}
-
-	endpoints, err := FetchEndpoints(endpointURL)
+	ttl, err := discoveryCacheTTL(cfg.DB)
 	if err != nil {
 		return "", Endpoint{}, err
 	}
-	ep, err := ChooseEndpoint(endpoints, cfg.DB.PreferRole)
+	source, endpoints, err := provider.Resolve(serviceName)
+	if err != nil {
+		if !discoveryUnreachable(err) {
+			return "", Endpoint{}, err
+		}
+		entry, ok := lookupDiscoveryCache(s.discoveryCachePath(cfg), serviceName, source, ttl, time.Now())
+		if !ok {
+			return "", Endpoint{}, err
+		}
+		fmt.Printf("service %s: service discovery failed (%v)\n", serviceName, err)
+		fmt.Printf("service %s: using STALE cached endpoints fetched at %s (%s ago)\n",
+			serviceName, entry.FetchedAt.Local().Format(time.RFC3339), time.Since(entry.FetchedAt).Round(time.Second))
+		ep, cacheErr := chooseReachableEndpoint(entry.Endpoints, choose)
+		if cacheErr != nil {
+			return "", Endpoint{}, fmt.Errorf("%w (cache fallback: %v)", err, cacheErr)
+		}
+		return "cache:" + entry.Source, ep, nil
+	}
+	entry := discoveryCacheEntry{
+		Service:   serviceName,
+		Source:    source,
+		Host:      sourceHost(source),
+		FetchedAt: time.Now().UTC(),
+		Endpoints: endpoints,
+	}
+	if err := storeDiscoveryCache(s.discoveryCachePath(cfg), entry); err != nil {
+		fmt.Printf("service %s: update discovery cache: %v\n", serviceName, err)
+	}
+	ep, err := choose(endpoints)
 	if err != nil {
 		return "", Endpoint{}, err
 	}
 
-	return endpointURL, ep, nil
+	return source, ep, nil
+}
+
+// discoveryCachePath returns the cache file of the active environment, so
+// endpoints cached for one environment never answer for another.
+func (s Service) discoveryCachePath(cfg config.Config) string {
+	path := s.rt.Paths.DBDiscoveryCache
+	if path == "" {
+		path = filepath.Join(s.rt.Paths.StateDir, "db-discovery-cache.json")
+	}
+	if env := activeEnvironment(cfg); env != defaultEnvironment {
+		ext := filepath.Ext(path)
+		path = strings.TrimSuffix(path, ext) + "-" + env + ext
+	}
+	return path
 }
 
 func validateRole(s string) error {
@@ -549,41 +1194,115 @@ func validateServiceName(s string) error {
 	return nil
 }
 
-func ensureServiceDiscoveryConfigured(cfg config.Config) error {
-	if strings.TrimSpace(cfg.DB.ServiceDiscoveryHost) == "" {
-		return fmt.Errorf("service discovery host is not configured")
-	}
-	if strings.TrimSpace(cfg.DB.EndpointMask) == "" {
-		return fmt.Errorf("Service discovery endpoint mask is not configured")
+func validateNotEmpty(s string) error {
+	if strings.TrimSpace(s) == "" {
+		return fmt.Errorf("must not be empty")
 	}
 	return nil
 }
 
-func (s Service) backfillFromLegacy(cfg *config.Config) {
-	legacy := strings.TrimSpace(cfg.DB.ServiceDiscoveryURL)
-	if legacy == "" {
-		return
+func validateTokenSource(s string) error {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "paste", "file", "command":
+		return nil
+	default:
+		return fmt.Errorf("must be one of: paste, file, command")
 	}
-	if strings.TrimSpace(cfg.DB.ServiceDiscoveryHost) != "" && strings.TrimSpace(cfg.DB.ServiceDiscoveryScheme) != "" {
-		return
+}
+
+func validateCAFile(s string) error {
+	if strings.TrimSpace(s) == "system" {
+		return nil
 	}
+	return validateReadableFile(s)
+}
 
-	scheme, host, err := NormalizeServiceDiscoveryInput(legacy)
+func validateReadableFile(s string) error {
+	val := strings.TrimSpace(s)
+	if val == "" {
+		return fmt.Errorf("must not be empty")
+	}
+	f, err := os.Open(val)
 	if err != nil {
+		return fmt.Errorf("file is not readable: %v", err)
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || info.IsDir() {
+		return fmt.Errorf("file is not readable: %s is a directory", val)
+	}
+	return nil
+}
+
+func ensureServiceDiscoveryConfigured(cfg config.Config) error {
+	switch discoveryProviderName(cfg.DB) {
+	case DiscoveryProviderHTTP:
+		if strings.TrimSpace(cfg.DB.ServiceDiscoveryHost) == "" {
+			return fmt.Errorf("service discovery host is not configured")
+		}
+		if strings.TrimSpace(cfg.DB.EndpointMask) == "" {
+			return fmt.Errorf("Service discovery endpoint mask is not configured")
+		}
+	case DiscoveryProviderConsul:
+		if strings.TrimSpace(cfg.DB.ServiceDiscoveryHost) == "" {
+			return fmt.Errorf("Consul address is not configured")
+		}
+		if strings.TrimSpace(cfg.DB.DiscoveryServiceMask) == "" {
+			return fmt.Errorf("Consul service name mask is not configured")
+		}
+	case DiscoveryProviderDNS:
+		if strings.TrimSpace(cfg.DB.DiscoveryServiceMask) == "" {
+			return fmt.Errorf("DNS SRV name mask is not configured")
+		}
+	case DiscoveryProviderFile:
+		if strings.TrimSpace(cfg.DB.DiscoveryFile) == "" {
+			return fmt.Errorf("static endpoints file is not configured")
+		}
+	default:
+		return fmt.Errorf("unknown service discovery provider %q", cfg.DB.DiscoveryProvider)
+	}
+	return nil
+}
+
+// serviceDiscoveryLabel describes where endpoints are looked up.
+func serviceDiscoveryLabel(cfg config.Config) string {
+	switch discoveryProviderName(cfg.DB) {
+	case DiscoveryProviderConsul:
+		return "consul " + serviceDiscoveryCurrent(&cfg)
+	case DiscoveryProviderDNS:
+		if strings.TrimSpace(cfg.DB.ServiceDiscoveryHost) == "" {
+			return "dns (system resolver)"
+		}
+		return "dns " + cfg.DB.ServiceDiscoveryHost
+	case DiscoveryProviderFile:
+		return "file " + cfg.DB.DiscoveryFile
+	}
+	return serviceDiscoveryCurrent(&cfg)
+}
+
+func printDiscoveryFallbackHosts(cfg config.Config) {
+	if len(cfg.DB.ServiceDiscoveryHosts) == 0 {
 		return
 	}
-	if strings.TrimSpace(cfg.DB.ServiceDiscoveryScheme) == "" {
-		cfg.DB.ServiceDiscoveryScheme = scheme
+	order := cfg.DB.DiscoveryHostOrder
+	if order == "" {
+		order = DiscoveryHostOrderOrdered
 	}
-	if strings.TrimSpace(cfg.DB.ServiceDiscoveryHost) == "" {
-		cfg.DB.ServiceDiscoveryHost = host
+	fmt.Printf("Service discovery fallback hosts: %s (%s)\n", strings.Join(cfg.DB.ServiceDiscoveryHosts, ", "), order)
+}
+
+func printDiscoveryMask(cfg config.Config) {
+	switch discoveryProviderName(cfg.DB) {
+	case DiscoveryProviderHTTP:
+		fmt.Println("db endpoint mask:", cfg.DB.EndpointMask)
+	case DiscoveryProviderConsul, DiscoveryProviderDNS:
+		fmt.Println("db service name mask:", cfg.DB.DiscoveryServiceMask)
 	}
 }
 
 func serviceDiscoveryCurrent(cfg *config.Config) string {
 	host := strings.TrimSpace(cfg.DB.ServiceDiscoveryHost)
 	if host == "" {
-		return strings.TrimSpace(cfg.DB.ServiceDiscoveryURL)
+		return ""
 	}
 	scheme := strings.TrimSpace(cfg.DB.ServiceDiscoveryScheme)
 	if scheme == "" {
@@ -592,6 +1311,17 @@ func serviceDiscoveryCurrent(cfg *config.Config) string {
 	return fmt.Sprintf("%s://%s", scheme, host)
 }
 
+func autoResolveLabel(cfg config.Config) string {
+	if !cfg.DB.AutoResolve {
+		return "no"
+	}
+	pattern := strings.TrimSpace(cfg.DB.AutoResolvePattern)
+	if pattern == "" {
+		pattern = "*"
+	}
+	return fmt.Sprintf("yes (pattern: %s)", pattern)
+}
+
 func boolLabel(v bool) string {
 	if v {
 		return "yes"
@@ -606,6 +1336,30 @@ func emptyIf(v string) string {
 	return v
 }
 
+// printServiceEndpoints prints the services of cfg; database names of
+// inactive environments carry the `<env>/` prefix.
+func printServiceEndpoints(cfg config.Config, indent string) {
+	prefix := ""
+	if indent != "" {
+		prefix = activeEnvironment(cfg) + environmentSeparator
+	}
+	for _, service := range cfg.DB.ServiceNames {
+		target := getServiceValue(cfg.DB.ServiceTargets, service)
+		instance := getServiceValue(cfg.DB.ServiceInstances, service)
+		fmt.Printf("%s- %s%s -> %s", indent, prefix, service, emptyIf(target))
+		if instance != "" {
+			fmt.Printf(" (%s)", instance)
+		}
+		if pin := servicePinLabel(cfg, service); pin != "" {
+			fmt.Printf(" [pinned %s]", pin)
+		}
+		if sel := serviceSelector(cfg, service); !sel.IsZero() {
+			fmt.Printf(" [%s]", sel)
+		}
+		fmt.Println()
+	}
+}
+
 func servicesLabel(serviceNames []string) string {
 	list := normalizeServiceNames(serviceNames)
 	if len(list) == 0 {
@@ -667,6 +1421,15 @@ func removeServiceName(serviceNames []string, serviceName string) ([]string, boo
 	return out, removed
 }
 
+func containsServiceName(serviceNames []string, serviceName string) bool {
+	for _, existing := range serviceNames {
+		if strings.EqualFold(strings.TrimSpace(existing), strings.TrimSpace(serviceName)) {
+			return true
+		}
+	}
+	return false
+}
+
 func serviceKey(service string) string {
 	return strings.ToLower(strings.TrimSpace(service))
 }
@@ -745,7 +1508,7 @@ func (s Service) proxyRoutesPath() string {
 }
 
 func (s Service) writeProxyRoutesFile(cfg config.Config) error {
-	if len(cfg.DB.ServiceNames) == 0 {
+	if !hasProxyRoutes(cfg) {
 		return fmt.Errorf("no services configured")
 	}
 	if err := os.MkdirAll(s.rt.Paths.StateDir, 0o755); err != nil {
@@ -753,7 +1516,37 @@ func (s Service) writeProxyRoutesFile(cfg config.Config) error {
 	}
 
 	routes := proxyRoutesFile{
-		Services: make(map[string]proxyRoute, len(cfg.DB.ServiceNames)),
+		Services:  make(map[string]proxyRoute, len(cfg.DB.ServiceNames)),
+		Discovery: proxyDiscoveryFromConfig(cfg, appruntime.SecretStores(s.rt.Paths)),
+		Auto:      proxyAutoResolveFromConfig(cfg),
+	}
+	for _, rule := range cfg.DB.RouteRules {
+		pr := proxyRouteRule{
+			Database:        rule.Database,
+			User:            rule.User,
+			ApplicationName: rule.ApplicationName,
+			Service:         rule.Service,
+			Role:            rule.Role,
+		}
+		if rule.Service != "" {
+			key := ruleTarget(rule).key()
+			pr.TargetAddr = getServiceValue(cfg.DB.ServiceTargets, key)
+			if pr.TargetAddr == "" {
+				return fmt.Errorf("target endpoint for service %q (rule %s) is not set", key, routeRuleLabel(rule))
+			}
+			pr.Instance = getServiceValue(cfg.DB.ServiceInstances, key)
+		}
+		routes.Rules = append(routes.Rules, pr)
+	}
+
+	// Keep routes the daemon already resolved on demand so clients do not
+	// hit service discovery again after every config change.
+	if current, err := loadProxyRoutes(s.proxyRoutesPath()); err == nil {
+		for key, route := range current.Services {
+			if route.Auto && routes.allowsDynamicRoute(route.Service) {
+				routes.Services[key] = route
+			}
+		}
 	}
 	for _, service := range cfg.DB.ServiceNames {
 		target := getServiceValue(cfg.DB.ServiceTargets, service)
@@ -764,17 +1557,38 @@ func (s Service) writeProxyRoutesFile(cfg config.Config) error {
 			Service:    service,
 			TargetAddr: target,
 			Instance:   getServiceValue(cfg.DB.ServiceInstances, service),
+			Pinned:     servicePinLabel(cfg, service) != "",
 		}
 	}
 
-	b, err := json.MarshalIndent(routes, "", "  ")
-	if err != nil {
-		return fmt.Errorf("marshal proxy routes: %w", err)
-	}
-	if err := os.WriteFile(s.proxyRoutesPath(), b, 0o600); err != nil {
-		return fmt.Errorf("write proxy routes: %w", err)
+	// Every environment is also reachable as `<env>/<service>`, so clients
+	// can use several of them through one proxy.
+	if len(cfg.DB.Environments) > 0 {
+		active := activeEnvironment(cfg)
+		routes.Environments = append([]string{active}, environmentNames(cfg)...)
+		for _, service := range cfg.DB.ServiceNames {
+			route := routes.Services[serviceKey(service)]
+			routes.Services[environmentServiceKey(active, service)] = route
+		}
+		for _, name := range environmentNames(cfg) {
+			envCfg := environmentConfig(cfg, name)
+			for _, service := range envCfg.DB.ServiceNames {
+				target := getServiceValue(envCfg.DB.ServiceTargets, service)
+				if target == "" {
+					fmt.Printf("environment %s: target endpoint for service %q is not set (run `db start`)\n", name, service)
+					continue
+				}
+				routes.Services[environmentServiceKey(name, service)] = proxyRoute{
+					Service:    service,
+					TargetAddr: target,
+					Instance:   getServiceValue(envCfg.DB.ServiceInstances, service),
+					Pinned:     servicePinLabel(envCfg, service) != "",
+				}
+			}
+		}
 	}
-	return nil
+
+	return writeProxyRoutes(s.proxyRoutesPath(), routes)
 }
 
 func (s Service) ensureProxyRunning(cfg config.Config) error {
@@ -1,14 +1,12 @@
 package db
 
 import (
-	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"net/url"
 	"regexp"
 	"strings"
-	"time"
+
+	"wslbridge/internal/config"
 )
 
 var maskPlaceholderRE = regexp.MustCompile(`<[^>]+>`)
@@ -26,30 +24,7 @@ type Endpoint struct {
 
 // FetchEndpoints gets endpoint data from Service discovery.
 func FetchEndpoints(ServiceDiscoveryURL string) ([]Endpoint, error) {
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(ServiceDiscoveryURL)
-	if err != nil {
-		return nil, fmt.Errorf("fetch Service discovery endpoints: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("Service discovery returned status %d", resp.StatusCode)
-	}
-
-	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
-	if err != nil {
-		return nil, fmt.Errorf("read Service discovery response: %w", err)
-	}
-
-	var endpoints []Endpoint
-	if err := json.Unmarshal(body, &endpoints); err != nil {
-		return nil, fmt.Errorf("decode Service discovery response: %w", err)
-	}
-	if len(endpoints) == 0 {
-		return nil, fmt.Errorf("Service discovery returned no endpoints")
-	}
-	return endpoints, nil
+	return fetchEndpoints(discoveryClient{}, ServiceDiscoveryURL, config.DBEndpointMapping{})
 }
 
 // ChooseEndpoint selects a preferred endpoint by role/default route.
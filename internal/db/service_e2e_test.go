@@ -43,7 +43,7 @@ func TestServiceE2E_MultiServiceLifecycle(t *testing.T) {
 
 	localPort := getClosedTCPPort(t)
 	initURL := serviceDiscovery.URL + "/endpoints?service=bootstrap.pg:bouncer"
-	withStdinInput(t, fmt.Sprintf("%s\n%d\n\n", initURL, localPort), func() {
+	withStdinInput(t, fmt.Sprintf("\n%s\n\n\n%d\n\n", initURL, localPort), func() {
 		if err := svc.Init(false); err != nil {
 			t.Fatalf("Init() error: %v", err)
 		}
@@ -174,7 +174,7 @@ func TestServiceE2E_AddServiceRejectsUnreachableEndpoint(t *testing.T) {
 
 	localPort := getClosedTCPPort(t)
 	initURL := serviceDiscovery.URL + "/endpoints?service=bootstrap.pg:bouncer"
-	withStdinInput(t, fmt.Sprintf("%s\n%d\n\n", initURL, localPort), func() {
+	withStdinInput(t, fmt.Sprintf("\n%s\n\n\n%d\n\n", initURL, localPort), func() {
 		if err := svc.Init(false); err != nil {
 			t.Fatalf("Init() error: %v", err)
 		}
@@ -216,7 +216,7 @@ func TestServiceE2E_CancelRequestIsForwardedToMatchedUpstream(t *testing.T) {
 
 	localPort := getClosedTCPPort(t)
 	initURL := serviceDiscovery.URL + "/endpoints?service=bootstrap.pg:bouncer"
-	withStdinInput(t, fmt.Sprintf("%s\n%d\n\n", initURL, localPort), func() {
+	withStdinInput(t, fmt.Sprintf("\n%s\n\n\n%d\n\n", initURL, localPort), func() {
 		if err := svc.Init(false); err != nil {
 			t.Fatalf("Init() error: %v", err)
 		}
@@ -236,6 +236,69 @@ func TestServiceE2E_CancelRequestIsForwardedToMatchedUpstream(t *testing.T) {
 	}
 }
 
+func TestServiceE2E_EnvironmentsAreServedWithPrefix(t *testing.T) {
+	requireWSLSupported(t)
+
+	rt := newE2ERuntime(t)
+	svc := NewService(rt)
+
+	devAddr, _, stopDev := startMockPostgresTarget(t, "dev-upstream")
+	defer stopDev()
+	stageAddr, _, stopStage := startMockPostgresTarget(t, "stage-upstream")
+	defer stopStage()
+
+	devDiscovery, _ := startServiceDiscoveryStub(t, map[string]string{"example-db": devAddr})
+	defer devDiscovery.Close()
+	stageDiscovery, _ := startServiceDiscoveryStub(t, map[string]string{"example-db": stageAddr})
+	defer stageDiscovery.Close()
+
+	localPort := getClosedTCPPort(t)
+	withStdinInput(t, fmt.Sprintf("\n%s\n\n\n%d\n\n", devDiscovery.URL+"/endpoints?service=bootstrap.pg:bouncer", localPort), func() {
+		if err := svc.Init(false); err != nil {
+			t.Fatalf("Init() dev error: %v", err)
+		}
+	})
+	if err := svc.AddService("example-db"); err != nil {
+		t.Fatalf("AddService(example-db) dev error: %v", err)
+	}
+	defer func() { _ = svc.Stop() }()
+
+	if err := svc.UseEnvironment("stage"); err != nil {
+		t.Fatalf("UseEnvironment(stage) error: %v", err)
+	}
+	withStdinInput(t, fmt.Sprintf("\n%s\n\n\n\n\n", stageDiscovery.URL+"/endpoints?service=bootstrap.pg:bouncer"), func() {
+		if err := svc.Init(false); err != nil {
+			t.Fatalf("Init() stage error: %v", err)
+		}
+	})
+	if err := svc.AddService("example-db"); err != nil {
+		t.Fatalf("AddService(example-db) stage error: %v", err)
+	}
+
+	cfg := mustLoadConfig(t, rt.Paths.ConfigPath)
+	if cfg.DB.Environment != "stage" || cfg.DB.Environments[defaultEnvironment].ServiceTargets["example-db"] != devAddr {
+		t.Fatalf("Environment=%q Environments=%+v", cfg.DB.Environment, cfg.DB.Environments)
+	}
+
+	listenAddr := fmt.Sprintf("%s:%d", defaultLocalHost, localPort)
+	for database, want := range map[string]string{
+		"example-db":         "stage-upstream",
+		"stage/example-db":   "stage-upstream",
+		"default/example-db": "dev-upstream",
+	} {
+		if msg := connectViaProxy(t, listenAddr, database); msg != want {
+			t.Fatalf("%s connect returned %q, want %q", database, msg, want)
+		}
+	}
+
+	if err := svc.UseEnvironment("default"); err != nil {
+		t.Fatalf("UseEnvironment(default) error: %v", err)
+	}
+	if msg := connectViaProxy(t, listenAddr, "example-db"); msg != "dev-upstream" {
+		t.Fatalf("example-db connect after switch returned %q, want %q", msg, "dev-upstream")
+	}
+}
+
 func requireWSLSupported(t *testing.T) {
 	t.Helper()
 	if goruntime.GOOS != "linux" || !env.IsWSL() {
@@ -721,6 +784,18 @@ func (q *queryRecorder) Contains(v string) bool {
 	return false
 }
 
+func (q *queryRecorder) Count(v string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := 0
+	for _, candidate := range q.queries {
+		if candidate == v {
+			n++
+		}
+	}
+	return n
+}
+
 func startServiceDiscoveryStub(t *testing.T, addrByService map[string]string) (*httptest.Server, *queryRecorder) {
 	t.Helper()
 
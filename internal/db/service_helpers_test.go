@@ -1,6 +1,7 @@
 package db
 
 import (
+	"encoding/binary"
 	"reflect"
 	"testing"
 )
@@ -87,3 +88,20 @@ func TestFindProxyRoute(t *testing.T) {
 		t.Fatalf("findProxyRoute() target got %q, want %q", got, want)
 	}
 }
+
+// TestParseStartupRequest_ApplicationName verifies startup parameter extraction.
+func TestParseStartupRequest_ApplicationName(t *testing.T) {
+	payload := []byte("user\x00reporting\x00database\x00example-db\x00application_name\x00psql\x00\x00")
+	packet := make([]byte, 8+len(payload))
+	binary.BigEndian.PutUint32(packet[0:4], uint32(len(packet)))
+	binary.BigEndian.PutUint32(packet[4:8], pgProtocolVersion3)
+	copy(packet[8:], payload)
+
+	req, err := parseStartupRequest(packet, pgProtocolVersion3)
+	if err != nil {
+		t.Fatalf("parseStartupRequest() error: %v", err)
+	}
+	if req.User != "reporting" || req.Database != "example-db" || req.ApplicationName != "psql" {
+		t.Fatalf("parseStartupRequest() = %+v", req)
+	}
+}
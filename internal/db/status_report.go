@@ -0,0 +1,202 @@
+package db
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"wslbridge/internal/config"
+)
+
+// StatusSchemaVersion is the version of the StatusReport schema. Fields are
+// only added within a version; renames and removals bump it.
+const StatusSchemaVersion = 1
+
+// statusCheckTimeout bounds the TCP check of each service target so that
+// `db status --output` stays fast enough for shell prompts.
+const statusCheckTimeout = 700 * time.Millisecond
+
+// Health values of StatusReport and StatusService.
+const (
+	HealthOK          = "ok"
+	HealthDegraded    = "degraded"
+	HealthDown        = "down"
+	HealthUnresolved  = "unresolved"
+	HealthUnreachable = "unreachable"
+	HealthStopped     = "stopped"
+)
+
+// StatusReport is the machine-readable form of `db status`. All fields are
+// always present; unknown values are empty strings or zero.
+type StatusReport struct {
+	SchemaVersion int             `json:"schema_version" yaml:"schema_version"`
+	Config        StatusConfig    `json:"config" yaml:"config"`
+	Environment   string          `json:"environment" yaml:"environment"`
+	Discovery     StatusDiscovery `json:"discovery" yaml:"discovery"`
+	Proxy         StatusProxy     `json:"proxy" yaml:"proxy"`
+	Services      []StatusService `json:"services" yaml:"services"`
+	// Health is ok when the proxy runs and every service target answers,
+	// down when the proxy is not running and degraded otherwise.
+	Health string `json:"health" yaml:"health"`
+}
+
+// StatusConfig describes where the configuration was loaded from.
+type StatusConfig struct {
+	Path    string `json:"path" yaml:"path"`
+	Exists  bool   `json:"exists" yaml:"exists"`
+	Profile string `json:"profile" yaml:"profile"`
+}
+
+// StatusDiscovery describes the service discovery of the active environment.
+type StatusDiscovery struct {
+	Provider string `json:"provider" yaml:"provider"`
+	Address  string `json:"address" yaml:"address"`
+}
+
+// StatusProxy describes the local proxy daemon.
+type StatusProxy struct {
+	Running    bool   `json:"running" yaml:"running"`
+	PID        int    `json:"pid" yaml:"pid"`
+	StalePID   bool   `json:"stale_pid" yaml:"stale_pid"`
+	ListenAddr string `json:"listen_addr" yaml:"listen_addr"`
+	RoutesFile string `json:"routes_file" yaml:"routes_file"`
+	StartedAt  string `json:"started_at" yaml:"started_at"`
+	PIDFile    string `json:"pid_file" yaml:"pid_file"`
+	LogFile    string `json:"log_file" yaml:"log_file"`
+}
+
+// StatusService describes one routed service.
+type StatusService struct {
+	Name        string `json:"name" yaml:"name"`
+	Environment string `json:"environment" yaml:"environment"`
+	Active      bool   `json:"active" yaml:"active"`
+	Target      string `json:"target" yaml:"target"`
+	Instance    string `json:"instance" yaml:"instance"`
+	// PinnedInstance and PinnedAddress are set by `db pin`.
+	PinnedInstance string `json:"pinned_instance" yaml:"pinned_instance"`
+	PinnedAddress  string `json:"pinned_address" yaml:"pinned_address"`
+	// Release and Version narrow the endpoints the target is chosen from.
+	Release string `json:"release" yaml:"release"`
+	Version string `json:"version" yaml:"version"`
+	// Health is ok, unresolved (no target), unreachable (TCP check failed)
+	// or stopped (the proxy is not running).
+	Health string `json:"health" yaml:"health"`
+	Error  string `json:"error" yaml:"error"`
+}
+
+// StatusReport returns the current configuration and daemon state for
+// `db status --output json|yaml`. Service targets are checked over TCP.
+func (s Service) StatusReport() (StatusReport, error) {
+	if err := s.checkSupported(); err != nil {
+		return StatusReport{}, err
+	}
+
+	cfg, exists, err := s.loadConfig()
+	if err != nil {
+		return StatusReport{}, err
+	}
+	s.applyDefaults(&cfg)
+
+	proxy := StatusProxy{
+		Running:    IsProxyRunning(s.rt.Paths.DBProxyPIDFile),
+		ListenAddr: fmt.Sprintf("%s:%d", cfg.DB.LocalHost, cfg.DB.LocalPort),
+		RoutesFile: s.proxyRoutesPath(),
+		PIDFile:    s.rt.Paths.DBProxyPIDFile,
+		LogFile:    s.rt.Paths.DBProxyLogFile,
+	}
+	if pid, ok := readPID(s.rt.Paths.DBProxyPIDFile); ok {
+		proxy.PID = pid
+		proxy.StalePID = !proxy.Running
+	}
+	if meta, ok := readProxyMeta(s.rt.Paths.DBProxyMetaFile); ok {
+		proxy.ListenAddr = meta.ListenAddr
+		proxy.RoutesFile = meta.RoutesFile
+		proxy.StartedAt = meta.StartedAt
+	}
+
+	report := buildStatusReport(cfg, proxy, func(addr string) error {
+		return CheckTCPConnectivity(addr, statusCheckTimeout)
+	})
+	report.Config = StatusConfig{Path: s.rt.Paths.ConfigPath, Exists: exists, Profile: s.rt.Profile}
+	return report, nil
+}
+
+// buildStatusReport fills the services and health of the report from cfg
+// and proxy; check probes a service target.
+func buildStatusReport(cfg config.Config, proxy StatusProxy, check func(addr string) error) StatusReport {
+	report := StatusReport{
+		SchemaVersion: StatusSchemaVersion,
+		Environment:   activeEnvironment(cfg),
+		Discovery:     StatusDiscovery{Provider: discoveryProviderName(cfg.DB), Address: statusDiscoveryAddress(cfg)},
+		Proxy:         proxy,
+		Services:      statusServices(cfg, true),
+	}
+	for _, name := range environmentNames(cfg) {
+		report.Services = append(report.Services, statusServices(environmentConfig(cfg, name), false)...)
+	}
+
+	var wg sync.WaitGroup
+	for i := range report.Services {
+		svc := &report.Services[i]
+		switch {
+		case svc.Target == "":
+			svc.Health = HealthUnresolved
+		case !proxy.Running:
+			svc.Health = HealthStopped
+		default:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := check(svc.Target); err != nil {
+					svc.Health = HealthUnreachable
+					svc.Error = err.Error()
+					return
+				}
+				svc.Health = HealthOK
+			}()
+		}
+	}
+	wg.Wait()
+
+	report.Health = HealthOK
+	if !proxy.Running {
+		report.Health = HealthDown
+	} else {
+		for _, svc := range report.Services {
+			if svc.Health != HealthOK {
+				report.Health = HealthDegraded
+				break
+			}
+		}
+	}
+	return report
+}
+
+func statusServices(cfg config.Config, active bool) []StatusService {
+	env := activeEnvironment(cfg)
+	out := make([]StatusService, 0, len(cfg.DB.ServiceNames))
+	for _, service := range normalizeServiceNames(cfg.DB.ServiceNames) {
+		out = append(out, StatusService{
+			Name:           service,
+			Environment:    env,
+			Active:         active,
+			Target:         getServiceValue(cfg.DB.ServiceTargets, service),
+			Instance:       getServiceValue(cfg.DB.ServiceInstances, service),
+			PinnedInstance: getServiceValue(cfg.DB.PinnedInstances, service),
+			PinnedAddress:  getServiceValue(cfg.DB.PinnedAddresses, service),
+			Release:        getServiceValue(cfg.DB.ServiceReleases, service),
+			Version:        getServiceValue(cfg.DB.ServiceVersions, service),
+		})
+	}
+	return out
+}
+
+func statusDiscoveryAddress(cfg config.Config) string {
+	switch discoveryProviderName(cfg.DB) {
+	case DiscoveryProviderDNS:
+		return cfg.DB.ServiceDiscoveryHost
+	case DiscoveryProviderFile:
+		return cfg.DB.DiscoveryFile
+	}
+	return serviceDiscoveryCurrent(&cfg)
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"wslbridge/internal/config"
+)
+
+// TestBuildStatusReport verifies per-service and overall health.
+func TestBuildStatusReport(t *testing.T) {
+	cfg := config.Config{DB: config.DBConfig{
+		ServiceNames:    []string{"orders-db", "billing-db", "new-db"},
+		ServiceTargets:  map[string]string{"orders-db": "10.0.0.5:5432", "billing-db": "10.0.0.6:5432"},
+		PinnedInstances: map[string]string{"orders-db": "pg-2"},
+		Environments: map[string]config.DBEnvironment{
+			"stage": {ServiceNames: []string{"orders-db"}, ServiceTargets: map[string]string{"orders-db": "10.1.0.5:5432"}},
+		},
+	}}
+	check := func(addr string) error {
+		if addr == "10.0.0.6:5432" {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+
+	r := buildStatusReport(cfg, StatusProxy{Running: true}, check)
+	if r.SchemaVersion != StatusSchemaVersion || r.Health != HealthDegraded {
+		t.Fatalf("report = %+v", r)
+	}
+	want := map[string]string{
+		"default/orders-db":  HealthOK,
+		"default/billing-db": HealthUnreachable,
+		"default/new-db":     HealthUnresolved,
+		"stage/orders-db":    HealthOK,
+	}
+	if len(r.Services) != len(want) {
+		t.Fatalf("services = %+v", r.Services)
+	}
+	for _, svc := range r.Services {
+		if got := want[svc.Environment+"/"+svc.Name]; got != svc.Health {
+			t.Fatalf("%s/%s health = %s, want %s", svc.Environment, svc.Name, svc.Health, got)
+		}
+	}
+	if r.Services[0].PinnedInstance != "pg-2" || !r.Services[0].Active || r.Services[3].Active {
+		t.Fatalf("services = %+v", r.Services)
+	}
+	if r.Services[1].Error == "" {
+		t.Fatalf("unreachable service must carry the error")
+	}
+
+	r = buildStatusReport(cfg, StatusProxy{}, func(string) error {
+		t.Fatalf("targets must not be checked while the proxy is stopped")
+		return nil
+	})
+	if r.Health != HealthDown || r.Services[0].Health != HealthStopped {
+		t.Fatalf("report = %+v", r)
+	}
+}
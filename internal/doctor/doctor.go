@@ -0,0 +1,76 @@
+// Package doctor holds the result type shared by the `doctor` checks of the
+// bridge components and renders a report of them.
+package doctor
+
+import (
+	"fmt"
+	"io"
+)
+
+// Status is the outcome of a check.
+type Status string
+
+// Check outcomes. Warn marks a degraded but usable setup; Skip marks a
+// check that did not apply, e.g. because a check it depends on failed.
+const (
+	OK   Status = "ok"
+	Warn Status = "warn"
+	Fail Status = "fail"
+	Skip Status = "skip"
+)
+
+// Result is the outcome of one check with a hint on how to fix it.
+type Result struct {
+	Name   string `json:"name" yaml:"name"`
+	Status Status `json:"status" yaml:"status"`
+	Detail string `json:"detail" yaml:"detail"`
+	Hint   string `json:"hint" yaml:"hint"`
+}
+
+// Pass returns an ok result.
+func Pass(name, detail string) Result {
+	return Result{Name: name, Status: OK, Detail: detail}
+}
+
+// Problem returns a result with status, a detail and a fix hint.
+func Problem(name string, status Status, detail, hint string) Result {
+	return Result{Name: name, Status: status, Detail: detail, Hint: hint}
+}
+
+// Failed returns the number of failed results.
+func Failed(results []Result) int {
+	n := 0
+	for _, r := range results {
+		if r.Status == Fail {
+			n++
+		}
+	}
+	return n
+}
+
+// Write prints results one per line with their hints and a summary.
+func Write(w io.Writer, results []Result) {
+	counts := map[Status]int{}
+	for _, r := range results {
+		counts[r.Status]++
+		_, _ = fmt.Fprintf(w, "%-6s %s: %s\n", label(r.Status), r.Name, r.Detail)
+		if r.Hint != "" && r.Status != OK {
+			_, _ = fmt.Fprintf(w, "       fix: %s\n", r.Hint)
+		}
+	}
+	_, _ = fmt.Fprintf(w, "\n%d checks: %d ok, %d warnings, %d failed, %d skipped\n",
+		len(results), counts[OK], counts[Warn], counts[Fail], counts[Skip])
+}
+
+func label(s Status) string {
+	switch s {
+	case OK:
+		return "[ok]"
+	case Warn:
+		return "[warn]"
+	case Fail:
+		return "[FAIL]"
+	default:
+		return "[skip]"
+	}
+}
@@ -26,3 +26,8 @@ func (Driver) Stop(rt appruntime.Runtime, args []string) error {
 func (Driver) Status(rt appruntime.Runtime, args []string) error {
 	return fmt.Errorf("macOS status is not implemented yet")
 }
+
+// Watchdog is not implemented for macOS yet.
+func (Driver) Watchdog(rt appruntime.Runtime, args []string) error {
+	return fmt.Errorf("macOS watchdog is not implemented yet")
+}
@@ -8,4 +8,5 @@ type Driver interface {
 	Init(rt runtime.Runtime, args []string) error
 	Stop(rt runtime.Runtime, args []string) error
 	Status(rt runtime.Runtime, args []string) error
+	Watchdog(rt runtime.Runtime, args []string) error
 }
@@ -26,3 +26,8 @@ func (Driver) Stop(rt appruntime.Runtime, args []string) error {
 func (Driver) Status(rt appruntime.Runtime, args []string) error {
 	return fmt.Errorf("windows status is not implemented yet")
 }
+
+// Watchdog is not implemented for Windows yet.
+func (Driver) Watchdog(rt appruntime.Runtime, args []string) error {
+	return fmt.Errorf("Windows watchdog is not implemented yet")
+}
@@ -25,3 +25,8 @@ func (Driver) Stop(rt appruntime.Runtime, args []string) error {
 func (Driver) Status(rt appruntime.Runtime, args []string) error {
 	return initubuntu.StatusCommand{}.Run(rt, args)
 }
+
+// Watchdog runs the WSL tunnel watchdog.
+func (Driver) Watchdog(rt appruntime.Runtime, args []string) error {
+	return initubuntu.WatchdogCommand{}.Run(rt, args)
+}
@@ -7,15 +7,26 @@ import (
 
 // Paths groups filesystem paths used by the app.
 type Paths struct {
+	// ConfigPath is the config file that saves write to. UserConfigPath is
+	// the user (or profile) config layered below it when they differ.
 	ConfigPath       string
+	UserConfigPath   string
 	ShareDir         string
 	StateDir         string
 	DefaultRouteFile string
+	TunRoutesFile    string
+	TunBypassFile    string
 	Tun2SocksPIDFile string
 	Tun2SocksLogFile string
+	WatchdogPIDFile  string
+	WatchdogLogFile  string
 	DBProxyPIDFile   string
 	DBProxyMetaFile  string
 	DBProxyLogFile   string
+	DBDiscoveryCache string
+	// SecretStoreDir holds the encrypted store that secret:// config
+	// values resolve from.
+	SecretStoreDir string
 }
 
 // DefaultPaths returns default user-scoped paths.
@@ -29,15 +40,27 @@ func DefaultPaths() (Paths, error) {
 	share := filepath.Join(home, ".local", "share", "wslbridge")
 	state := filepath.Join(home, ".local", "state", "wslbridge")
 
+	return pathsIn(filepath.Join(cfgDir, "config.yaml"), share, state), nil
+}
+
+// pathsIn lays out the state files of one config under state.
+func pathsIn(configPath, share, state string) Paths {
 	return Paths{
-		ConfigPath:       filepath.Join(cfgDir, "config.yaml"),
+		ConfigPath:       configPath,
+		UserConfigPath:   configPath,
 		ShareDir:         share,
 		StateDir:         state,
 		DefaultRouteFile: filepath.Join(state, "default_route.txt"),
+		TunRoutesFile:    filepath.Join(state, "tun_routes.txt"),
+		TunBypassFile:    filepath.Join(state, "tun_bypass.txt"),
 		Tun2SocksPIDFile: filepath.Join(state, "tun2socks.pid"),
 		Tun2SocksLogFile: filepath.Join(state, "tun2socks.log"),
+		WatchdogPIDFile:  filepath.Join(state, "watchdog.pid"),
+		WatchdogLogFile:  filepath.Join(state, "watchdog.log"),
 		DBProxyPIDFile:   filepath.Join(state, "db-proxy.pid"),
 		DBProxyMetaFile:  filepath.Join(state, "db-proxy.json"),
 		DBProxyLogFile:   filepath.Join(state, "db-proxy.log"),
-	}, nil
+		DBDiscoveryCache: filepath.Join(state, "db-discovery-cache.json"),
+		SecretStoreDir:   filepath.Join(state, "secret-store"),
+	}
 }
@@ -0,0 +1,311 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"wslbridge/internal/config"
+)
+
+const (
+	// DefaultProfile uses the regular config and state paths.
+	DefaultProfile = "default"
+	// ProfileEnv selects a profile like the --profile flag.
+	ProfileEnv = "WSLBRIDGE_PROFILE"
+
+	profilesDirName   = "profiles"
+	profileSelectFile = "profile"
+
+	// maxExtendsFollowed bounds the `extends` chain SecretStores walks.
+	maxExtendsFollowed = 8
+)
+
+var profileNameRE = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// ValidateProfileName checks that name can be used as a file name.
+func ValidateProfileName(name string) error {
+	if !profileNameRE.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: must start with a letter or digit and contain only letters, digits, `-` and `_`", name)
+	}
+	return nil
+}
+
+func normalizeProfile(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return DefaultProfile
+	}
+	return name
+}
+
+// ProfilesDir returns the directory holding <name>.yaml profile configs.
+func ProfilesDir() (string, error) {
+	paths, err := DefaultPaths()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(paths.ConfigPath), profilesDirName), nil
+}
+
+func profileSelectPath() (string, error) {
+	paths, err := DefaultPaths()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(paths.ConfigPath), profileSelectFile), nil
+}
+
+// ErrProfileNotFound is returned by ResolveProfile for a profile that has
+// no config file.
+var ErrProfileNotFound = errors.New("profile not found")
+
+// ResolveProfile returns the active profile: flag, then $WSLBRIDGE_PROFILE,
+// then the one saved by SelectProfile. The profile must exist, so a typo
+// does not run against a fresh, empty state dir.
+func ResolveProfile(flag string) (string, error) {
+	name, err := requestedProfile(flag)
+	if err != nil {
+		return "", err
+	}
+	if !ProfileExists(name) {
+		return "", fmt.Errorf("%w: %q (see `wslbridge profile list`; create it with `wslbridge profile use %s`)", ErrProfileNotFound, name, name)
+	}
+	return name, nil
+}
+
+// requestedProfile is ResolveProfile without the existence check.
+func requestedProfile(flag string) (string, error) {
+	name := strings.TrimSpace(flag)
+	if name == "" {
+		name = os.Getenv(ProfileEnv)
+	}
+	if strings.TrimSpace(name) == "" {
+		return SelectedProfile()
+	}
+	name = normalizeProfile(name)
+	if err := ValidateProfileName(name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// SelectedProfile returns the profile saved by SelectProfile.
+func SelectedProfile() (string, error) {
+	path, err := profileSelectPath()
+	if err != nil {
+		return "", err
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return DefaultProfile, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	name := normalizeProfile(string(b))
+	if err := ValidateProfileName(name); err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	return name, nil
+}
+
+// SelectProfile saves name as the profile used without --profile.
+func SelectProfile(name string) error {
+	name = normalizeProfile(name)
+	if err := ValidateProfileName(name); err != nil {
+		return err
+	}
+	path, err := profileSelectPath()
+	if err != nil {
+		return err
+	}
+	if name == DefaultProfile {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(name+"\n"), 0o644)
+}
+
+// ProfileConfigPath returns the config file of profile name.
+func ProfileConfigPath(name string) (string, error) {
+	name = normalizeProfile(name)
+	if name == DefaultProfile {
+		return ResolveConfigPath()
+	}
+	dir, err := ProfilesDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".yaml"), nil
+}
+
+// PathsForProfile returns the paths of profile name. Non-default profiles
+// keep their state under <state>/profiles/<name> so their daemons, pid files
+// and caches do not collide. The tun device and the default route belong to
+// the whole system, so the tun2socks, watchdog and route files stay shared:
+// whichever profile stops the tunnel restores the route saved when it came
+// up. Project-local config only applies to the default profile.
+func PathsForProfile(name string) (Paths, error) {
+	name = normalizeProfile(name)
+	paths, err := DefaultPaths()
+	if err != nil {
+		return Paths{}, err
+	}
+	cfgPath, err := ProfileConfigPath(name)
+	if err != nil {
+		return Paths{}, err
+	}
+	if name == DefaultProfile {
+		paths.ConfigPath = cfgPath
+		return paths, nil
+	}
+	p := pathsIn(cfgPath, paths.ShareDir, filepath.Join(paths.StateDir, profilesDirName, name))
+	p.DefaultRouteFile = paths.DefaultRouteFile
+	p.TunRoutesFile = paths.TunRoutesFile
+	p.TunBypassFile = paths.TunBypassFile
+	p.Tun2SocksPIDFile = paths.Tun2SocksPIDFile
+	p.Tun2SocksLogFile = paths.Tun2SocksLogFile
+	p.WatchdogPIDFile = paths.WatchdogPIDFile
+	p.WatchdogLogFile = paths.WatchdogLogFile
+	return p, nil
+}
+
+// ProfileExists reports whether profile name has a config file. The default
+// profile always exists.
+func ProfileExists(name string) bool {
+	name = normalizeProfile(name)
+	if name == DefaultProfile {
+		return true
+	}
+	path, err := ProfileConfigPath(name)
+	return err == nil && exists(path)
+}
+
+// ListProfiles returns the default profile followed by the saved profiles
+// in name order.
+func ListProfiles() ([]string, error) {
+	dir, err := ProfilesDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".yaml")
+		if e.IsDir() || !ok || name == DefaultProfile || ValidateProfileName(name) != nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append([]string{DefaultProfile}, names...), nil
+}
+
+// CreateProfile writes a profile config that extends base and returns its
+// path. Values saved later in the profile override the base ones.
+func CreateProfile(name, base string) (string, error) {
+	name = normalizeProfile(name)
+	base = normalizeProfile(base)
+	if err := ValidateProfileName(name); err != nil {
+		return "", err
+	}
+	if name == DefaultProfile {
+		return "", fmt.Errorf("profile %q always exists", DefaultProfile)
+	}
+	if err := ValidateProfileName(base); err != nil {
+		return "", fmt.Errorf("base profile: %w", err)
+	}
+	if base == name {
+		return "", fmt.Errorf("profile %q cannot extend itself", name)
+	}
+	if !ProfileExists(base) {
+		return "", fmt.Errorf("base profile %q not found", base)
+	}
+
+	path, err := ProfileConfigPath(name)
+	if err != nil {
+		return "", err
+	}
+	if exists(path) {
+		return "", fmt.Errorf("profile %q already exists", name)
+	}
+	ref := base
+	if base == DefaultProfile {
+		if ref, err = defaultProfileRef(filepath.Dir(path)); err != nil {
+			return "", err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	body := fmt.Sprintf("extends: %q\nversion: %d\n", ref, config.CurrentVersion)
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// defaultProfileRef returns the `extends` value pointing at the default
+// config: relative to the profiles dir when it lives in the user config dir,
+// absolute otherwise (project-local config).
+func defaultProfileRef(profilesDir string) (string, error) {
+	cfgPath, err := ResolveConfigPath()
+	if err != nil {
+		return "", err
+	}
+	if filepath.Dir(cfgPath) == filepath.Dir(profilesDir) {
+		return filepath.Join("..", filepath.Base(cfgPath)), nil
+	}
+	return filepath.Abs(cfgPath)
+}
+
+// SecretStores returns the secret stores that secret:// references in the
+// config of paths resolve from: the profile's own store first, then the
+// stores of the profiles whose configs it extends, so inherited references
+// resolve where they were saved. The default profile's store comes last.
+func SecretStores(paths Paths) []string {
+	stores := []string{paths.SecretStoreDir}
+	add := func(dir string) {
+		for _, s := range stores {
+			if s == dir {
+				return
+			}
+		}
+		stores = append(stores, dir)
+	}
+	profilesDir, err := ProfilesDir()
+	if err != nil {
+		return stores
+	}
+	path := paths.ConfigPath
+	for depth := 0; depth < maxExtendsFollowed; depth++ {
+		extends := config.ReadExtends(path)
+		if extends == "" {
+			break
+		}
+		path = config.ResolveExtends(path, extends)
+		if filepath.Dir(path) != profilesDir || filepath.Ext(path) != ".yaml" {
+			continue
+		}
+		if base, err := PathsForProfile(strings.TrimSuffix(filepath.Base(path), ".yaml")); err == nil {
+			add(base.SecretStoreDir)
+		}
+	}
+	if base, err := DefaultPaths(); err == nil {
+		add(base.SecretStoreDir)
+	}
+	return stores
+}
@@ -0,0 +1,130 @@
+package runtime
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestProfiles validates profile selection, creation and path namespacing.
+func TestProfiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(ProfileEnv, "")
+	t.Chdir(t.TempDir())
+
+	if got, err := ResolveProfile(""); err != nil || got != DefaultProfile {
+		t.Fatalf("ResolveProfile() = %q, %v; want %q", got, err, DefaultProfile)
+	}
+
+	path, err := CreateProfile("Office", "")
+	if err != nil {
+		t.Fatalf("CreateProfile error: %v", err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile error: %v", err)
+	}
+	if string(b) != "extends: \"../config.yaml\"\nversion: 2\n" {
+		t.Fatalf("profile body = %q", b)
+	}
+	if _, err := CreateProfile("office", "default"); err == nil {
+		t.Fatalf("CreateProfile() expected error for an existing profile")
+	}
+	if _, err := CreateProfile("lab", "missing"); err == nil {
+		t.Fatalf("CreateProfile() expected error for a missing base")
+	}
+
+	if err := SelectProfile("office"); err != nil {
+		t.Fatalf("SelectProfile error: %v", err)
+	}
+	if got, err := ResolveProfile(""); err != nil || got != "office" {
+		t.Fatalf("ResolveProfile() = %q, %v; want office", got, err)
+	}
+	if _, err := CreateProfile("lab", "office"); err != nil {
+		t.Fatalf("CreateProfile(lab) error: %v", err)
+	}
+	t.Setenv(ProfileEnv, "lab")
+	if got, err := ResolveProfile(""); err != nil || got != "lab" {
+		t.Fatalf("ResolveProfile() with env = %q, %v; want lab", got, err)
+	}
+	if got, err := ResolveProfile("office"); err != nil || got != "office" {
+		t.Fatalf("ResolveProfile(flag) = %q, %v; want office", got, err)
+	}
+	if _, err := ResolveProfile("prdo"); !errors.Is(err, ErrProfileNotFound) {
+		t.Fatalf("ResolveProfile(prdo) error = %v, want ErrProfileNotFound", err)
+	}
+	if got, err := requestedProfile("prdo"); err != nil || got != "prdo" {
+		t.Fatalf("requestedProfile(prdo) = %q, %v; want prdo", got, err)
+	}
+	if _, err := ResolveProfile("../x"); err == nil {
+		t.Fatalf("ResolveProfile() expected error for an invalid name")
+	}
+
+	names, err := ListProfiles()
+	if err != nil || !reflect.DeepEqual(names, []string{"default", "lab", "office"}) {
+		t.Fatalf("ListProfiles() = %v, %v", names, err)
+	}
+
+	def, err := PathsForProfile(DefaultProfile)
+	if err != nil {
+		t.Fatalf("PathsForProfile(default) error: %v", err)
+	}
+	office, err := PathsForProfile("office")
+	if err != nil {
+		t.Fatalf("PathsForProfile(office) error: %v", err)
+	}
+	if office.ConfigPath != path {
+		t.Fatalf("office ConfigPath = %q, want %q", office.ConfigPath, path)
+	}
+	if want := filepath.Join(def.StateDir, "profiles", "office", "db-proxy.pid"); office.DBProxyPIDFile != want {
+		t.Fatalf("office DBProxyPIDFile = %q, want %q", office.DBProxyPIDFile, want)
+	}
+	if office.DefaultRouteFile != def.DefaultRouteFile || office.TunRoutesFile != def.TunRoutesFile ||
+		office.Tun2SocksPIDFile != def.Tun2SocksPIDFile || office.WatchdogPIDFile != def.WatchdogPIDFile {
+		t.Fatalf("office tunnel state must be shared with default: %+v", office)
+	}
+
+	if err := SelectProfile(DefaultProfile); err != nil {
+		t.Fatalf("SelectProfile(default) error: %v", err)
+	}
+	if got, err := SelectedProfile(); err != nil || got != DefaultProfile {
+		t.Fatalf("SelectedProfile() = %q, %v", got, err)
+	}
+}
+
+// TestSecretStores verifies that a profile resolves secrets from its own
+// store, then from the stores of the profiles it extends.
+func TestSecretStores(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Chdir(t.TempDir())
+
+	if _, err := CreateProfile("office", ""); err != nil {
+		t.Fatalf("CreateProfile(office) error: %v", err)
+	}
+	if _, err := CreateProfile("lab", "office"); err != nil {
+		t.Fatalf("CreateProfile(lab) error: %v", err)
+	}
+	stores := func(name string) []string {
+		paths, err := PathsForProfile(name)
+		if err != nil {
+			t.Fatalf("PathsForProfile(%s) error: %v", name, err)
+		}
+		return SecretStores(paths)
+	}
+	state := filepath.Join(home, ".local", "state", "wslbridge")
+	want := []string{
+		filepath.Join(state, "profiles", "lab", "secret-store"),
+		filepath.Join(state, "profiles", "office", "secret-store"),
+		filepath.Join(state, "secret-store"),
+	}
+	if got := stores("lab"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("SecretStores(lab) = %v, want %v", got, want)
+	}
+	if got := stores(DefaultProfile); !reflect.DeepEqual(got, want[2:]) {
+		t.Fatalf("SecretStores(default) = %v, want %v", got, want[2:])
+	}
+}
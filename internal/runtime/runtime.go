@@ -1,29 +1,78 @@
 package runtime
 
 import (
+	"os"
+
+	"wslbridge/internal/cli"
+	"wslbridge/internal/config"
 	"wslbridge/internal/execx"
 	"wslbridge/internal/platform"
 )
 
 // Runtime bundles runtime dependencies and paths.
 type Runtime struct {
-	Paths    Paths
-	Runner   execx.Runner
-	Platform platform.Platform
+	Profile string
+	Paths   Paths
+	// ConfigFlags holds `--set key=value` overrides by dotted config key.
+	ConfigFlags map[string]string
+	// NonInteractive makes prompts fail instead of reading stdin when a
+	// value has no flag, current value or default.
+	NonInteractive bool
+	Runner         execx.Runner
+	Platform       platform.Platform
 }
 
-// New constructs a Runtime with resolved paths.
+// New constructs a Runtime with resolved paths for the active profile.
 func New(r execx.Runner, p platform.Platform) (Runtime, error) {
-	paths, err := DefaultPaths()
+	return NewForProfile(r, p, "", false)
+}
+
+// NewForProfile constructs a Runtime for profile. An empty profile falls back
+// to $WSLBRIDGE_PROFILE and then to the profile saved by `profile use`.
+// Unknown profiles are an error unless allowMissing is set for commands
+// that create profiles.
+func NewForProfile(r execx.Runner, p platform.Platform, profile string, allowMissing bool) (Runtime, error) {
+	resolve := ResolveProfile
+	if allowMissing {
+		resolve = requestedProfile
+	}
+	name, err := resolve(profile)
 	if err != nil {
 		return Runtime{}, err
 	}
 	// default: prefer project-local config when in wslbridge repo
-	cfgPath, err := ResolveConfigPath()
+	paths, err := PathsForProfile(name)
 	if err != nil {
 		return Runtime{}, err
 	}
-	paths.ConfigPath = cfgPath
 
-	return Runtime{Paths: paths, Runner: r, Platform: p}, nil
+	return Runtime{Profile: name, Paths: paths, Runner: r, Platform: p}, nil
+}
+
+// ConfigSources returns the config layers of rt with defaults at the bottom.
+func (rt Runtime) ConfigSources(defaults config.Config) config.Sources {
+	src := config.Sources{
+		Defaults: defaults,
+		UserPath: rt.Paths.ConfigPath,
+		Env:      os.Environ(),
+		Flags:    rt.ConfigFlags,
+
+		SecretStores: SecretStores(rt.Paths),
+	}
+	if user := rt.Paths.UserConfigPath; user != "" && user != rt.Paths.ConfigPath {
+		src.UserPath = user
+		src.ProjectPath = rt.Paths.ConfigPath
+	}
+	return src
+}
+
+// ResolveConfig loads the layered config of rt.
+func (rt Runtime) ResolveConfig(defaults config.Config) (config.Resolved, error) {
+	return config.Resolve(rt.ConfigSources(defaults))
+}
+
+// Prompter returns a stdin prompter that honours NonInteractive. answers
+// preset named prompts from command flags.
+func (rt Runtime) Prompter(answers map[string]string) *cli.Prompter {
+	return cli.NewPrompter(os.Stdin, os.Stdout).WithAnswers(answers).WithNonInteractive(rt.NonInteractive)
 }
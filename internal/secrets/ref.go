@@ -0,0 +1,107 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Reference schemes. A value of a config secret field (passwords, tokens and
+// discovery headers) that starts with one of them is replaced by the secret
+// it points to when the config is loaded.
+const (
+	SchemeSecret = "secret://"
+	SchemeEnv    = "env://"
+	SchemeFile   = "file://"
+	SchemeCmd    = "cmd://"
+)
+
+const commandTimeout = 10 * time.Second
+
+// IsRef reports whether s is a secret reference.
+func IsRef(s string) bool {
+	for _, scheme := range []string{SchemeSecret, SchemeEnv, SchemeFile, SchemeCmd} {
+		if strings.HasPrefix(s, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolver turns references into secret values.
+type Resolver struct {
+	// Dirs are the store directories for secret:// references, searched in
+	// order. secret:// references do not resolve without one.
+	Dirs []string
+}
+
+// Resolve returns the value ref points to:
+//
+//	secret://name  the entry in the local store (`wslbridge secret set`)
+//	env://VAR      the environment variable VAR, which must be set
+//	file://path    the file contents without the trailing newline; `~/`
+//	               expands to the home directory
+//	cmd://command  the trimmed output of `sh -c command`
+func (r Resolver) Resolve(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, SchemeSecret):
+		name := strings.TrimPrefix(ref, SchemeSecret)
+		if err := ValidateName(name); err != nil {
+			return "", err
+		}
+		if len(r.Dirs) == 0 {
+			return "", fmt.Errorf("no secret store to read %s from", name)
+		}
+		for _, dir := range r.Dirs {
+			val, err := Open(dir).Get(name)
+			if !errors.Is(err, ErrNotFound) {
+				return val, err
+			}
+		}
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	case strings.HasPrefix(ref, SchemeEnv):
+		name := strings.TrimPrefix(ref, SchemeEnv)
+		val, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return val, nil
+	case strings.HasPrefix(ref, SchemeFile):
+		path, err := expandHome(strings.TrimPrefix(ref, SchemeFile))
+		if err != nil {
+			return "", err
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	case strings.HasPrefix(ref, SchemeCmd):
+		command := strings.TrimPrefix(ref, SchemeCmd)
+		ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+		defer cancel()
+		out, err := exec.CommandContext(ctx, "sh", "-c", command).Output()
+		if err != nil {
+			return "", fmt.Errorf("run %q: %w", command, err)
+		}
+		return strings.TrimSpace(string(out)), nil
+	default:
+		return "", fmt.Errorf("not a secret reference: %q", ref)
+	}
+}
+
+func expandHome(path string) (string, error) {
+	if !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, path[2:]), nil
+}
@@ -0,0 +1,183 @@
+// Package secrets resolves secret references in config values and keeps the
+// local encrypted secret store.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+const (
+	storeFile = "store.json"
+	keyFile   = "store.key"
+	keySize   = 32
+)
+
+// ErrNotFound is returned for names missing from the store.
+var ErrNotFound = errors.New("secret not found")
+
+var nameRE = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ValidateName checks that name can be used in a secret:// reference.
+func ValidateName(name string) error {
+	if !nameRE.MatchString(name) {
+		return fmt.Errorf("invalid secret name %q: must start with a letter or digit and contain only letters, digits, `.`, `-` and `_`", name)
+	}
+	return nil
+}
+
+// Store is a directory holding secrets encrypted with AES-GCM. The key is a
+// random file next to the store, readable by the owner only: it keeps
+// secrets out of config files, backups and shell history, not away from
+// someone who can read the user's files.
+type Store struct {
+	dir string
+}
+
+// Open returns the store in dir. Files are created on the first Set.
+func Open(dir string) Store {
+	return Store{dir: dir}
+}
+
+// Dir returns the store directory.
+func (s Store) Dir() string { return s.dir }
+
+// Get returns the secret stored under name.
+func (s Store) Get(name string) (string, error) {
+	entries, err := s.read()
+	if err != nil {
+		return "", err
+	}
+	sealed, ok := entries[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	aead, err := s.cipher(false)
+	if err != nil {
+		return "", err
+	}
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(raw) < aead.NonceSize() {
+		return "", fmt.Errorf("secret %s: corrupt entry", name)
+	}
+	plain, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], []byte(name))
+	if err != nil {
+		return "", fmt.Errorf("secret %s: cannot decrypt (wrong or replaced %s?)", name, keyFile)
+	}
+	return string(plain), nil
+}
+
+// Set stores value under name, replacing any previous value.
+func (s Store) Set(name, value string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	entries, err := s.read()
+	if err != nil {
+		return err
+	}
+	// A new key is only made for an empty store: entries sealed with a lost
+	// key must not be silently mixed with ones sealed with a fresh one.
+	aead, err := s.cipher(len(entries) == 0)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	entries[name] = base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(value), []byte(name)))
+	return s.write(entries)
+}
+
+// Remove deletes name from the store.
+func (s Store) Remove(name string) error {
+	entries, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := entries[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	delete(entries, name)
+	return s.write(entries)
+}
+
+// List returns the stored names in order.
+func (s Store) List() ([]string, error) {
+	entries, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s Store) read() (map[string]string, error) {
+	entries := map[string]string{}
+	b, err := os.ReadFile(filepath.Join(s.dir, storeFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, fmt.Errorf("read secret store: %w", err)
+	}
+	return entries, nil
+}
+
+func (s Store) write(entries map[string]string) error {
+	b, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(s.dir, storeFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0o600); err != nil {
+		return fmt.Errorf("write secret store: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// cipher loads the store key, generating it when create is set.
+func (s Store) cipher(create bool) (cipher.AEAD, error) {
+	path := filepath.Join(s.dir, keyFile)
+	key, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && create {
+		if err := os.MkdirAll(s.dir, 0o700); err != nil {
+			return nil, err
+		}
+		key = make([]byte, keySize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, key, 0o600); err != nil {
+			return nil, fmt.Errorf("write secret key: %w", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("read secret key: %w", err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("secret key %s: expected %d bytes, got %d", path, keySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
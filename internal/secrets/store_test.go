@@ -0,0 +1,133 @@
+package secrets
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStoreSetGetRemove(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "secrets")
+	s := Open(dir)
+
+	if err := s.Set("db-pass", "s3cret"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := s.Set("token", "abc"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	got, err := s.Get("db-pass")
+	if err != nil || got != "s3cret" {
+		t.Fatalf("Get = %q, %v", got, err)
+	}
+	names, err := s.List()
+	if err != nil || !reflect.DeepEqual(names, []string{"db-pass", "token"}) {
+		t.Fatalf("List = %v, %v", names, err)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(dir, storeFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), "s3cret") {
+		t.Fatalf("store holds plaintext: %s", raw)
+	}
+	for _, name := range []string{storeFile, keyFile} {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != 0o600 {
+			t.Fatalf("%s mode = %v, want 0600", name, fi.Mode().Perm())
+		}
+	}
+
+	if err := s.Remove("db-pass"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := s.Get("db-pass"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get after Remove: %v, want ErrNotFound", err)
+	}
+	if err := s.Remove("db-pass"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("second Remove: %v, want ErrNotFound", err)
+	}
+}
+
+func TestStoreRejectsLostKey(t *testing.T) {
+	dir := t.TempDir()
+	s := Open(dir)
+	if err := s.Set("a", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, keyFile)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set("b", "2"); err == nil {
+		t.Fatalf("Set with a lost key must fail instead of making a new one")
+	}
+}
+
+func TestStoreInvalidName(t *testing.T) {
+	if err := Open(t.TempDir()).Set("../x", "1"); err == nil {
+		t.Fatalf("expected invalid name error")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	if err := Open(dir).Set("pw", "from-store"); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "token")
+	if err := os.WriteFile(file, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("WSLBRIDGE_TEST_SECRET", "from-env")
+
+	profile := t.TempDir()
+	if err := Open(profile).Set("own", "from-profile"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Names missing from the first store are looked up in the next one.
+	r := Resolver{Dirs: []string{profile, dir}}
+	for ref, want := range map[string]string{
+		"secret://own":                "from-profile",
+		"secret://pw":                 "from-store",
+		"env://WSLBRIDGE_TEST_SECRET": "from-env",
+		"file://" + file:              "from-file",
+		"cmd://echo from-cmd":         "from-cmd",
+	} {
+		got, err := r.Resolve(ref)
+		if err != nil || got != want {
+			t.Fatalf("Resolve(%q) = %q, %v; want %q", ref, got, err, want)
+		}
+	}
+
+	for _, ref := range []string{"secret://missing", "env://WSLBRIDGE_TEST_UNSET", "file://" + filepath.Join(dir, "nope"), "cmd://exit 3"} {
+		if _, err := r.Resolve(ref); err == nil {
+			t.Fatalf("Resolve(%q): expected error", ref)
+		}
+	}
+	if _, err := (Resolver{}).Resolve("secret://pw"); err == nil {
+		t.Fatalf("Resolve without a store: expected error")
+	}
+}
+
+func TestIsRef(t *testing.T) {
+	for s, want := range map[string]bool{
+		"secret://x": true,
+		"env://X":    true,
+		"file:///x":  true,
+		"cmd://true": true,
+		"http://x":   false,
+		"plain":      false,
+	} {
+		if IsRef(s) != want {
+			t.Fatalf("IsRef(%q) = %v", s, !want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// Package socks implements the client side of the SOCKS5 handshake (RFC
+// 1928) without authentication, which is what tun2socks speaks to the
+// upstream proxy.
+package socks
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	version5       = 0x05
+	methodNoAuth   = 0x00
+	methodNone     = 0xff
+	cmdConnect     = 0x01
+	atypIPv4       = 0x01
+	atypDomain     = 0x03
+	atypIPv6       = 0x04
+	replySucceeded = 0x00
+)
+
+// ErrAuthRequired is returned when the proxy does not accept clients
+// without authentication.
+var ErrAuthRequired = errors.New("socks5 proxy requires authentication")
+
+var replyText = map[byte]string{
+	0x01: "general server failure",
+	0x02: "connection not allowed by ruleset",
+	0x03: "network unreachable",
+	0x04: "host unreachable",
+	0x05: "connection refused",
+	0x06: "TTL expired",
+	0x07: "command not supported",
+	0x08: "address type not supported",
+}
+
+// Handshake connects to proxyAddr and negotiates the no-auth method.
+func Handshake(proxyAddr string, timeout time.Duration) error {
+	conn, err := dial(proxyAddr, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return negotiate(conn)
+}
+
+// Dial connects to target through the SOCKS5 proxy at proxyAddr. The
+// returned connection has no deadline set.
+func Dial(proxyAddr, target string, timeout time.Duration) (net.Conn, error) {
+	conn, err := dial(proxyAddr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if err := negotiate(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := connect(conn, target); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func dial(proxyAddr string, timeout time.Duration) (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", proxyAddr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func negotiate(conn net.Conn) error {
+	if _, err := conn.Write([]byte{version5, 1, methodNoAuth}); err != nil {
+		return fmt.Errorf("socks5 greeting: %w", err)
+	}
+	var resp [2]byte
+	if _, err := io.ReadFull(conn, resp[:]); err != nil {
+		return fmt.Errorf("socks5 greeting: %w", err)
+	}
+	if resp[0] != version5 {
+		return fmt.Errorf("not a socks5 proxy (version byte %#x)", resp[0])
+	}
+	switch resp[1] {
+	case methodNoAuth:
+		return nil
+	case methodNone:
+		return ErrAuthRequired
+	default:
+		return fmt.Errorf("socks5 proxy chose unsupported method %#x", resp[1])
+	}
+}
+
+func connect(conn net.Conn, target string) error {
+	host, portStr, err := net.SplitHostPort(target)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("invalid port in %q", target)
+	}
+
+	req := []byte{version5, cmdConnect, 0}
+	switch ip := net.ParseIP(host); {
+	case ip != nil && ip.To4() != nil:
+		req = append(append(req, atypIPv4), ip.To4()...)
+	case ip != nil:
+		req = append(append(req, atypIPv6), ip.To16()...)
+	default:
+		if len(host) > 255 {
+			return fmt.Errorf("host name too long: %s", host)
+		}
+		req = append(append(req, atypDomain, byte(len(host))), host...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return fmt.Errorf("socks5 connect: %w", err)
+	}
+
+	var head [4]byte
+	if _, err := io.ReadFull(conn, head[:]); err != nil {
+		return fmt.Errorf("socks5 connect: %w", err)
+	}
+	if head[1] != replySucceeded {
+		msg, ok := replyText[head[1]]
+		if !ok {
+			msg = fmt.Sprintf("reply %#x", head[1])
+		}
+		return fmt.Errorf("socks5 connect to %s: %s", target, msg)
+	}
+	// Skip the bound address.
+	var n int
+	switch head[3] {
+	case atypIPv4:
+		n = net.IPv4len
+	case atypIPv6:
+		n = net.IPv6len
+	case atypDomain:
+		var l [1]byte
+		if _, err := io.ReadFull(conn, l[:]); err != nil {
+			return fmt.Errorf("socks5 connect: %w", err)
+		}
+		n = int(l[0])
+	default:
+		return fmt.Errorf("socks5 connect: unknown address type %#x", head[3])
+	}
+	if _, err := io.ReadFull(conn, make([]byte, n+2)); err != nil {
+		return fmt.Errorf("socks5 connect: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package socks
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// serveOnce accepts one client on a fresh listener and runs fn on it.
+func serveOnce(t *testing.T, fn func(net.Conn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fn(conn)
+	}()
+	return ln.Addr().String()
+}
+
+// TestDial verifies the greeting and CONNECT request bytes and that data
+// flows after the handshake.
+func TestDial(t *testing.T) {
+	got := make(chan []byte, 2)
+	addr := serveOnce(t, func(c net.Conn) {
+		greet := make([]byte, 3)
+		_, _ = io.ReadFull(c, greet)
+		got <- greet
+		_, _ = c.Write([]byte{5, 0})
+		req := make([]byte, 4+1+len("db.example.com")+2)
+		_, _ = io.ReadFull(c, req)
+		got <- req
+		_, _ = c.Write([]byte{5, 0, 0, 1, 10, 0, 0, 1, 0x1f, 0x90})
+		_, _ = c.Write([]byte("pong"))
+	})
+
+	conn, err := Dial(addr, "db.example.com:5432", time.Second)
+	if err != nil {
+		t.Fatalf("Dial error: %v", err)
+	}
+	defer conn.Close()
+	if greet := <-got; !bytes.Equal(greet, []byte{5, 1, 0}) {
+		t.Fatalf("greeting = %v", greet)
+	}
+	want := append([]byte{5, 1, 0, 3, byte(len("db.example.com"))}, "db.example.com"...)
+	want = append(want, 0x15, 0x38)
+	if req := <-got; !bytes.Equal(req, want) {
+		t.Fatalf("connect request = %v, want %v", req, want)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "pong" {
+		t.Fatalf("read after handshake = %q, %v", buf, err)
+	}
+}
+
+func TestDial_Errors(t *testing.T) {
+	addr := serveOnce(t, func(c net.Conn) {
+		_, _ = io.ReadFull(c, make([]byte, 3))
+		_, _ = c.Write([]byte{5, 0xff})
+	})
+	if err := Handshake(addr, time.Second); !errors.Is(err, ErrAuthRequired) {
+		t.Fatalf("Handshake error = %v, want ErrAuthRequired", err)
+	}
+
+	addr = serveOnce(t, func(c net.Conn) {
+		_, _ = io.ReadFull(c, make([]byte, 3))
+		_, _ = c.Write([]byte{5, 0})
+		_, _ = io.ReadFull(c, make([]byte, 10))
+		_, _ = c.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+	})
+	if _, err := Dial(addr, "10.0.0.5:5432", time.Second); err == nil || !bytes.Contains([]byte(err.Error()), []byte("connection refused")) {
+		t.Fatalf("Dial error = %v, want connection refused", err)
+	}
+
+	addr = serveOnce(t, func(c net.Conn) {
+		_, _ = c.Write([]byte("HTTP/1.1 400 Bad Request\r\n\r\n"))
+	})
+	if err := Handshake(addr, time.Second); err == nil {
+		t.Fatalf("expected error for a non-socks server")
+	}
+}
@@ -15,27 +15,10 @@ import (
 
 // EnsureBin locates or installs the tun2socks binary.
 func EnsureBin() (string, error) {
-	if p, err := exec.LookPath("tun2socks"); err == nil {
+	if p, err := FindBin(); err == nil {
 		return p, nil
 	}
 
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", err
-	}
-
-	if gobin := os.Getenv("GOBIN"); gobin != "" {
-		bin := filepath.Join(gobin, "tun2socks")
-		if isExecutable(bin) {
-			return bin, nil
-		}
-	}
-
-	bin := filepath.Join(home, "go", "bin", "tun2socks")
-	if isExecutable(bin) {
-		return bin, nil
-	}
-
 	if _, err := exec.LookPath("go"); err != nil {
 		return "", fmt.Errorf("tun2socks not found and go is not installed")
 	}
@@ -48,14 +31,43 @@ func EnsureBin() (string, error) {
 		return "", fmt.Errorf("failed to install tun2socks: %w", err)
 	}
 
+	if p, err := FindBin(); err == nil {
+		return p, nil
+	}
+	return "", fmt.Errorf("tun2socks install finished but binary not found (check your GOBIN / GOPATH)")
+}
+
+// FindBin locates an installed tun2socks binary in PATH, $GOBIN or
+// ~/go/bin.
+func FindBin() (string, error) {
 	if p, err := exec.LookPath("tun2socks"); err == nil {
 		return p, nil
 	}
+	if gobin := os.Getenv("GOBIN"); gobin != "" {
+		bin := filepath.Join(gobin, "tun2socks")
+		if isExecutable(bin) {
+			return bin, nil
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	bin := filepath.Join(home, "go", "bin", "tun2socks")
 	if isExecutable(bin) {
 		return bin, nil
 	}
+	return "", fmt.Errorf("tun2socks not found in PATH, $GOBIN or ~/go/bin")
+}
 
-	return "", fmt.Errorf("tun2socks install finished but binary not found (check your GOBIN / GOPATH)")
+// Version returns the first line of `tun2socks -version`.
+func Version(bin string) (string, error) {
+	out, err := exec.Command(bin, "-version").Output()
+	if err != nil {
+		return "", fmt.Errorf("%s -version: %w", bin, err)
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return strings.TrimSpace(line), nil
 }
 
 // Start launches tun2socks and returns its PID.